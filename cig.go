@@ -2,6 +2,8 @@ package cig
 
 import (
 	"github.com/MarioLegenda/cig/internal/db"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/db/join"
 	"github.com/MarioLegenda/cig/internal/syntax"
 )
 
@@ -9,7 +11,18 @@ type Cig interface {
 	Run(sql string) Data
 }
 
-type cig struct{}
+type cig struct {
+	options options
+}
+
+type options struct {
+	nullMarkers []string
+	tempDir     string
+	memoryLimit int64
+}
+
+// Option configures cig. Pass options to New().
+type Option func(o *options)
 
 type Data struct {
 	SelectedColumns []string
@@ -18,19 +31,61 @@ type Data struct {
 	Data            []map[string]string
 }
 
+// WithNullMarkers sets the cell values that are interpreted as NULL. By default,
+// empty cells, NULL, NA and - are NULL. Calling it without arguments means that
+// no value is NULL.
+func WithNullMarkers(markers ...string) Option {
+	return func(o *options) {
+		o.nullMarkers = markers
+	}
+}
+
+// WithTempDir sets the directory in which joins that do not fit into memory create their
+// temporary files. By default, it is the temporary directory of the system.
+func WithTempDir(dir string) Option {
+	return func(o *options) {
+		o.tempDir = dir
+	}
+}
+
+// WithMemoryLimit sets how many bytes the rows of a joined file can take in memory, 256 MiB by
+// default. Bigger files are split into temporary files and joined part by part. A limit of 0
+// keeps joined files in memory whatever their size.
+func WithMemoryLimit(bytes int64) Option {
+	return func(o *options) {
+		o.memoryLimit = bytes
+	}
+}
+
 func (c cig) Run(sql string) Data {
 	res, err := syntax.NewStructure(sql)
 	if err != nil {
 		return newData(nil, nil, nil, err)
 	}
 
-	data := db.New().Run(res)
+	d := db.New(db.Options{
+		NullMarkers: c.options.nullMarkers,
+		TempDir:     c.options.tempDir,
+		MemoryLimit: c.options.memoryLimit,
+	})
+	defer d.Close()
+
+	data := d.Run(res)
 
 	return newData(data.SelectedColumns, data.AllColumns, data.Data, data.Error)
 }
 
-func New() Cig {
-	return cig{}
+func New(opts ...Option) Cig {
+	o := options{
+		nullMarkers: comparison.DefaultNullMarkers,
+		memoryLimit: join.DefaultMemoryLimit,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return cig{options: o}
 }
 
 func newData(selected, all []string, data []map[string]string, err error) Data {
@@ -1,9 +1,15 @@
 package cig
 
 import (
+	"errors"
+	"github.com/MarioLegenda/cig/pkg"
 	"github.com/stretchr/testify/assert"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestGettingAllResults(t *testing.T) {
@@ -18,10 +24,765 @@ func TestGettingAllResults(t *testing.T) {
 func TestGettingResultsWithSingleWhereClause(t *testing.T) {
 	c := New()
 
+	res := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1'")
+
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 5031, len(res.Data))
+}
+
+func TestGettingResultsWithLogicalOperatorPrecedence(t *testing.T) {
+	c := New()
+
+	levelOne := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1'")
+	assert.Nil(t, levelOne.Error)
+
+	levelTwoIn2021 := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 2' AND 'e.Year'::int = '2021'")
+	assert.Nil(t, levelTwoIn2021.Error)
+
+	levelOneIn2021 := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1' AND 'e.Year'::int = '2021'")
+	assert.Nil(t, levelOneIn2021.Error)
+
+	// AND binds stronger than OR
 	res := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1' OR 'e.Industry_aggregation_NZSIOC' = 'Level 2' AND 'e.Year'::int = '2021'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, len(levelOne.Data)+len(levelTwoIn2021.Data), len(res.Data))
+
+	// parentheses override precedence
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE ('e.Industry_aggregation_NZSIOC' = 'Level 1' OR 'e.Industry_aggregation_NZSIOC' = 'Level 2') AND 'e.Year'::int = '2021'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, len(levelOneIn2021.Data)+len(levelTwoIn2021.Data), len(res.Data))
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE NOT 'e.Industry_aggregation_NZSIOC' = 'Level 1'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715-len(levelOne.Data), len(res.Data))
+
+	// NOT of the parenthesized OR is Level 1 AND 2021
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE NOT ('e.Industry_aggregation_NZSIOC' != 'Level 1' OR NOT 'e.Year'::int = '2021')")
+	assert.Nil(t, res.Error)
+	assert.NotEqual(t, 0, len(levelOneIn2021.Data))
+	assert.Equal(t, len(levelOneIn2021.Data), len(res.Data))
+
+	for _, r := range res.Data {
+		assert.Equal(t, "Level 1", r["Industry_aggregation_NZSIOC"])
+		assert.Equal(t, "2021", r["Year"])
+	}
+}
+
+func TestGettingResultsWithListOperators(t *testing.T) {
+	c := New()
+
+	or := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::int = '2014' OR 'e.Year'::int = '2016' OR 'e.Year'::int = '2018'")
+	assert.Nil(t, or.Error)
+
+	in := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::int IN ('2014', '2016', '2018')")
+	assert.Nil(t, in.Error)
+	assert.Equal(t, len(or.Data), len(in.Data))
+
+	notIn := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::int NOT IN ('2014', '2016', '2018')")
+	assert.Nil(t, notIn.Error)
+	assert.Equal(t, 41715-len(in.Data), len(notIn.Data))
+}
+
+func TestGettingResultsWithPatternOperators(t *testing.T) {
+	c := New()
 
+	res := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' LIKE 'Level _'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715, len(res.Data))
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' ILIKE 'LEVEL 1'")
 	assert.Nil(t, res.Error)
 	assert.Equal(t, 5031, len(res.Data))
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' NOT LIKE '%1'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715-5031, len(res.Data))
+}
+
+func TestGettingResultsWithRegexOperators(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' ~ '^Level [12]$'")
+	assert.Nil(t, res.Error)
+
+	in := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' IN ('Level 1', 'Level 2')")
+	assert.Nil(t, in.Error)
+	assert.Equal(t, len(in.Data), len(res.Data))
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' !~* '^level'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 0, len(res.Data))
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' ~ '(Level'")
+	assert.True(t, errors.Is(res.Error, pkg.InvalidRegex))
+}
+
+func TestGettingResultsWithRangeOperators(t *testing.T) {
+	c := New()
+
+	comparison := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::int >= '2015' AND 'e.Year'::int <= '2020'")
+	assert.Nil(t, comparison.Error)
+
+	between := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::int BETWEEN '2015' AND '2020'")
+	assert.Nil(t, between.Error)
+	assert.Equal(t, len(comparison.Data), len(between.Data))
+
+	for _, row := range between.Data {
+		assert.True(t, row["Year"] >= "2015" && row["Year"] <= "2020")
+	}
+
+	year := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::int = '2016'")
+	assert.Nil(t, year.Error)
+
+	notBetween := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::int NOT BETWEEN '2015' AND '2020' OR 'e.Year'::int = '2016'")
+	assert.Nil(t, notBetween.Error)
+	assert.Equal(t, 41715-len(between.Data)+len(year.Data), len(notBetween.Data))
+}
+
+func TestGettingResultsWithNulls(t *testing.T) {
+	c := New(WithNullMarkers("C", "S"))
+
+	suppressed := New().Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Value' IN ('C', 'S')")
+	assert.Nil(t, suppressed.Error)
+
+	res := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Value' IS NULL")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, len(suppressed.Data), len(res.Data))
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Value' IS NOT NULL")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715-len(suppressed.Data), len(res.Data))
+
+	// comparisons with NULL are neither true nor false
+	equal := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Value' = '0'")
+	assert.Nil(t, equal.Error)
+
+	notEqual := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE NOT 'e.Value' = '0'")
+	assert.Nil(t, notEqual.Error)
+	assert.Equal(t, 41715-len(suppressed.Data), len(equal.Data)+len(notEqual.Data))
+
+	res = New().Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC'::int = '1'")
+	assert.NotNil(t, res.Error)
+}
+
+func TestGettingResultsWithAggregates(t *testing.T) {
+	c := New()
+
+	levelOne := c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1'")
+	assert.Nil(t, levelOne.Error)
+
+	var sum int64
+	minYear := ""
+	maxYear := ""
+	for _, r := range levelOne.Data {
+		year, err := strconv.ParseInt(r["Year"], 10, 64)
+		assert.Nil(t, err)
+		sum += year
+
+		if minYear == "" || r["Year"] < minYear {
+			minYear = r["Year"]
+		}
+
+		if maxYear == "" || r["Year"] > maxYear {
+			maxYear = r["Year"]
+		}
+	}
+
+	res := c.Run("SELECT COUNT(*), SUM('e.Year'::int), MIN('e.Year'::int), MAX('e.Year'::int), AVG('e.Year') FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 1, len(res.Data))
+	assert.Equal(t, []string{"count(*)", "sum(Year)", "min(Year)", "max(Year)", "avg(Year)"}, res.SelectedColumns)
+
+	row := res.Data[0]
+	assert.Equal(t, strconv.Itoa(len(levelOne.Data)), row["count(*)"])
+	assert.Equal(t, strconv.FormatInt(sum, 10), row["sum(Year)"])
+	assert.Equal(t, minYear, row["min(Year)"])
+	assert.Equal(t, maxYear, row["max(Year)"])
+
+	avg, err := strconv.ParseFloat(row["avg(Year)"], 64)
+	assert.Nil(t, err)
+	assert.InDelta(t, float64(sum)/float64(len(levelOne.Data)), avg, 0.000001)
+
+	// COUNT of a column does not count NULL values
+	suppressed := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Value' IN ('C', 'S')")
+	assert.Nil(t, suppressed.Error)
+
+	res = New(WithNullMarkers("C", "S")).Run("SELECT COUNT('e.Value'), COUNT(*) FROM path:testdata/example.csv AS e")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, strconv.Itoa(41715-len(suppressed.Data)), res.Data[0]["count(Value)"])
+	assert.Equal(t, "41715", res.Data[0]["count(*)"])
+
+	// aggregates over no rows
+	res = c.Run("SELECT COUNT(*), SUM('e.Year'::int) FROM path:testdata/example.csv AS e WHERE 'e.Year'::int < '0'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "0", res.Data[0]["count(*)"])
+	assert.Equal(t, "", res.Data[0]["sum(Year)"])
+
+	res = c.Run("SELECT SUM('e.Value'::float) FROM path:testdata/example.csv AS e")
+	assert.NotNil(t, res.Error)
+
+	// a sum of integers that does not fit into 64 bits is an error, not a wrapped around value
+	res = c.Run("SELECT SUM('n.total'::int) FROM path:testdata/numbers.csv AS n")
+	assert.NotNil(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "Integer overflow")
+
+	res = c.Run("SELECT SUM('n.total'::int) FROM path:testdata/numbers.csv AS n WHERE 'n.id' != '2'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "9223372036854775807", res.Data[0]["sum(total)"])
+
+	res = c.Run("SELECT COUNT(*) FROM path:testdata/example.csv AS e OFFSET 1")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 0, len(res.Data))
+}
+
+func TestGettingResultsWithGroupBy(t *testing.T) {
+	c := New()
+
+	levelOne := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1'")
+	assert.Nil(t, levelOne.Error)
+
+	res := c.Run("SELECT 'e.Industry_aggregation_NZSIOC', COUNT(*) FROM path:testdata/example.csv AS e GROUP BY 'e.Industry_aggregation_NZSIOC' ORDER BY COUNT(*) DESC")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"Industry_aggregation_NZSIOC", "count(*)"}, res.SelectedColumns)
+
+	total := 0
+	previous := int64(-1)
+	for _, r := range res.Data {
+		count, err := strconv.ParseInt(r["count(*)"], 10, 64)
+		assert.Nil(t, err)
+		total += int(count)
+
+		if previous != -1 {
+			assert.True(t, previous >= count)
+		}
+		previous = count
+
+		if r["Industry_aggregation_NZSIOC"] == "Level 1" {
+			assert.Equal(t, strconv.Itoa(len(levelOne.Data)), r["count(*)"])
+		}
+	}
+
+	assert.Equal(t, 41715, total)
+
+	// every group is a distinct combination of the grouped columns
+	res = c.Run("SELECT 'e.Year', 'e.Industry_aggregation_NZSIOC', COUNT(*) FROM path:testdata/example.csv AS e GROUP BY 'e.Year', 'e.Industry_aggregation_NZSIOC'")
+	assert.Nil(t, res.Error)
+
+	total = 0
+	seen := make(map[string]bool)
+	for _, r := range res.Data {
+		key := r["Year"] + "|" + r["Industry_aggregation_NZSIOC"]
+		assert.False(t, seen[key])
+		seen[key] = true
+
+		count, err := strconv.Atoi(r["count(*)"])
+		assert.Nil(t, err)
+		total += count
+	}
+
+	assert.Equal(t, 41715, total)
+
+	// HAVING filters groups by aggregates that are not selected
+	all := c.Run("SELECT 'e.Year', COUNT(*) FROM path:testdata/example.csv AS e GROUP BY 'e.Year'")
+	assert.Nil(t, all.Error)
+
+	expected := 0
+	for _, r := range all.Data {
+		count, _ := strconv.Atoi(r["count(*)"])
+		if count > 3000 {
+			expected++
+		}
+	}
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e GROUP BY 'e.Year' HAVING COUNT(*) > '3000' ORDER BY 'e.Year'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, expected, len(res.Data))
+
+	for i, r := range res.Data {
+		assert.Equal(t, 1, len(r))
+
+		if i > 0 {
+			assert.True(t, res.Data[i-1]["Year"] < r["Year"])
+		}
+	}
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e GROUP BY 'e.Year' ORDER BY COUNT(*) DESC LIMIT 1 OFFSET 1")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 1, len(res.Data))
+
+	res = c.Run("SELECT 'e.Missing' FROM path:testdata/example.csv AS e GROUP BY 'e.Missing'")
+	assert.NotNil(t, res.Error)
+}
+
+func TestGettingResultsWithDistinct(t *testing.T) {
+	c := New()
+
+	groups := c.Run("SELECT 'e.Year', 'e.Industry_aggregation_NZSIOC' FROM path:testdata/example.csv AS e GROUP BY 'e.Year', 'e.Industry_aggregation_NZSIOC'")
+	assert.Nil(t, groups.Error)
+
+	res := c.Run("SELECT DISTINCT 'e.Year', 'e.Industry_aggregation_NZSIOC' FROM path:testdata/example.csv AS e")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, len(groups.Data), len(res.Data))
+
+	seen := make(map[string]bool)
+	for _, r := range res.Data {
+		key := r["Year"] + "|" + r["Industry_aggregation_NZSIOC"]
+		assert.False(t, seen[key])
+		seen[key] = true
+	}
+
+	// OFFSET and LIMIT are applied after removing duplicates
+	paginated := c.Run("SELECT DISTINCT 'e.Year', 'e.Industry_aggregation_NZSIOC' FROM path:testdata/example.csv AS e OFFSET 1 LIMIT 3")
+	assert.Nil(t, paginated.Error)
+	assert.Equal(t, res.Data[1:4], paginated.Data)
+
+	years := c.Run("SELECT DISTINCT 'e.Year' FROM path:testdata/example.csv AS e")
+	assert.Nil(t, years.Error)
+
+	// DISTINCT ON keeps the first row of every year
+	res = c.Run("SELECT DISTINCT ON ('e.Year') * FROM path:testdata/example.csv AS e")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, len(years.Data), len(res.Data))
+
+	for _, r := range res.Data {
+		assert.Equal(t, 10, len(r))
+	}
+}
+
+func TestGettingResultsWithExpressions(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT 'e.Year' AS year, 'e.Year'::int * 1000 AS year_k, ('e.Year'::int - 2000) / 2 AS half, 'e.Year' / 4, 'e.Industry_code_NZSIOC' AS code FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1' LIMIT 100")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"year", "year_k", "half", "'e.Year' / 4", "code"}, res.SelectedColumns)
+	assert.Equal(t, 100, len(res.Data))
+
+	for _, r := range res.Data {
+		assert.Equal(t, 5, len(r))
+
+		year, err := strconv.ParseInt(r["year"], 10, 64)
+		assert.Nil(t, err)
+
+		assert.Equal(t, strconv.FormatInt(year*1000, 10), r["year_k"])
+		// integer division
+		assert.Equal(t, strconv.FormatInt((year-2000)/2, 10), r["half"])
+		// columns without a data type are floats
+		assert.Equal(t, strconv.FormatFloat(float64(year)/4, 'f', -1, 64), r["'e.Year' / 4"])
+		assert.NotEmpty(t, r["code"])
+	}
+
+	// DISTINCT works on the computed values
+	years := c.Run("SELECT DISTINCT 'e.Year' FROM path:testdata/example.csv AS e")
+	assert.Nil(t, years.Error)
+
+	res = c.Run("SELECT DISTINCT 'e.Year'::int * 0 + 1 AS one FROM path:testdata/example.csv AS e")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 1, len(res.Data))
+	assert.Equal(t, "1", res.Data[0]["one"])
+
+	res = c.Run("SELECT 'e.Year', COUNT(*) AS total, 'e.Year'::int + 1 AS next FROM path:testdata/example.csv AS e GROUP BY 'e.Year'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, len(years.Data), len(res.Data))
+
+	for _, r := range res.Data {
+		year, _ := strconv.Atoi(r["Year"])
+		assert.Equal(t, strconv.Itoa(year+1), r["next"])
+		assert.NotEmpty(t, r["total"])
+	}
+
+	// NULL operands make the result NULL
+	res = New(WithNullMarkers("C", "S")).Run("SELECT 'e.Value'::float * 2 AS doubled FROM path:testdata/example.csv AS e WHERE 'e.Value' IS NULL LIMIT 5")
+	assert.Nil(t, res.Error)
+	for _, r := range res.Data {
+		assert.Equal(t, "", r["doubled"])
+	}
+
+	res = c.Run("SELECT 'e.Industry_aggregation_NZSIOC'::int * 2 FROM path:testdata/example.csv AS e")
+	assert.NotNil(t, res.Error)
+
+	// quoted numbers are numbers, like values of conditions
+	res = c.Run("SELECT 't.price'::float * '1.5' AS scaled, 't.id'::int + '1' AS next FROM path:testdata/trades.csv AS t WHERE 't.price'::float - '0.5' > '184' AND 't.id' = '1'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"scaled": "277.5", "next": "2"}}, res.Data)
+
+	// integers that do not fit into 64 bits are an error, not a wrapped around value
+	for _, sql := range []string{
+		"SELECT 'e.Year'::int * 9223372036854775807 FROM path:testdata/example.csv AS e LIMIT 1",
+		"SELECT 'e.Year'::int + 9223372036854775807 FROM path:testdata/example.csv AS e LIMIT 1",
+		"SELECT 0 - 'e.Year'::int - 9223372036854775807 FROM path:testdata/example.csv AS e LIMIT 1",
+	} {
+		res = c.Run(sql)
+		assert.NotNil(t, res.Error, sql)
+		assert.Contains(t, res.Error.Error(), "Integer overflow", sql)
+	}
+
+	res = c.Run("SELECT 'e.Year'::int * 4000000000000000 AS big FROM path:testdata/example.csv AS e WHERE 'e.Year' = '2021' LIMIT 1")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "8084000000000000000", res.Data[0]["big"])
+}
+
+func TestGettingResultsWithFunctions(t *testing.T) {
+	c := New()
+
+	level := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1'")
+	assert.Nil(t, level.Error)
+
+	res := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE LOWER('e.Industry_aggregation_NZSIOC') = 'level 1'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, len(level.Data), len(res.Data))
+
+	res = c.Run("SELECT UPPER('e.Industry_name_NZSIOC') AS upper, LENGTH('e.Industry_code_NZSIOC') AS length, CONCAT('e.Year', '-', 'e.Industry_code_NZSIOC') AS code, SUBSTR('e.Year', 3) AS short, REPLACE('e.Units', 'Dollars', 'USD') AS units, TRIM(' x ') AS trimmed, 'e.Industry_name_NZSIOC', 'e.Industry_code_NZSIOC', 'e.Year', 'e.Units' FROM path:testdata/example.csv AS e LIMIT 50")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 50, len(res.Data))
+
+	for _, r := range res.Data {
+		assert.Equal(t, strings.ToUpper(r["Industry_name_NZSIOC"]), r["upper"])
+		assert.Equal(t, strconv.Itoa(len(r["Industry_code_NZSIOC"])), r["length"])
+		assert.Equal(t, r["Year"]+"-"+r["Industry_code_NZSIOC"], r["code"])
+		assert.Equal(t, r["Year"][2:], r["short"])
+		assert.Equal(t, strings.ReplaceAll(r["Units"], "Dollars", "USD"), r["units"])
+		assert.Equal(t, "x", r["trimmed"])
+	}
+
+	// functions can be sorted by and used in arithmetic
+	res = c.Run("SELECT 'e.Industry_code_NZSIOC', LENGTH('e.Industry_code_NZSIOC') * 2 AS double FROM path:testdata/example.csv AS e ORDER BY LENGTH('e.Industry_code_NZSIOC') DESC LIMIT 1")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 1, len(res.Data))
+
+	longest := len(res.Data[0]["Industry_code_NZSIOC"])
+	assert.Equal(t, strconv.Itoa(longest*2), res.Data[0]["double"])
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE LENGTH('e.Industry_code_NZSIOC') > '" + strconv.Itoa(longest) + "'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 0, len(res.Data))
+
+	res = c.Run("SELECT SUBSTR('e.Year', 'e.Units') FROM path:testdata/example.csv AS e")
+	assert.NotNil(t, res.Error)
+}
+
+func TestGettingResultsWithDates(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::date('2006') > '2013'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 37080, len(res.Data))
+
+	// values that do not match the layout are parsed as ISO-8601
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::date('2006') >= '2014-01-01'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 37080, len(res.Data))
+
+	between := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::timestamp('2006') BETWEEN '2014-01-01T00:00:00Z' AND '2021'")
+	assert.Nil(t, between.Error)
+	assert.Equal(t, 37080, len(between.Data))
+
+	// values of the file must be in the layout, they are not parsed as ISO-8601
+	res = c.Run("SELECT 't.id' FROM path:testdata/trades.csv AS t WHERE 't.ts'::date('02/01/2006') > '01/01/2024'")
+	assert.NotNil(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "is not a valid date")
+
+	res = c.Run("SELECT 't.id' FROM path:testdata/trades.csv AS t WHERE 't.ts'::timestamp('2006-01-02 15:04:05') > '2024-01-02T09:31:00Z'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 4, len(res.Data))
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::date('2006') IN ('2014-01-01', '2015')")
+	assert.Nil(t, res.Error)
+	assert.NotEqual(t, 0, len(res.Data))
+
+	for _, r := range res.Data {
+		assert.Contains(t, []string{"2014", "2015"}, r["Year"])
+	}
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e ORDER BY 'e.Year'::date('2006') DESC LIMIT 1")
+	assert.Nil(t, res.Error)
+
+	last := res.Data[0]["Year"]
+
+	res = c.Run("SELECT MAX('e.Year'::date('2006')), MIN('e.Year'::date('2006')) FROM path:testdata/example.csv AS e")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, last, res.Data[0]["max(Year)"])
+	assert.Less(t, res.Data[0]["min(Year)"], last)
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_code_NZSIOC'::date > '2014-01-01'")
+	assert.NotNil(t, res.Error)
+}
+
+func TestGettingResultsWithDateFunctions(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT 'e.Year', EXTRACT(year FROM 'e.Year'::date('2006')) AS year, DATE_TRUNC('quarter', 'e.Year'::date('2006') + INTERVAL '5 months') AS quarter, 'e.Year'::date('2006') - INTERVAL '1 day' AS previous FROM path:testdata/example.csv AS e LIMIT 50")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 50, len(res.Data))
+
+	for _, r := range res.Data {
+		year, _ := strconv.Atoi(r["Year"])
+
+		assert.Equal(t, r["Year"], r["year"])
+		assert.Equal(t, r["Year"]+"-04-01T00:00:00Z", r["quarter"])
+		assert.Equal(t, strconv.Itoa(year-1)+"-12-31", r["previous"])
+	}
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE EXTRACT(year FROM 'e.Year'::date('2006')) > '2013'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 37080, len(res.Data))
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::date('2006') + INTERVAL '1 year' > '2014-12-31'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 37080, len(res.Data))
+
+	// computed values are compared like any other value
+	recent := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::date('2006') >= DATE_TRUNC('year', NOW()) - INTERVAL '" + strconv.Itoa(time.Now().Year()-2014) + " years'")
+	assert.Nil(t, recent.Error)
+	assert.Equal(t, 37080, len(recent.Data))
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Year'::date('2006') < CURRENT_DATE")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715, len(res.Data))
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e ORDER BY 'e.Year'::date('2006') + INTERVAL '1 day' DESC LIMIT 1")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "2021", res.Data[0]["Year"])
+
+	res = c.Run("SELECT EXTRACT(year FROM 'e.Industry_code_NZSIOC') FROM path:testdata/example.csv AS e")
+	assert.NotNil(t, res.Error)
+}
+
+func TestGettingResultsWithDecimals(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT 'e.Year'::decimal(6,2) * 1.10 AS increased, 'e.Year'::decimal / 3 AS third, 'e.Year' FROM path:testdata/example.csv AS e WHERE 'e.Year'::decimal(6,2) = '2020.00' LIMIT 1")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "2222.0000", res.Data[0]["increased"])
+	assert.Equal(t, "673.333333", res.Data[0]["third"])
+
+	res = c.Run("SELECT COUNT(*), SUM('e.Year'::decimal(6,2)), AVG('e.Year'::decimal) FROM path:testdata/example.csv AS e WHERE 'e.Year'::int = '2020'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "4635", res.Data[0]["count(*)"])
+	assert.Equal(t, "9362700.00", res.Data[0]["sum(Year)"])
+	assert.Equal(t, "2020.000000", res.Data[0]["avg(Year)"])
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e ORDER BY 'e.Year'::decimal(6,2) DESC LIMIT 1")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "2021", res.Data[0]["Year"])
+
+	// hexadecimal, binary and octal numbers and digit separators are not decimals
+	for _, id := range []string{"1", "2", "3", "4"} {
+		res = c.Run("SELECT 'n.amount' FROM path:testdata/numbers.csv AS n WHERE 'n.id' = '" + id + "' AND 'n.amount'::decimal(10,2) = '16'")
+		assert.NotNil(t, res.Error, id)
+		assert.Contains(t, res.Error.Error(), "is not a valid decimal", id)
+
+		res = c.Run("SELECT SUM('n.amount'::decimal(10,2)) FROM path:testdata/numbers.csv AS n WHERE 'n.id' = '" + id + "'")
+		assert.NotNil(t, res.Error, id)
+	}
+
+	res = c.Run("SELECT * FROM path:testdata/numbers.csv AS n WHERE 'n.amount'::decimal(10,2) = '0x10'")
+	assert.NotNil(t, res.Error)
+	assert.True(t, errors.Is(res.Error, pkg.InvalidDataType))
+}
+
+func TestGettingResultsWithMultipleOrderByColumns(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT 'e.Year', 'e.Industry_code_NZSIOC' FROM path:testdata/example.csv AS e ORDER BY 'e.Year'::int DESC, 'e.Industry_code_NZSIOC' ASC")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715, len(res.Data))
+
+	for i := 1; i < len(res.Data); i++ {
+		previous, current := res.Data[i-1], res.Data[i]
+
+		assert.GreaterOrEqual(t, previous["Year"], current["Year"])
+		if previous["Year"] == current["Year"] {
+			assert.LessOrEqual(t, previous["Industry_code_NZSIOC"], current["Industry_code_NZSIOC"])
+		}
+	}
+
+	// NULL values are the largest values unless NULLS FIRST or NULLS LAST says otherwise
+	c = New(WithNullMarkers("C", "S"))
+	nulls := c.Run("SELECT 'e.Value' FROM path:testdata/example.csv AS e WHERE 'e.Value' IS NULL")
+	assert.Nil(t, nulls.Error)
+
+	res = c.Run("SELECT 'e.Value' FROM path:testdata/example.csv AS e ORDER BY 'e.Value' DESC LIMIT 1")
+	assert.Nil(t, res.Error)
+	assert.Contains(t, []string{"C", "S"}, res.Data[0]["Value"])
+
+	res = c.Run("SELECT 'e.Value' FROM path:testdata/example.csv AS e ORDER BY 'e.Value' DESC NULLS LAST")
+	assert.Nil(t, res.Error)
+	for i, row := range res.Data {
+		isNull := row["Value"] == "C" || row["Value"] == "S"
+		assert.Equal(t, i >= len(res.Data)-len(nulls.Data), isNull)
+	}
+
+	res = c.Run("SELECT 'e.Value' FROM path:testdata/example.csv AS e ORDER BY 'e.Value' NULLS FIRST LIMIT 1 OFFSET " + strconv.Itoa(len(nulls.Data)))
+	assert.Nil(t, res.Error)
+	assert.NotContains(t, []string{"C", "S"}, res.Data[0]["Value"])
+}
+
+func TestGettingResultsWithCollations(t *testing.T) {
+	c := New()
+
+	exact := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_name_NZSIOC' = 'Manufacturing'")
+	assert.Nil(t, exact.Error)
+	assert.NotEqual(t, 0, len(exact.Data))
+
+	res := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_name_NZSIOC' COLLATE nocase = 'MANUFACTURING'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, len(exact.Data), len(res.Data))
+
+	res = c.Run("SELECT DISTINCT 'e.Industry_code_NZSIOC' FROM path:testdata/example.csv AS e ORDER BY 'e.Industry_code_NZSIOC' COLLATE natural LIMIT 7")
+	assert.Nil(t, res.Error)
+
+	codes := make([]string, len(res.Data))
+	for i, row := range res.Data {
+		codes[i] = row["Industry_code_NZSIOC"]
+	}
+
+	assert.Equal(t, []string{"99999", "AA", "AA1", "AA11", "AA12", "AA111", "AA112"}, codes)
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_code_NZSIOC' COLLATE french = 'AA'")
+	assert.True(t, errors.Is(res.Error, pkg.InvalidCollation))
+}
+
+func TestGettingResultsWithColumnComparisons(t *testing.T) {
+	c := New(WithNullMarkers("C", "S"))
+
+	res := c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e WHERE 'e.Year'::int = 'e.Year'::int")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715, len(res.Data))
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e WHERE 'e.Year'::int > 'e.Year'::int - 1 AND NOT 'e.Year'::int < 'e.Year'::int")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715, len(res.Data))
+
+	// comparing NULL with a column is neither true nor false
+	nulls := c.Run("SELECT 'e.Value' FROM path:testdata/example.csv AS e WHERE 'e.Value' IS NULL")
+	assert.Nil(t, nulls.Error)
+
+	res = c.Run("SELECT 'e.Value' FROM path:testdata/example.csv AS e WHERE 'e.Value' = 'e.Value'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715-len(nulls.Data), len(res.Data))
+
+	years := c.Run("SELECT DISTINCT 'e.Year' FROM path:testdata/example.csv AS e")
+	assert.Nil(t, years.Error)
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e GROUP BY 'e.Year' HAVING MAX('e.Year'::int) = 'e.Year'::int")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, len(years.Data), len(res.Data))
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e WHERE 'e.Year'::int = 'e.Units'::date")
+	assert.True(t, errors.Is(res.Error, pkg.InvalidDataType))
+}
+
+func TestGettingResultsWithJoins(t *testing.T) {
+	c := New()
+
+	levels := c.Run("SELECT * FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' IN ('Level 1', 'Level 2', 'Level 3')")
+	assert.Nil(t, levels.Error)
+
+	res := c.Run("SELECT 'e.Year', 'l.Description' FROM path:testdata/example.csv AS e JOIN path:testdata/levels.csv AS l ON 'e.Industry_aggregation_NZSIOC' = 'l.Level'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"e.Year", "l.Description"}, res.SelectedColumns)
+	assert.Equal(t, len(levels.Data), len(res.Data))
+	assert.Equal(t, "Industry division", res.Data[0]["l.Description"])
+
+	res = c.Run("SELECT * FROM path:testdata/example.csv AS e INNER JOIN path:testdata/levels.csv AS l ON 'e.Industry_aggregation_NZSIOC' = 'l.Level' WHERE 'l.Description' = 'Industry group'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 12, len(res.SelectedColumns))
+	assert.Equal(t, 18000, len(res.Data))
+
+	// there is no Level 4 in levels.csv so its rows do not have a description
+	res = c.Run("SELECT 'e.Year', 'l.Description' FROM path:testdata/example.csv AS e LEFT JOIN path:testdata/levels.csv AS l ON 'e.Industry_aggregation_NZSIOC' = 'l.Level'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 41715, len(res.Data))
+
+	res = c.Run("SELECT 'l.Description' FROM path:testdata/example.csv AS e LEFT OUTER JOIN path:testdata/levels.csv AS l ON 'e.Industry_aggregation_NZSIOC' = 'l.Level' WHERE 'l.Level' IS NULL")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 5715, len(res.Data))
+	assert.Equal(t, "", res.Data[0]["l.Description"])
+
+	// nothing in example.csv is Level 5
+	res = c.Run("SELECT 'l.Level', COUNT('e.Year') FROM path:testdata/example.csv AS e RIGHT JOIN path:testdata/levels.csv AS l ON 'e.Industry_aggregation_NZSIOC' = 'l.Level' GROUP BY 'l.Level' ORDER BY 'l.Level'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 4, len(res.Data))
+	assert.Equal(t, "Level 5", res.Data[3]["l.Level"])
+	assert.Equal(t, "0", res.Data[3]["count(e.Year)"])
+
+	// joins without an equality are compared row by row
+	res = c.Run("SELECT 'a.Level', 'b.Level' FROM path:testdata/levels.csv AS a JOIN path:testdata/levels.csv AS b ON 'a.Level' < 'b.Level' ORDER BY 'a.Level', 'b.Level'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 6, len(res.Data))
+	assert.Equal(t, map[string]string{"a.Level": "Level 1", "b.Level": "Level 2"}, res.Data[0])
+
+	res = c.Run("SELECT 'a.Level', 'c.Description' FROM path:testdata/levels.csv AS a JOIN path:testdata/levels.csv AS b ON 'a.Level' = 'b.Level' LEFT JOIN path:testdata/levels.csv AS c ON 'b.Level' = 'c.Level' AND 'c.Level' != 'Level 1'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 4, len(res.Data))
+	assert.Equal(t, "", res.Data[0]["c.Description"])
+	assert.Equal(t, "Industry subdivision", res.Data[1]["c.Description"])
+
+	res = c.Run("SELECT * FROM path:testdata/levels.csv AS a JOIN path:testdata/levels.csv AS b ON 'a.Level' = 'b.Unknown'")
+	assert.NotNil(t, res.Error)
+}
+
+func TestGettingResultsWithJoinsOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	inMemory := New(WithMemoryLimit(0))
+	onDisk := New(WithTempDir(dir), WithMemoryLimit(64<<10))
+
+	statements := []string{
+		"SELECT * FROM path:testdata/levels.csv AS l JOIN path:testdata/example.csv AS e ON 'l.Level' = 'e.Industry_aggregation_NZSIOC' ORDER BY 'e.Year', 'e.Industry_code_NZSIOC', 'e.Variable_code'",
+		"SELECT * FROM path:testdata/levels.csv AS l LEFT JOIN path:testdata/example.csv AS e ON 'l.Level' = 'e.Industry_aggregation_NZSIOC' AND 'e.Year'::int = '2021' ORDER BY 'l.Level', 'e.Industry_code_NZSIOC', 'e.Variable_code'",
+		"SELECT * FROM path:testdata/levels.csv AS l RIGHT JOIN path:testdata/example.csv AS e ON 'l.Level' = 'e.Industry_aggregation_NZSIOC' ORDER BY 'e.Year', 'e.Industry_code_NZSIOC', 'e.Variable_code'",
+		"SELECT 'l.Level', COUNT(*) FROM path:testdata/levels.csv AS l JOIN path:testdata/example.csv AS e ON 'l.Level' = 'e.Industry_aggregation_NZSIOC' AND 'e.Year' IS NOT NULL GROUP BY 'l.Level' ORDER BY 'l.Level'",
+		"SELECT COUNT(*) FROM path:testdata/levels.csv AS l JOIN path:testdata/example.csv AS e ON 'l.Level' < 'e.Industry_aggregation_NZSIOC'",
+	}
+
+	for _, s := range statements {
+		expected := inMemory.Run(s)
+		res := onDisk.Run(s)
+
+		assert.Nil(t, expected.Error, s)
+		assert.Nil(t, res.Error, s)
+		assert.NotEqual(t, 0, len(res.Data), s)
+		assert.Equal(t, expected.Data, res.Data, s)
+	}
+
+	// temporary files are removed when the query is finished
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(files))
+}
+
+func TestGettingResultsWithAsofJoins(t *testing.T) {
+	c := New()
+
+	bids := func(res Data) []string {
+		values := make([]string, len(res.Data))
+		for i, r := range res.Data {
+			values[i] = r["t.id"] + ":" + r["q.bid"]
+		}
+
+		return values
+	}
+
+	res := c.Run("SELECT 't.id', 'q.bid' FROM path:testdata/trades.csv AS t ASOF JOIN path:testdata/quotes.csv AS q ON 't.sym' = 'q.sym' AND 't.ts'::timestamp >= 'q.ts'::timestamp")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"2:185.20", "3:370.00", "4:185.05", "5:370.50"}, bids(res))
+
+	res = c.Run("SELECT 't.id', 'q.bid' FROM path:testdata/trades.csv AS t ASOF LEFT JOIN path:testdata/quotes.csv AS q ON 'q.ts'::timestamp < 't.ts'::timestamp AND 'q.sym' = 't.sym'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"1:", "2:185.10", "3:370.00", "4:185.05", "5:370.00", "6:"}, bids(res))
+
+	res = c.Run("SELECT 't.id', 'q.bid' FROM path:testdata/trades.csv AS t ASOF JOIN path:testdata/quotes.csv AS q ON 't.sym' = 'q.sym' AND 't.ts'::timestamp <= 'q.ts'::timestamp")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"1:185.10", "2:185.20", "3:370.50", "5:370.50"}, bids(res))
+
+	res = c.Run("SELECT 't.id', 'q.bid' FROM path:testdata/trades.csv AS t ASOF JOIN path:testdata/quotes.csv AS q ON 't.sym' = 'q.sym' AND 't.ts'::timestamp > 'q.ts'::timestamp WHERE 'q.bid'::float > '200' ORDER BY 't.id' DESC")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"5:370.00", "3:370.00"}, bids(res))
+
+	// without columns compared with =, every row is matched with the nearest row of the whole file
+	res = c.Run("SELECT 't.id', 'q.bid' FROM path:testdata/trades.csv AS t ASOF JOIN path:testdata/quotes.csv AS q ON 't.ts'::timestamp >= 'q.ts'::timestamp")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"2:185.20", "3:185.20", "4:370.50", "5:370.50", "6:370.50"}, bids(res))
 }
 
 func TestGettingResultsWithDataConversion(t *testing.T) {
@@ -123,3 +884,230 @@ func TestParallelRun(t *testing.T) {
 		assert.Nil(t, res.Error)
 	}
 }
+
+func TestGettingResultsWithSetOperations(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT 'a.Level' FROM path:testdata/levels.csv AS a UNION ALL SELECT 'b.Level' FROM path:testdata/levels.csv AS b")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"Level"}, res.SelectedColumns)
+	assert.Equal(t, 8, len(res.Data))
+	assert.Equal(t, "Level 1", res.Data[4]["Level"])
+
+	res = c.Run("SELECT 'a.Level' FROM path:testdata/levels.csv AS a union SELECT 'b.Level' FROM path:testdata/levels.csv AS b")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 4, len(res.Data))
+
+	// rows of later queries are named by the columns of the first one
+	res = c.Run("SELECT 'a.Level' AS name FROM path:testdata/levels.csv AS a WHERE 'a.Level' = 'Level 1' UNION SELECT 'b.Description' FROM path:testdata/levels.csv AS b WHERE 'b.Level' = 'Level 2'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"name": "Level 1"}, {"name": "Industry subdivision"}}, res.Data)
+
+	res = c.Run("SELECT 'a.Level' FROM path:testdata/levels.csv AS a INTERSECT SELECT 'b.Level' FROM path:testdata/levels.csv AS b WHERE 'b.Level' IN ('Level 2', 'Level 5')")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"Level": "Level 2"}, {"Level": "Level 5"}}, res.Data)
+
+	res = c.Run("SELECT 'a.Level' FROM path:testdata/levels.csv AS a EXCEPT SELECT 'b.Level' FROM path:testdata/levels.csv AS b WHERE 'b.Level' IN ('Level 2', 'Level 5')")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"Level": "Level 1"}, {"Level": "Level 3"}}, res.Data)
+
+	// INTERSECT is applied before EXCEPT
+	res = c.Run("SELECT 'a.Level' FROM path:testdata/levels.csv AS a EXCEPT SELECT 'b.Level' FROM path:testdata/levels.csv AS b INTERSECT SELECT 'c.Level' FROM path:testdata/levels.csv AS c WHERE 'c.Level' = 'Level 3'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 3, len(res.Data))
+
+	res = c.Run("SELECT DISTINCT 'e.Industry_aggregation_NZSIOC' FROM path:testdata/example.csv AS e EXCEPT SELECT 'l.Level' FROM path:testdata/levels.csv AS l")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"Industry_aggregation_NZSIOC": "Level 4"}}, res.Data)
+
+	res = c.Run("SELECT * FROM path:testdata/levels.csv AS a UNION SELECT 'b.Level' FROM path:testdata/levels.csv AS b")
+	assert.NotNil(t, res.Error)
+	assert.True(t, errors.Is(res.Error, pkg.InvalidSetOperation))
+}
+
+func TestGettingResultsWithSubqueries(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' IN (SELECT 'l.Level' FROM path:testdata/levels.csv AS l WHERE 'l.Description' = 'Industry group')")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 18000, len(res.Data))
+
+	// there is no Level 4 in levels.csv
+	res = c.Run("SELECT 'e.Industry_aggregation_NZSIOC' FROM path:testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' NOT IN (SELECT 'l.Level' FROM path:testdata/levels.csv AS l)")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 5715, len(res.Data))
+	assert.Equal(t, "Level 4", res.Data[0]["Industry_aggregation_NZSIOC"])
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e WHERE EXISTS (SELECT * FROM path:testdata/levels.csv AS l WHERE 'l.Level' = 'e.Industry_aggregation_NZSIOC' AND 'l.Description' != 'Industry group')")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 18000, len(res.Data))
+
+	res = c.Run("SELECT 'e.Year' FROM path:testdata/example.csv AS e WHERE NOT EXISTS (SELECT 'l.Level' FROM path:testdata/levels.csv AS l WHERE 'e.Industry_aggregation_NZSIOC' = 'l.Level') AND 'e.Year'::int = '2021'")
+	assert.Nil(t, res.Error)
+	assert.True(t, len(res.Data) > 0 && len(res.Data) < 5715)
+
+	// the blocklist has a NULL, so values that are not in it could still be the NULL
+	res = c.Run("SELECT 'l.Level' FROM path:testdata/levels.csv AS l WHERE 'l.Level' IN (SELECT 'b.Level' FROM path:testdata/blocklist.csv AS b)")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"Level": "Level 2"}}, res.Data)
+
+	res = c.Run("SELECT 'l.Level' FROM path:testdata/levels.csv AS l WHERE 'l.Level' NOT IN (SELECT 'b.Level' FROM path:testdata/blocklist.csv AS b)")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 0, len(res.Data))
+
+	res = c.Run("SELECT 'l.Level' FROM path:testdata/levels.csv AS l WHERE 'l.Level' NOT IN (SELECT 'b.Level' FROM path:testdata/blocklist.csv AS b WHERE 'b.Level' IS NOT NULL) ORDER BY 'l.Level'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"Level": "Level 1"}, {"Level": "Level 3"}, {"Level": "Level 5"}}, res.Data)
+
+	// correlated subqueries can also be nested in other subqueries
+	res = c.Run("SELECT 'a.Level' FROM path:testdata/levels.csv AS a WHERE 'a.Level' IN (SELECT 'b.Level' FROM path:testdata/levels.csv AS b WHERE 'b.Description' = 'a.Description' AND NOT EXISTS (SELECT * FROM path:testdata/blocklist.csv AS c WHERE 'c.Level' = 'b.Level'))")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 3, len(res.Data))
+
+	res = c.Run("SELECT 'a.Level' FROM path:testdata/levels.csv AS a WHERE 'a.Level' IN (SELECT 'b.Level' FROM path:testdata/unknown.csv AS b)")
+	assert.NotNil(t, res.Error)
+
+	// columns that are not in the file of the subquery are not selected
+	res = c.Run("SELECT 't.id' FROM path:testdata/trades.csv AS t WHERE 't.sym' IN (SELECT 'b.sym' FROM path:testdata/blocklist.csv AS b)")
+	assert.NotNil(t, res.Error)
+	assert.True(t, errors.Is(res.Error, pkg.InvalidSubquery))
+
+	res = c.Run("SELECT 't.id' FROM path:testdata/trades.csv AS t WHERE EXISTS (SELECT 'b.Level' FROM path:testdata/blocklist.csv AS b WHERE 'b.nope' = 't.sym')")
+	assert.NotNil(t, res.Error)
+	assert.True(t, errors.Is(res.Error, pkg.InvalidSubquery))
+}
+
+func TestGettingResultsWithDerivedTables(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT * FROM (SELECT 'e.Industry_aggregation_NZSIOC' AS level, COUNT(*) AS total FROM path:testdata/example.csv AS e GROUP BY 'e.Industry_aggregation_NZSIOC') AS t WHERE 't.total'::int > '10000' ORDER BY 't.level'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"level", "total"}, res.SelectedColumns)
+	assert.Equal(t, []map[string]string{{"level": "Level 2", "total": "12969"}, {"level": "Level 3", "total": "18000"}}, res.Data)
+
+	// filter, then aggregate, then filter again
+	res = c.Run("WITH recent AS (SELECT 'e.Industry_aggregation_NZSIOC' AS level FROM path:testdata/example.csv AS e WHERE 'e.Year'::int = '2021'), counts AS (SELECT 'r.level', COUNT(*) AS total FROM recent AS r GROUP BY 'r.level') SELECT 'c.level', 'c.total' FROM counts AS c WHERE 'c.level' != 'Level 4' ORDER BY 'c.total'::int DESC LIMIT 1")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 1, len(res.Data))
+	assert.Equal(t, "Level 3", res.Data[0]["level"])
+
+	res = c.Run("WITH counts AS (SELECT 'e.Industry_aggregation_NZSIOC' AS level, COUNT(*) AS total FROM path:testdata/example.csv AS e GROUP BY 'e.Industry_aggregation_NZSIOC') SELECT 'c.level', 'l.Description' FROM counts AS c JOIN path:testdata/levels.csv AS l ON 'c.level' = 'l.Level' ORDER BY 'c.level'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 3, len(res.Data))
+	assert.Equal(t, map[string]string{"c.level": "Level 1", "l.Description": "Industry division"}, res.Data[0])
+
+	// a query of WITH can be used more than once and without an alias
+	res = c.Run("WITH blocked AS (SELECT 'b.Level' FROM path:testdata/blocklist.csv AS b WHERE 'b.Level' IS NOT NULL) SELECT 'l.Level' FROM path:testdata/levels.csv AS l JOIN blocked AS x ON 'l.Level' = 'x.Level' WHERE 'l.Level' IN (SELECT 'blocked.Level' FROM blocked)")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"l.Level": "Level 2"}}, res.Data)
+
+	// rows combined by set operators are ordered by the query around them
+	res = c.Run("SELECT * FROM (SELECT 'a.Level' FROM path:testdata/levels.csv AS a UNION SELECT 'b.Level' FROM path:testdata/blocklist.csv AS b WHERE 'b.Level' IS NOT NULL) AS u ORDER BY 'u.Level' DESC")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"Level": "Level 5"}, {"Level": "Level 3"}, {"Level": "Level 2"}, {"Level": "Level 1"}}, res.Data)
+
+	res = c.Run("SELECT * FROM (SELECT 'a.Level' FROM path:testdata/unknown.csv AS a) AS u")
+	assert.NotNil(t, res.Error)
+}
+
+func TestGettingResultsWithConditionalExpressions(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT 'l.Level', CASE WHEN 'l.Level' = 'Level 1' THEN 'top' WHEN 'l.Level' IN ('Level 2', 'Level 3') THEN 'middle' ELSE 'bottom' END AS bucket FROM path:testdata/levels.csv AS l")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"Level", "bucket"}, res.SelectedColumns)
+	assert.Equal(t, []map[string]string{
+		{"Level": "Level 1", "bucket": "top"},
+		{"Level": "Level 2", "bucket": "middle"},
+		{"Level": "Level 3", "bucket": "middle"},
+		{"Level": "Level 5", "bucket": "bottom"},
+	}, res.Data)
+
+	// NA is NULL, NULLIF turns Unknown into NULL too
+	res = c.Run("SELECT COALESCE('b.Level', 'b.Reason', 'none') AS level, NULLIF('b.Reason', 'Unknown') AS reason FROM path:testdata/blocklist.csv AS b")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"level": "Level 2", "reason": "Duplicated"}, {"level": "Unknown", "reason": ""}}, res.Data)
+
+	res = c.Run("SELECT 'e.Value' FROM path:testdata/example.csv AS e WHERE NULLIF('e.Value', 'C') IS NULL")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 2019, len(res.Data))
+
+	res = c.Run("SELECT 'l.Level' FROM path:testdata/levels.csv AS l WHERE CASE WHEN 'l.Level' = 'Level 3' THEN 'l.Description' ELSE 'none' END = 'Industry group'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"Level": "Level 3"}}, res.Data)
+
+	res = c.Run("SELECT 'l.Level' FROM path:testdata/levels.csv AS l ORDER BY CASE WHEN 'l.Level' = 'Level 3' THEN 0 ELSE 1 END, 'l.Level' DESC")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"Level": "Level 3"}, {"Level": "Level 5"}, {"Level": "Level 2"}, {"Level": "Level 1"}}, res.Data)
+
+	// columns of a joined file without a matching row are NULL
+	res = c.Run("SELECT 'l.Level', COALESCE('b.Reason', 'allowed') AS reason FROM path:testdata/levels.csv AS l LEFT JOIN path:testdata/blocklist.csv AS b ON 'l.Level' = 'b.Level' WHERE CASE WHEN 'b.Reason' IS NULL THEN 'l.Level' END != 'Level 5'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"l.Level": "Level 1", "reason": "allowed"}, {"l.Level": "Level 3", "reason": "allowed"}}, res.Data)
+
+	// without ELSE, CASE is NULL if no condition is true
+	res = c.Run("SELECT CASE WHEN 'l.Level' = 'Level 1' THEN 10 END * 2 AS doubled FROM path:testdata/levels.csv AS l LIMIT 2")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"doubled": "20"}, {"doubled": ""}}, res.Data)
+}
+
+func TestGettingResultsWithWindowFunctions(t *testing.T) {
+	c := New()
+
+	res := c.Run("SELECT 'q.sym', 'q.bid', ROW_NUMBER() OVER (PARTITION BY 'q.sym' ORDER BY 'q.bid'::float DESC) AS rn, RANK() OVER (ORDER BY 'q.sym'), DENSE_RANK() OVER (ORDER BY 'q.sym') AS dense FROM path:testdata/quotes.csv AS q")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []string{"sym", "bid", "rn", "RANK() OVER (ORDER BY 'q.sym')", "dense"}, res.SelectedColumns)
+	// rows keep the order in which they were read
+	assert.Equal(t, []map[string]string{
+		{"sym": "AAPL", "bid": "185.10", "rn": "2", "RANK() OVER (ORDER BY 'q.sym')": "1", "dense": "1"},
+		{"sym": "AAPL", "bid": "185.20", "rn": "1", "RANK() OVER (ORDER BY 'q.sym')": "1", "dense": "1"},
+		{"sym": "MSFT", "bid": "370.00", "rn": "2", "RANK() OVER (ORDER BY 'q.sym')": "4", "dense": "2"},
+		{"sym": "AAPL", "bid": "185.05", "rn": "3", "RANK() OVER (ORDER BY 'q.sym')": "1", "dense": "1"},
+		{"sym": "MSFT", "bid": "370.50", "rn": "1", "RANK() OVER (ORDER BY 'q.sym')": "4", "dense": "2"},
+	}, res.Data)
+
+	// the highest 2 bids of every symbol
+	res = c.Run("SELECT 't.sym', 't.bid' FROM (SELECT 'q.sym', 'q.bid', ROW_NUMBER() OVER (PARTITION BY 'q.sym' ORDER BY 'q.bid'::float DESC) AS rn FROM path:testdata/quotes.csv AS q) AS t WHERE 't.rn'::int <= '2' ORDER BY 't.sym', 't.bid'::float DESC")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{
+		{"sym": "AAPL", "bid": "185.20"},
+		{"sym": "AAPL", "bid": "185.10"},
+		{"sym": "MSFT", "bid": "370.50"},
+		{"sym": "MSFT", "bid": "370.00"},
+	}, res.Data)
+
+	// the change from the previous bid, the first bid of a symbol does not have one
+	res = c.Run("SELECT 'd.sym', 'd.bid'::decimal - 'd.prev'::decimal AS delta FROM (SELECT 'q.sym', 'q.bid', LAG('q.bid') OVER (PARTITION BY 'q.sym' ORDER BY 'q.ts'::timestamp) AS prev FROM path:testdata/quotes.csv AS q) AS d")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{
+		{"sym": "AAPL", "delta": ""},
+		{"sym": "AAPL", "delta": "0.10"},
+		{"sym": "MSFT", "delta": ""},
+		{"sym": "AAPL", "delta": "-0.15"},
+		{"sym": "MSFT", "delta": "0.50"},
+	}, res.Data)
+
+	res = c.Run("SELECT 'q.sym', SUM('q.bid'::decimal) OVER (PARTITION BY 'q.sym' ORDER BY 'q.ts') AS running, SUM('q.bid'::decimal) OVER (PARTITION BY 'q.sym') AS total, LEAD('q.sym', 2, 'none') OVER () AS after FROM path:testdata/quotes.csv AS q ORDER BY 'q.sym', 'q.ts'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{
+		{"sym": "AAPL", "running": "185.10", "total": "555.35", "after": "MSFT"},
+		{"sym": "AAPL", "running": "370.30", "total": "555.35", "after": "AAPL"},
+		{"sym": "AAPL", "running": "555.35", "total": "555.35", "after": "none"},
+		{"sym": "MSFT", "running": "370.00", "total": "740.50", "after": "MSFT"},
+		{"sym": "MSFT", "running": "740.50", "total": "740.50", "after": "none"},
+	}, res.Data)
+
+	res = c.Run("SELECT 'q.sym', 't.id', ROW_NUMBER() OVER (PARTITION BY 'q.sym' ORDER BY 't.id'::int DESC, 'q.ts') AS rn FROM path:testdata/quotes.csv AS q INNER JOIN path:testdata/trades.csv AS t ON 'q.sym' = 't.sym' WHERE 'q.sym' = 'MSFT'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{
+		{"q.sym": "MSFT", "t.id": "3", "rn": "3"},
+		{"q.sym": "MSFT", "t.id": "5", "rn": "1"},
+		{"q.sym": "MSFT", "t.id": "3", "rn": "4"},
+		{"q.sym": "MSFT", "t.id": "5", "rn": "2"},
+	}, res.Data)
+
+	// a running sum of integers that does not fit into 64 bits is an error
+	res = c.Run("SELECT 'n.id', SUM('n.total'::int) OVER (ORDER BY 'n.id'::int) AS running FROM path:testdata/numbers.csv AS n")
+	assert.NotNil(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "Integer overflow")
+}
@@ -6,6 +6,7 @@ require (
 	github.com/jedib0t/go-pretty/v6 v6.5.8
 	github.com/spf13/cobra v1.8.0
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/text v0.22.0
 )
 
 require (
@@ -0,0 +1,106 @@
+package comparison
+
+import (
+	"github.com/MarioLegenda/cig/internal/syntax/collations"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+)
+
+// CompareStrings compares two strings with the collation. It returns -1, 0 or 1. An empty
+// collation is binary.
+func CompareStrings(collation, a, b string) int {
+	switch collation {
+	case collations.NoCase, collations.Unicode:
+		return strings.Compare(collationKey(collation, a), collationKey(collation, b))
+	case collations.Natural:
+		return compareNatural(a, b)
+	}
+
+	return strings.Compare(a, b)
+}
+
+// collationKey returns the value two strings are equal by under the collation. It is used
+// to hash the values of IN lists.
+func collationKey(collation, value string) string {
+	switch collation {
+	case collations.NoCase:
+		return toLowerASCII(value)
+	case collations.Unicode:
+		// canonical caseless matching, see chapter 3.13 of the Unicode standard
+		return norm.NFC.String(cases.Fold().String(norm.NFD.String(value)))
+	}
+
+	return value
+}
+
+func toLowerASCII(value string) string {
+	b := []byte(value)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+
+	return string(b)
+}
+
+// compareNatural compares runs of digits by their numeric value and everything else byte
+// by byte. Strings that differ only in leading zeros, like file2 and file02, are ordered
+// byte by byte so that only equal strings are equal.
+func compareNatural(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return compareOrdered(a[i], b[j])
+			}
+
+			i++
+			j++
+
+			continue
+		}
+
+		ai, bj := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+
+		x := strings.TrimLeft(a[ai:i], "0")
+		y := strings.TrimLeft(b[bj:j], "0")
+		if len(x) != len(y) {
+			return compareOrdered(len(x), len(y))
+		}
+
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+
+	if c := compareOrdered(len(a)-i, len(b)-j); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a, b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func compareOrdered[T byte | int | int64 | float64 | string](a, b T) int {
+	if a < b {
+		return -1
+	}
+
+	if a > b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,198 @@
+package comparison
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/functions"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ScalarFunction computes the value of a scalar function from the values of its arguments.
+type ScalarFunction func(arguments []string) (string, error)
+
+var scalarFunctions = map[string]ScalarFunction{
+	functions.Upper: func(arguments []string) (string, error) {
+		return strings.ToUpper(arguments[0]), nil
+	},
+	functions.Lower: func(arguments []string) (string, error) {
+		return strings.ToLower(arguments[0]), nil
+	},
+	functions.Trim: func(arguments []string) (string, error) {
+		if len(arguments) == 2 {
+			return strings.Trim(arguments[0], arguments[1]), nil
+		}
+
+		return strings.TrimSpace(arguments[0]), nil
+	},
+	functions.Substr: substr,
+	functions.Concat: func(arguments []string) (string, error) {
+		return strings.Join(arguments, ""), nil
+	},
+	functions.Length: func(arguments []string) (string, error) {
+		return strconv.Itoa(utf8.RuneCountInString(arguments[0])), nil
+	},
+	functions.Replace: func(arguments []string) (string, error) {
+		if arguments[1] == "" {
+			return arguments[0], nil
+		}
+
+		return strings.ReplaceAll(arguments[0], arguments[1], arguments[2]), nil
+	},
+	functions.Extract:   extract,
+	functions.DateTrunc: dateTrunc,
+	functions.Now: func(arguments []string) (string, error) {
+		return dataTypes.FormatTime(dataTypes.Timestamp, time.Now()), nil
+	},
+	functions.CurrentDate: func(arguments []string) (string, error) {
+		return dataTypes.FormatTime(dataTypes.Date, time.Now()), nil
+	},
+}
+
+// CallFunction calls the scalar function with the values of its arguments. isNull tells which
+// of the arguments are NULL. A NULL argument makes the result NULL, except for CONCAT
+// which uses an empty string instead.
+func CallFunction(name string, arguments []string, isNull []bool) (string, bool, error) {
+	fn, ok := scalarFunctions[name]
+	if !ok {
+		return "", false, fmt.Errorf("Internal error. Unknown function %s", name)
+	}
+
+	values := make([]string, len(arguments))
+	for i, a := range arguments {
+		if isNull[i] {
+			if name != functions.Concat {
+				return "", true, nil
+			}
+
+			a = ""
+		}
+
+		values[i] = a
+	}
+
+	value, err := fn(values)
+
+	return value, false, err
+}
+
+// substr returns the characters starting at a 1 based position. Positions before the first
+// character count towards the length, as in SQL.
+func substr(arguments []string) (string, error) {
+	runes := []rune(arguments[0])
+
+	start, err := strconv.Atoi(arguments[1])
+	if err != nil {
+		return "", fmt.Errorf("Start %s of SUBSTR is not a valid integer", arguments[1])
+	}
+
+	end := len(runes) + 1
+	if len(arguments) == 3 {
+		length, err := strconv.Atoi(arguments[2])
+		if err != nil {
+			return "", fmt.Errorf("Length %s of SUBSTR is not a valid integer", arguments[2])
+		}
+
+		if length < 0 {
+			return "", fmt.Errorf("Length of SUBSTR cannot be negative, got %d", length)
+		}
+
+		if start+length < end {
+			end = start + length
+		}
+	}
+
+	if start < 1 {
+		start = 1
+	}
+
+	if start >= end {
+		return "", nil
+	}
+
+	return string(runes[start-1 : end-1]), nil
+}
+
+// parseTemporal parses an argument of a date and time function. Dates and times are
+// ISO-8601 once they get here.
+func parseTemporal(value string) (time.Time, error) {
+	if t, err := dataTypes.ParseTime(dataTypes.Timestamp, value); err == nil {
+		return t, nil
+	}
+
+	return dataTypes.ParseTime(dataTypes.Time, value)
+}
+
+// extract returns a part of a date or a time. Weeks are ISO weeks and days of
+// the week start with 0 on Sunday.
+func extract(arguments []string) (string, error) {
+	t, err := parseTemporal(arguments[1])
+	if err != nil {
+		return "", err
+	}
+
+	var part int64
+	switch arguments[0] {
+	case "year":
+		part = int64(t.Year())
+	case "quarter":
+		part = int64(t.Month()-1)/3 + 1
+	case "month":
+		part = int64(t.Month())
+	case "week":
+		_, week := t.ISOWeek()
+		part = int64(week)
+	case "day":
+		part = int64(t.Day())
+	case "dow":
+		part = int64(t.Weekday())
+	case "doy":
+		part = int64(t.YearDay())
+	case "hour":
+		part = int64(t.Hour())
+	case "minute":
+		part = int64(t.Minute())
+	case "second":
+		part = int64(t.Second())
+	case "epoch":
+		part = t.Unix()
+	default:
+		return "", fmt.Errorf("Internal error. Unknown EXTRACT field %s", arguments[0])
+	}
+
+	return strconv.FormatInt(part, 10), nil
+}
+
+// dateTrunc sets everything smaller than the given precision to its start. Weeks start on Monday.
+func dateTrunc(arguments []string) (string, error) {
+	t, err := parseTemporal(arguments[1])
+	if err != nil {
+		return "", err
+	}
+
+	year, month, day := t.Date()
+	switch arguments[0] {
+	case "year":
+		t = time.Date(year, time.January, 1, 0, 0, 0, 0, t.Location())
+	case "quarter":
+		t = time.Date(year, (month-1)/3*3+1, 1, 0, 0, 0, 0, t.Location())
+	case "month":
+		t = time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	case "week":
+		t = time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	case "day":
+		t = time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	case "hour":
+		t = time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location())
+	case "minute":
+		t = time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, t.Location())
+	case "second":
+		t = time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+	default:
+		return "", fmt.Errorf("Internal error. Unknown DATE_TRUNC precision %s", arguments[0])
+	}
+
+	return dataTypes.FormatTime(dataTypes.Timestamp, t), nil
+}
@@ -0,0 +1,118 @@
+package comparison
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"strconv"
+)
+
+type listProcessable struct {
+	values    map[string]struct{}
+	dataType  string
+	collation string
+	negate    bool
+}
+
+// Process looks up the incoming value in the hashed list. The incoming value
+// is normalized the same way the list values are so that, for example, '007' and '7'
+// are equal when compared as ::int.
+func (p listProcessable) Process(incomingValue string) (bool, error) {
+	key, err := normalize(p.dataType, p.collation, incomingValue)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := p.values[key]
+
+	return ok != p.negate, nil
+}
+
+// NewListProcessable hashes the list values once so that IN and NOT IN are a single
+// lookup per row.
+func NewListProcessable(conditionValues []string, op, dataType, collation string) (Processable, error) {
+	if op != operators.InOperator && op != operators.NotInOperator {
+		return nil, fmt.Errorf("Internal error. Operator %s is not a list operator", op)
+	}
+
+	values := make(map[string]struct{}, len(conditionValues))
+	for _, v := range conditionValues {
+		key, err := normalizeQueryValue(dataType, collation, v)
+		if err != nil {
+			return nil, err
+		}
+
+		values[key] = struct{}{}
+	}
+
+	return listProcessable{
+		values:    values,
+		dataType:  dataType,
+		collation: collation,
+		negate:    op == operators.NotInOperator,
+	}, nil
+}
+
+// normalizeQueryValue normalizes a value written in the query. Dates and times can be
+// written either in the layout hint or in ISO-8601.
+func normalizeQueryValue(dt, collation, value string) (string, error) {
+	if collation == "" && dataTypes.IsTemporal(dt) {
+		return dataTypes.SortableQueryTime(dt, value)
+	}
+
+	return normalize(dt, collation, value)
+}
+
+// HashKey is the key of a value in hash tables, values that are equal when compared as
+// dataType with the collation have the same key
+func HashKey(dataType, collation, value string) (string, error) {
+	return normalize(dataType, collation, value)
+}
+
+func normalize(dt, collation, value string) (string, error) {
+	if collation != "" {
+		return collationKey(collation, value), nil
+	}
+
+	if dt == dataTypes.Int {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.FormatInt(v, 10), nil
+	}
+
+	if dt == dataTypes.Float {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+
+	if dataTypes.IsTemporal(dt) {
+		return dataTypes.SortableTime(dt, value)
+	}
+
+	if dataTypes.Base(dt) == dataTypes.Decimal {
+		v, _, err := dataTypes.ParseDecimal(dt, value)
+		if err != nil {
+			return "", err
+		}
+
+		return v.RatString(), nil
+	}
+
+	if dt == dataTypes.Bool {
+		v, err := dataTypes.ParseBool(value)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.FormatBool(v), nil
+	}
+
+	return value, nil
+}
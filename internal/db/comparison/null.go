@@ -0,0 +1,27 @@
+package comparison
+
+// DefaultNullMarkers are the cell values that are NULL if nothing else is configured.
+var DefaultNullMarkers = []string{"", "NULL", "NA", "-"}
+
+// Missing is the value of the columns of a joined file that has no matching row, for
+// example of the right file of a LEFT JOIN. It is NULL whatever the NULL markers are.
+const Missing = "\x00"
+
+// Nulls holds the cell values that are interpreted as NULL.
+type Nulls map[string]struct{}
+
+func (n Nulls) IsNull(value string) bool {
+	_, ok := n[value]
+
+	return ok
+}
+
+func NewNulls(markers []string) Nulls {
+	nulls := make(Nulls, len(markers)+1)
+	for _, m := range markers {
+		nulls[m] = struct{}{}
+	}
+	nulls[Missing] = struct{}{}
+
+	return nulls
+}
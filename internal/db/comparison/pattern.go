@@ -0,0 +1,63 @@
+package comparison
+
+import (
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"regexp"
+	"strings"
+)
+
+// NewPatternProcessable compiles a LIKE pattern into a regular expression once per query.
+// % matches any sequence of characters, _ matches a single character and the escape
+// character makes the next character match literally.
+func NewPatternProcessable(pattern, escape, op string) (Processable, error) {
+	var b strings.Builder
+	b.WriteString("(?s)")
+	if op == operators.ILikeOperator || op == operators.NotILikeOperator {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+
+	escaped := false
+	for _, r := range pattern {
+		c := string(r)
+
+		if escaped {
+			b.WriteString(regexp.QuoteMeta(c))
+			escaped = false
+			continue
+		}
+
+		switch {
+		case escape != "" && c == escape:
+			escaped = true
+		case c == "%":
+			b.WriteString(".*")
+		case c == "_":
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(c))
+		}
+	}
+
+	b.WriteString("$")
+
+	compiled, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return processable{
+		conditionValue: pattern,
+		op:             op,
+		pattern:        compiled,
+	}, nil
+}
+
+// NewRegexProcessable uses the regular expression that was already compiled during validation.
+func NewRegexProcessable(regex *regexp.Regexp, op string) Processable {
+	return processable{
+		conditionValue: regex.String(),
+		op:             op,
+		pattern:        regex,
+	}
+}
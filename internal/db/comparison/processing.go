@@ -4,64 +4,92 @@ import (
 	"fmt"
 	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
 	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"regexp"
 	"strconv"
 )
 
-type Processor interface {
-	Process() (bool, error)
-}
-
-type processor struct {
-	processables []Processor
+// Processable compares a value of a row against the condition value(s) it was created with.
+// Processables are created once per query and reused for every row.
+type Processable interface {
+	Process(incomingValue string) (bool, error)
 }
 
 type processable struct {
-	incomingValue  string
 	conditionValue string
 	op             string
 	dataType       string
+	collation      string
+	pattern        *regexp.Regexp
 }
 
-func (p processable) Process() (bool, error) {
-	if p.op == operators.EqualOperator {
-		return compareEqual(p.dataType, p.incomingValue, p.conditionValue)
-	} else if p.op == operators.UnEqualOperator {
-		return compareUnequal(p.dataType, p.incomingValue, p.conditionValue)
-	} else if p.op == operators.LessThanOperator {
-		return compareLessThan(p.dataType, p.incomingValue, p.conditionValue)
-	} else if p.op == operators.LessThanOrEqualOperator {
-		return compareLessThanOrEqual(p.dataType, p.incomingValue, p.conditionValue)
-	} else if p.op == operators.GreaterThanOperator {
-		return compareGreaterThan(p.dataType, p.incomingValue, p.conditionValue)
-	} else if p.op == operators.GreaterThanOrEqualOperator {
-		return compareGreaterThanOrEqual(p.dataType, p.incomingValue, p.conditionValue)
-	}
-
-	return false, fmt.Errorf("Internal error. Could not match condition operator %s with any of valid operators", p.op)
+func (p processable) Process(incomingValue string) (bool, error) {
+	if p.op == operators.LikeOperator || p.op == operators.ILikeOperator {
+		return p.pattern.MatchString(incomingValue), nil
+	} else if p.op == operators.NotLikeOperator || p.op == operators.NotILikeOperator {
+		return !p.pattern.MatchString(incomingValue), nil
+	} else if p.op == operators.RegexMatchOperator || p.op == operators.RegexIMatchOperator {
+		return p.pattern.MatchString(incomingValue), nil
+	} else if p.op == operators.NotRegexMatchOperator || p.op == operators.NotRegexIMatchOperator {
+		return !p.pattern.MatchString(incomingValue), nil
+	}
+
+	return Compare(incomingValue, p.conditionValue, p.op, p.dataType, p.collation)
 }
 
-func (p processor) Process() (bool, error) {
-	for _, k := range p.processables {
-		ok, _ := k.Process()
-		if !ok {
-			return false, nil
-		}
+// Compare compares two values with a comparison operator like = or <. It is used when the
+// compared value is different for every row, for example when two columns are compared.
+func Compare(incomingValue, conditionValue, op, dataType, collation string) (bool, error) {
+	if collation != "" {
+		return compareCollated(op, collation, incomingValue, conditionValue)
 	}
 
-	return true, nil
-}
+	if op == operators.EqualOperator {
+		return compareEqual(dataType, incomingValue, conditionValue)
+	} else if op == operators.UnEqualOperator {
+		return compareUnequal(dataType, incomingValue, conditionValue)
+	} else if op == operators.LessThanOperator {
+		return compareLessThan(dataType, incomingValue, conditionValue)
+	} else if op == operators.LessThanOrEqualOperator {
+		return compareLessThanOrEqual(dataType, incomingValue, conditionValue)
+	} else if op == operators.GreaterThanOperator {
+		return compareGreaterThan(dataType, incomingValue, conditionValue)
+	} else if op == operators.GreaterThanOrEqualOperator {
+		return compareGreaterThanOrEqual(dataType, incomingValue, conditionValue)
+	}
 
-func NewProcessor(processables []Processor) Processor {
-	return processor{processables: processables}
+	return false, fmt.Errorf("Internal error. Could not match condition operator %s with any of valid operators", op)
 }
 
-func NewProcessable(conditionValue, incomingValue, op, dataType string) Processor {
+// NewProcessable creates a comparison of the value with the operator. Strings with
+// a collation are compared by the collation.
+func NewProcessable(conditionValue, op, dataType, collation string) Processable {
 	return processable{
-		incomingValue:  incomingValue,
 		conditionValue: conditionValue,
 		op:             op,
 		dataType:       dataType,
+		collation:      collation,
+	}
+}
+
+func compareCollated(op, collation, incomingValue, conditionValue string) (bool, error) {
+	c := CompareStrings(collation, incomingValue, conditionValue)
+
+	switch op {
+	case operators.EqualOperator:
+		return c == 0, nil
+	case operators.UnEqualOperator:
+		return c != 0, nil
+	case operators.LessThanOperator:
+		return c < 0, nil
+	case operators.LessThanOrEqualOperator:
+		return c <= 0, nil
+	case operators.GreaterThanOperator:
+		return c > 0, nil
+	case operators.GreaterThanOrEqualOperator:
+		return c >= 0, nil
 	}
+
+	return false, fmt.Errorf("Internal error. Operator %s cannot be used with a collation", op)
 }
 
 /*
@@ -71,6 +99,24 @@ Converting to interface{} will degrade performance (not that big of an issue??).
 This is more maintainable.
 */
 func compareEqual(dt, incomingValue, conditionValue string) (bool, error) {
+	if dataTypes.Base(dt) == dataTypes.Decimal {
+		c, err := compareDecimals(dt, incomingValue, conditionValue)
+
+		return c == 0, err
+	}
+
+	if dt == dataTypes.Bool {
+		c, err := CompareBools(incomingValue, conditionValue)
+
+		return c == 0, err
+	}
+
+	if dataTypes.IsTemporal(dt) {
+		c, err := compareTimes(dt, incomingValue, conditionValue)
+
+		return c == 0, err
+	}
+
 	if dt == dataTypes.Int {
 		a, err := strconv.ParseInt(incomingValue, 10, 64)
 		if err != nil {
@@ -103,6 +149,24 @@ func compareEqual(dt, incomingValue, conditionValue string) (bool, error) {
 }
 
 func compareUnequal(dt, incomingValue, conditionValue string) (bool, error) {
+	if dataTypes.Base(dt) == dataTypes.Decimal {
+		c, err := compareDecimals(dt, incomingValue, conditionValue)
+
+		return c != 0, err
+	}
+
+	if dt == dataTypes.Bool {
+		c, err := CompareBools(incomingValue, conditionValue)
+
+		return c != 0, err
+	}
+
+	if dataTypes.IsTemporal(dt) {
+		c, err := compareTimes(dt, incomingValue, conditionValue)
+
+		return c != 0, err
+	}
+
 	if dt == dataTypes.Int {
 		a, err := strconv.ParseInt(incomingValue, 10, 64)
 		if err != nil {
@@ -135,6 +199,24 @@ func compareUnequal(dt, incomingValue, conditionValue string) (bool, error) {
 }
 
 func compareLessThan(dt, incomingValue, conditionValue string) (bool, error) {
+	if dataTypes.Base(dt) == dataTypes.Decimal {
+		c, err := compareDecimals(dt, incomingValue, conditionValue)
+
+		return c < 0, err
+	}
+
+	if dt == dataTypes.Bool {
+		c, err := CompareBools(incomingValue, conditionValue)
+
+		return c < 0, err
+	}
+
+	if dataTypes.IsTemporal(dt) {
+		c, err := compareTimes(dt, incomingValue, conditionValue)
+
+		return c < 0, err
+	}
+
 	if dt == dataTypes.Int {
 		a, err := strconv.ParseInt(incomingValue, 10, 64)
 		if err != nil {
@@ -167,6 +249,24 @@ func compareLessThan(dt, incomingValue, conditionValue string) (bool, error) {
 }
 
 func compareLessThanOrEqual(dt, incomingValue, conditionValue string) (bool, error) {
+	if dataTypes.Base(dt) == dataTypes.Decimal {
+		c, err := compareDecimals(dt, incomingValue, conditionValue)
+
+		return c <= 0, err
+	}
+
+	if dt == dataTypes.Bool {
+		c, err := CompareBools(incomingValue, conditionValue)
+
+		return c <= 0, err
+	}
+
+	if dataTypes.IsTemporal(dt) {
+		c, err := compareTimes(dt, incomingValue, conditionValue)
+
+		return c <= 0, err
+	}
+
 	if dt == dataTypes.Int {
 		a, err := strconv.ParseInt(incomingValue, 10, 64)
 		if err != nil {
@@ -199,6 +299,24 @@ func compareLessThanOrEqual(dt, incomingValue, conditionValue string) (bool, err
 }
 
 func compareGreaterThan(dt, incomingValue, conditionValue string) (bool, error) {
+	if dataTypes.Base(dt) == dataTypes.Decimal {
+		c, err := compareDecimals(dt, incomingValue, conditionValue)
+
+		return c > 0, err
+	}
+
+	if dt == dataTypes.Bool {
+		c, err := CompareBools(incomingValue, conditionValue)
+
+		return c > 0, err
+	}
+
+	if dataTypes.IsTemporal(dt) {
+		c, err := compareTimes(dt, incomingValue, conditionValue)
+
+		return c > 0, err
+	}
+
 	if dt == dataTypes.Int {
 		a, err := strconv.ParseInt(incomingValue, 10, 64)
 		if err != nil {
@@ -231,6 +349,24 @@ func compareGreaterThan(dt, incomingValue, conditionValue string) (bool, error)
 }
 
 func compareGreaterThanOrEqual(dt, incomingValue, conditionValue string) (bool, error) {
+	if dataTypes.Base(dt) == dataTypes.Decimal {
+		c, err := compareDecimals(dt, incomingValue, conditionValue)
+
+		return c >= 0, err
+	}
+
+	if dt == dataTypes.Bool {
+		c, err := CompareBools(incomingValue, conditionValue)
+
+		return c >= 0, err
+	}
+
+	if dataTypes.IsTemporal(dt) {
+		c, err := compareTimes(dt, incomingValue, conditionValue)
+
+		return c >= 0, err
+	}
+
 	if dt == dataTypes.Int {
 		a, err := strconv.ParseInt(incomingValue, 10, 64)
 		if err != nil {
@@ -261,3 +397,151 @@ func compareGreaterThanOrEqual(dt, incomingValue, conditionValue string) (bool,
 
 	return incomingValue >= conditionValue, nil
 }
+
+// compareTimes compares two values of a temporal data type chronologically. The value of
+// the condition is written in the query or computed, so it can also be in ISO-8601.
+func compareTimes(dt, incomingValue, conditionValue string) (int, error) {
+	a, err := dataTypes.ParseTime(dt, incomingValue)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := dataTypes.ParseQueryTime(dt, conditionValue)
+	if err != nil {
+		return 0, err
+	}
+
+	return a.Compare(b), nil
+}
+
+// compareDecimals compares two decimals exactly
+func compareDecimals(dt, incomingValue, conditionValue string) (int, error) {
+	a, _, err := dataTypes.ParseDecimal(dt, incomingValue)
+	if err != nil {
+		return 0, err
+	}
+
+	b, _, err := dataTypes.ParseDecimal(dt, conditionValue)
+	if err != nil {
+		return 0, err
+	}
+
+	return a.Cmp(b), nil
+}
+
+// CompareBools compares two bools, false is less than true
+func CompareBools(incomingValue, conditionValue string) (int, error) {
+	a, err := dataTypes.ParseBool(incomingValue)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := dataTypes.ParseBool(conditionValue)
+	if err != nil {
+		return 0, err
+	}
+
+	if a == b {
+		return 0, nil
+	}
+
+	if b {
+		return -1, nil
+	}
+
+	return 1, nil
+}
+
+// SortDataType is the data type by which all values of a column are compared when they are
+// sorted. Comparing every two values by what they can be converted to is not an order, "2" < "10"
+// as numbers, "10" < "1a" and "1a" < "2" as strings. Values are compared by the data type of the
+// column only if all of them are valid values of it, as numbers only if all of them are numbers
+// and as strings otherwise.
+func SortDataType(dataType string, values []string) string {
+	if dataTypes.Base(dataType) == dataTypes.Decimal || dataType == dataTypes.Bool {
+		valid := true
+		for _, v := range values {
+			if dataType == dataTypes.Bool {
+				_, err := dataTypes.ParseBool(v)
+				valid = valid && err == nil
+			} else {
+				_, _, err := dataTypes.ParseDecimal(dataType, v)
+				valid = valid && err == nil
+			}
+		}
+
+		if valid {
+			return dataType
+		}
+	}
+
+	numeric := dataTypes.Int
+	for _, v := range values {
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			continue
+		}
+
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return dataTypes.String
+		}
+
+		numeric = dataTypes.Float
+	}
+
+	return numeric
+}
+
+// CompareValues returns -1 if v1 is less than v2, 1 if it is greater and 0 if they are equal.
+// Values that cannot be converted to the data type are compared as numbers if they are numbers,
+// otherwise as strings. Strings are always compared as strings.
+func CompareValues(dataType string, collation string, v1 string, v2 string) int {
+	if collation != "" {
+		return CompareStrings(collation, v1, v2)
+	}
+
+	if dataType == dataTypes.String {
+		return compareOrdered(v1, v2)
+	}
+
+	if dataType == dataTypes.Float {
+		v1float, v1FloatErr := strconv.ParseFloat(v1, 64)
+		v2float, v2FloatErr := strconv.ParseFloat(v2, 64)
+
+		if v1FloatErr == nil && v2FloatErr == nil {
+			return compareOrdered(v1float, v2float)
+		}
+	}
+
+	// decimals are compared exactly, money must not go through a float
+	if dataTypes.Base(dataType) == dataTypes.Decimal {
+		d1, _, d1Err := dataTypes.ParseDecimal(dataType, v1)
+		d2, _, d2Err := dataTypes.ParseDecimal(dataType, v2)
+
+		if d1Err == nil && d2Err == nil {
+			return d1.Cmp(d2)
+		}
+	}
+
+	if dataType == dataTypes.Bool {
+		c, err := CompareBools(v1, v2)
+		if err == nil {
+			return c
+		}
+	}
+
+	v1int, v1IntErr := strconv.ParseInt(v1, 10, 64)
+	v2int, v2IntErr := strconv.ParseInt(v2, 10, 64)
+
+	if v1IntErr == nil && v2IntErr == nil {
+		return compareOrdered(v1int, v2int)
+	}
+
+	v1float, v1FloatErr := strconv.ParseFloat(v1, 64)
+	v2float, v2FloatErr := strconv.ParseFloat(v2, 64)
+
+	if v1FloatErr == nil && v2FloatErr == nil {
+		return compareOrdered(v1float, v2float)
+	}
+
+	return compareOrdered(v1, v2)
+}
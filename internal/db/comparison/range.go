@@ -0,0 +1,156 @@
+package comparison
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+type rangeProcessable struct {
+	dataType  string
+	collation string
+	negate    bool
+
+	low  string
+	high string
+
+	lowInt  int64
+	highInt int64
+
+	lowFloat  float64
+	highFloat float64
+
+	lowTime  time.Time
+	highTime time.Time
+
+	lowDecimal  *big.Rat
+	highDecimal *big.Rat
+}
+
+// Process parses the incoming value once and checks both bounds. Bounds are inclusive.
+func (p rangeProcessable) Process(incomingValue string) (bool, error) {
+	var inRange bool
+	if p.collation != "" {
+		inRange = CompareStrings(p.collation, incomingValue, p.low) >= 0 && CompareStrings(p.collation, incomingValue, p.high) <= 0
+	} else if p.dataType == dataTypes.Int {
+		v, err := strconv.ParseInt(incomingValue, 10, 64)
+		if err != nil {
+			return false, err
+		}
+
+		inRange = v >= p.lowInt && v <= p.highInt
+	} else if p.dataType == dataTypes.Float {
+		v, err := strconv.ParseFloat(incomingValue, 64)
+		if err != nil {
+			return false, err
+		}
+
+		inRange = v >= p.lowFloat && v <= p.highFloat
+	} else if dataTypes.Base(p.dataType) == dataTypes.Decimal {
+		v, _, err := dataTypes.ParseDecimal(p.dataType, incomingValue)
+		if err != nil {
+			return false, err
+		}
+
+		inRange = v.Cmp(p.lowDecimal) >= 0 && v.Cmp(p.highDecimal) <= 0
+	} else if p.dataType == dataTypes.Bool {
+		low, err := CompareBools(incomingValue, p.low)
+		if err != nil {
+			return false, err
+		}
+
+		high, err := CompareBools(incomingValue, p.high)
+		if err != nil {
+			return false, err
+		}
+
+		inRange = low >= 0 && high <= 0
+	} else if dataTypes.IsTemporal(p.dataType) {
+		v, err := dataTypes.ParseTime(p.dataType, incomingValue)
+		if err != nil {
+			return false, err
+		}
+
+		inRange = !v.Before(p.lowTime) && !v.After(p.highTime)
+	} else {
+		inRange = incomingValue >= p.low && incomingValue <= p.high
+	}
+
+	return inRange != p.negate, nil
+}
+
+// NewRangeProcessable parses the bounds of BETWEEN once per query.
+func NewRangeProcessable(low, high, op, dataType, collation string) (Processable, error) {
+	if op != operators.BetweenOperator && op != operators.NotBetweenOperator {
+		return nil, fmt.Errorf("Internal error. Operator %s is not a range operator", op)
+	}
+
+	p := rangeProcessable{
+		dataType:  dataType,
+		collation: collation,
+		negate:    op == operators.NotBetweenOperator,
+		low:       low,
+		high:      high,
+	}
+
+	if dataType == dataTypes.Int {
+		l, err := strconv.ParseInt(low, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		h, err := strconv.ParseInt(high, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		p.lowInt, p.highInt = l, h
+	}
+
+	if dataType == dataTypes.Float {
+		l, err := strconv.ParseFloat(low, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		h, err := strconv.ParseFloat(high, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		p.lowFloat, p.highFloat = l, h
+	}
+
+	if dataTypes.Base(dataType) == dataTypes.Decimal {
+		l, _, err := dataTypes.ParseDecimal(dataType, low)
+		if err != nil {
+			return nil, err
+		}
+
+		h, _, err := dataTypes.ParseDecimal(dataType, high)
+		if err != nil {
+			return nil, err
+		}
+
+		p.lowDecimal, p.highDecimal = l, h
+	}
+
+	if dataTypes.IsTemporal(dataType) {
+		l, err := dataTypes.ParseQueryTime(dataType, low)
+		if err != nil {
+			return nil, err
+		}
+
+		h, err := dataTypes.ParseQueryTime(dataType, high)
+		if err != nil {
+			return nil, err
+		}
+
+		p.lowTime, p.highTime = l, h
+	}
+
+	return p, nil
+}
@@ -1,18 +1,14 @@
 package conditionResolver
 
 import (
+	"github.com/MarioLegenda/cig/internal/db/comparison"
 	"github.com/MarioLegenda/cig/internal/syntax"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
-func TestConditionResolver(t *testing.T) {
-	sql := "SELECT * FROM path:../../../testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1' OR 'e.Industry_aggregation_NZSIOC' = 'Level 2'"
-
-	structure, err := syntax.NewStructure(sql)
-	assert.Nil(t, err)
-
-	cm := NewColumnMetadata(
+func testColumnMetadata() ColumnMetadata {
+	return NewColumnMetadata(
 		[]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
 		[]string{
 			"Year",
@@ -27,8 +23,10 @@ func TestConditionResolver(t *testing.T) {
 			"Industry_code_ANZSIC06",
 		},
 	)
+}
 
-	lines := []string{
+func testLines() []string {
+	return []string{
 		"2021",
 		"Level 2",
 		"99999",
@@ -40,9 +38,246 @@ func TestConditionResolver(t *testing.T) {
 		"757,504",
 		"ANZSIC06 divisions A-S (excluding classes K6330, L6711, O7552, O760, O771, O772, S9540, S9601, S9602, and S9603)",
 	}
+}
+
+// resolveCase is a WHERE clause and whether the test row satisfies it
+type resolveCase struct {
+	where    string
+	expected bool
+}
+
+// assertResolves resolves the WHERE clause of every case against the row, in the order of the cases
+func assertResolves(t *testing.T, metadata ColumnMetadata, lines []string, cases []resolveCase) {
+	t.Helper()
+
+	for _, c := range cases {
+		structure, err := syntax.NewStructure("SELECT * FROM path:../../../testdata/example.csv AS e WHERE " + c.where)
+		if !assert.Nil(t, err, c.where) {
+			continue
+		}
+
+		resolved, err := ResolveCondition(structure.Condition(), metadata, lines)
+
+		assert.Nil(t, err, c.where)
+		assert.Equal(t, c.expected, resolved, c.where)
+	}
+}
 
-	resolved, err := ResolveCondition(structure.Condition(), cm, lines)
+func TestConditionResolver(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC' = 'Level 1' OR 'e.Industry_aggregation_NZSIOC' = 'Level 2'"
+
+	structure, err := syntax.NewStructure(sql)
+	assert.Nil(t, err)
+
+	resolved, err := ResolveCondition(structure.Condition(), testColumnMetadata(), testLines())
 
 	assert.Nil(t, err)
 	assert.True(t, resolved)
 }
+
+func TestConditionResolverPrecedence(t *testing.T) {
+	cases := []resolveCase{
+		{"'e.Industry_aggregation_NZSIOC' = 'Level 1' OR 'e.Industry_aggregation_NZSIOC' = 'Level 2' AND 'e.Year'::int = '2020'", false},
+		{"'e.Industry_aggregation_NZSIOC' = 'Level 2' OR 'e.Industry_aggregation_NZSIOC' = 'Level 1' AND 'e.Year'::int = '2020'", true},
+		{"('e.Industry_aggregation_NZSIOC' = 'Level 2' OR 'e.Industry_aggregation_NZSIOC' = 'Level 1') AND 'e.Year'::int = '2020'", false},
+		{"NOT 'e.Year'::int = '2020' AND NOT ('e.Variable_code' = 'H02' OR 'e.Variable_code' = 'H03')", true},
+		{"NOT NOT 'e.Year'::int = '2021'", true},
+	}
+
+	assertResolves(t, testColumnMetadata(), testLines(), cases)
+}
+
+func TestConditionResolverIn(t *testing.T) {
+	cases := []resolveCase{
+		{"'e.Variable_code' IN ('H02', 'H01')", true},
+		{"'e.Variable_code' NOT IN ('H02', 'H01')", false},
+		{"'e.Year'::int IN ('02021', '2020')", true},
+		{"'e.Year'::float IN ('2021.0')", true},
+		{"'e.Year' IN ('02021', '2020')", false},
+		{"'e.Year'::int NOT IN ('2019') AND 'e.Variable_code' = 'H01'", true},
+	}
+
+	assertResolves(t, testColumnMetadata(), testLines(), cases)
+}
+
+func TestConditionResolverLike(t *testing.T) {
+	cases := []resolveCase{
+		{"'e.Industry_name_NZSIOC' LIKE 'All%'", true},
+		{"'e.Industry_name_NZSIOC' LIKE 'all%'", false},
+		{"'e.Industry_name_NZSIOC' ILIKE 'all%'", true},
+		{"'e.Industry_name_NZSIOC' NOT ILIKE '%INDUSTRIES'", false},
+		{"'e.Industry_name_NZSIOC' LIKE 'All_industries'", true},
+		{"'e.Industry_name_NZSIOC' LIKE 'All industries_'", false},
+		{"'e.Units' LIKE '%(millions)'", true},
+		{"'e.Units' LIKE 'Dollars (%'", true},
+		{"'e.Industry_code_ANZSIC06' LIKE '%A!-S%' ESCAPE '!'", true},
+		{"'e.Variable_code' LIKE 'H0\\_'", false},
+		{"'e.Variable_code' NOT LIKE 'H0_' AND 'e.Year' LIKE '20%'", false},
+	}
+
+	assertResolves(t, testColumnMetadata(), testLines(), cases)
+}
+
+func TestConditionResolverRegex(t *testing.T) {
+	cases := []resolveCase{
+		{"'e.Variable_code' ~ '^H[0-9]+$'", true},
+		{"'e.Variable_code' ~ '^h[0-9]+$'", false},
+		{"'e.Variable_code' ~* '^h[0-9]+$'", true},
+		{"'e.Variable_code' !~ '^H'", false},
+		{"'e.Variable_code' !~* '^h'", false},
+		{"'e.Units' ~ 'millions'", true},
+	}
+
+	assertResolves(t, testColumnMetadata(), testLines(), cases)
+}
+
+func TestConditionResolverBetween(t *testing.T) {
+	cases := []resolveCase{
+		{"'e.Year'::int BETWEEN '2015' AND '2021'", true},
+		{"'e.Year'::int BETWEEN '2021' AND '2021'", true},
+		{"'e.Year'::int BETWEEN '2022' AND '2030'", false},
+		{"'e.Year'::int NOT BETWEEN '2015' AND '2020'", true},
+		{"'e.Year'::float BETWEEN '2020.5' AND '2021.5'", true},
+		{"'e.Variable_code' BETWEEN 'H00' AND 'H02' AND 'e.Year'::int BETWEEN '2000' AND '2021'", true},
+	}
+
+	assertResolves(t, testColumnMetadata(), testLines(), cases)
+}
+
+func TestConditionResolverNulls(t *testing.T) {
+	lines := testLines()
+	// Industry_code_NZSIOC and Value
+	lines[2] = ""
+	lines[8] = "NA"
+
+	cases := []resolveCase{
+		{"'e.Value' IS NULL", true},
+		{"'e.Value' IS NOT NULL", false},
+		{"'e.Industry_code_NZSIOC'::int IS NULL", true},
+		{"'e.Year' IS NOT NULL", true},
+		{"'e.Value'::int > '5'", false},
+		{"NOT 'e.Value'::int > '5'", false},
+		{"'e.Value'::int > '5' OR 'e.Year'::int = '2021'", true},
+		{"'e.Value'::int > '5' OR 'e.Year'::int = '2020'", false},
+		{"NOT ('e.Value'::int > '5' OR 'e.Year'::int = '2020')", false},
+		{"NOT ('e.Value'::int > '5' AND 'e.Year'::int = '2020')", true},
+		{"'e.Value' IN ('NA')", false},
+		{"'e.Value' NOT IN ('NA')", false},
+		{"'e.Value' LIKE '%'", false},
+		{"'e.Industry_code_NZSIOC' = ''", false},
+	}
+
+	assertResolves(t, testColumnMetadata(), lines, cases)
+
+	structure, err := syntax.NewStructure("SELECT * FROM path:../../../testdata/example.csv AS e WHERE 'e.Value' IS NULL OR 'e.Value' = 'NA'")
+	assert.Nil(t, err)
+
+	r, err := NewResolver(structure.Condition(), testColumnMetadata(), comparison.NewNulls([]string{}))
+	assert.Nil(t, err)
+
+	resolved, err := r.Resolve(lines)
+	assert.Nil(t, err)
+	assert.True(t, resolved)
+
+	structure, err = syntax.NewStructure("SELECT * FROM path:../../../testdata/example.csv AS e WHERE 'e.Industry_aggregation_NZSIOC'::int = '2'")
+	assert.Nil(t, err)
+
+	_, err = ResolveCondition(structure.Condition(), testColumnMetadata(), lines)
+	assert.NotNil(t, err)
+}
+
+func TestConditionResolverBoolsAndDecimals(t *testing.T) {
+	lines := testLines()
+	// Variable_code and Value
+	lines[5] = "Yes"
+	lines[8] = "0.30"
+
+	cases := []resolveCase{
+		{"'e.Variable_code'::bool = 'true'", true},
+		{"'e.Variable_code'::bool = 't'", true},
+		{"'e.Variable_code'::bool != '0'", true},
+		{"'e.Variable_code'::bool IN ('1')", true},
+		{"'e.Variable_code'::bool > 'no'", true},
+		{"'e.Value'::decimal(4,2) = '0.3'", true},
+		{"'e.Value'::decimal = '0.300'", true},
+		{"'e.Value'::decimal(4,2) IN ('0.1', '0.30')", true},
+		{"'e.Value'::decimal(4,2) BETWEEN '0.3' AND '0.31'", true},
+		{"'e.Value'::decimal(4,2) < '0.3'", false},
+		{"'e.Value'::decimal(4,2) - 0.1 - 0.2 = '0'", true},
+		{"'e.Value'::float - 0.1 - 0.2 = '0'", false},
+		{"'e.Value'::decimal(4,2) * 3 = '0.9'", true},
+		{"'e.Value'::decimal(4,2) / 3 = '0.1'", true},
+		{"'e.Value'::decimal(4,2) % 0.25 = '0.05'", true},
+		{"'e.Value'::decimal(4,2) + 0.001 > '0.3'", true},
+		{"'e.Value'::decimal(4,2) * 'e.Year'::int = '606.3'", true},
+		{"'e.Value'::decimal(4,2) * 'e.Year'::float > '606.29'", true},
+	}
+
+	assertResolves(t, testColumnMetadata(), lines, cases)
+
+	// big.Rat reads base prefixes and digit separators, decimals are only digits
+	for _, value := range []string{"0x10", "0b101", "0o7", "1_000"} {
+		lines[8] = value
+
+		structure, err := syntax.NewStructure("SELECT * FROM path:../../../testdata/example.csv AS e WHERE 'e.Value'::decimal(10,2) = '16'")
+		assert.Nil(t, err)
+
+		_, err = ResolveCondition(structure.Condition(), testColumnMetadata(), lines)
+		assert.NotNil(t, err, value)
+
+		_, err = syntax.NewStructure("SELECT * FROM path:../../../testdata/example.csv AS e WHERE 'e.Value'::decimal(10,2) = '" + value + "'")
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestConditionResolverCollations(t *testing.T) {
+	lines := testLines()
+	// Industry_code_NZSIOC and Industry_name_NZSIOC
+	lines[2] = "file10"
+	lines[3] = "Ärger"
+
+	cases := []resolveCase{
+		{"'e.Industry_aggregation_NZSIOC' = 'LEVEL 2'", false},
+		{"'e.Industry_aggregation_NZSIOC' COLLATE nocase = 'LEVEL 2'", true},
+		{"'e.Industry_aggregation_NZSIOC' COLLATE NOCASE != 'level 2'", false},
+		{"'e.Industry_aggregation_NZSIOC' COLLATE binary = 'level 2'", false},
+		{"'e.Industry_aggregation_NZSIOC' COLLATE nocase IN ('level 1', 'LEVEL 2')", true},
+		{"'e.Industry_aggregation_NZSIOC' COLLATE nocase BETWEEN 'LEVEL 1' AND 'level 3'", true},
+		{"'e.Industry_code_NZSIOC' > 'file2'", false},
+		{"'e.Industry_code_NZSIOC' COLLATE natural > 'file2'", true},
+		{"'e.Industry_code_NZSIOC' COLLATE natural < 'file010'", false},
+		{"'e.Industry_code_NZSIOC' COLLATE natural BETWEEN 'file9' AND 'file11'", true},
+		{"'e.Industry_name_NZSIOC' COLLATE nocase = 'äRGER'", false},
+		{"'e.Industry_name_NZSIOC' COLLATE unicode = 'äRGER'", true},
+		{"'e.Industry_name_NZSIOC' COLLATE unicode = 'A\u0308rger'", true},
+		{"UPPER('e.Industry_name_NZSIOC') COLLATE unicode = 'ärger'", true},
+	}
+
+	assertResolves(t, testColumnMetadata(), lines, cases)
+}
+
+func TestConditionResolverColumnComparisons(t *testing.T) {
+	lines := testLines()
+	// Industry_code_NZSIOC, Units, Variable_code and Value
+	lines[2] = "2020"
+	lines[4] = "level 2"
+	lines[5] = "NA"
+	lines[8] = "2021.00"
+
+	cases := []resolveCase{
+		{"'e.Year'::int > 'e.Industry_code_NZSIOC'::int", true},
+		{"'e.Year'::int = 'e.Industry_code_NZSIOC'::int + 1", true},
+		{"'e.Year' = 'e.Industry_code_NZSIOC'::int", false},
+		{"'e.Year'::int = 'e.Value'::decimal(6,2)", true},
+		{"'e.Year'::float >= 'e.Value'::float * 1.1", false},
+		{"'e.Industry_aggregation_NZSIOC' = 'e.Units'", false},
+		{"'e.Industry_aggregation_NZSIOC' COLLATE nocase = 'e.Units'", true},
+		{"'e.Industry_aggregation_NZSIOC' = UPPER('e.Units')", false},
+		{"LOWER('e.Industry_aggregation_NZSIOC') = 'e.Units'", true},
+		{"'e.Industry_aggregation_NZSIOC' = 'e.Variable_code'", false},
+		{"NOT 'e.Industry_aggregation_NZSIOC' = 'e.Variable_code'", false},
+		{"'e.Year'::date('2006') < 'e.Industry_code_NZSIOC'::date('2006')", false},
+	}
+
+	assertResolves(t, testColumnMetadata(), lines, cases)
+}
@@ -3,119 +3,342 @@ package conditionResolver
 import (
 	"fmt"
 	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/db/expression"
 	"github.com/MarioLegenda/cig/internal/syntax/operators"
 	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
 )
 
-type cond struct {
-	incomingValue  string
-	toCompareValue string
-	op             string
-	dataType       string
-	result         bool
+// truth is the result of a condition in three-valued logic. Comparing
+// a NULL value with anything is unknown.
+type truth int
+
+const (
+	isFalse truth = iota
+	isTrue
+	isUnknown
+)
+
+// Resolver evaluates a condition tree against a single row. It is created once per query
+// so that everything that does not depend on the row (column positions, hashed IN lists...)
+// is computed only once. A row matches only if the condition is true, false and unknown
+// rows are both filtered out.
+type Resolver interface {
+	Resolve(lines []string) (bool, error)
+}
+
+type resolver struct {
+	logicalOperator string
+	left            *resolver
+	right           *resolver
+
+	column      string
+	operator    string
+	position    int
+	evaluator   expression.Evaluator
+	processable comparison.Processable
+	nulls       comparison.Nulls
+
+	// value is set instead of processable if the compared value is different for every
+	// row, for example when two columns are compared
+	value     expression.Evaluator
+	dataType  string
+	collation string
+
+	// subquery is set for IN (SELECT ...) and EXISTS (SELECT ...)
+	subquery *subquerySet
+}
+
+func NewResolver(condition syntaxStructure.Condition, metadata ColumnMetadata, nulls comparison.Nulls) (Resolver, error) {
+	return newResolver(condition, metadata, nulls)
 }
 
-// good enough for now, technically incorrect
+// conditions of CASE are resolved like WHERE, see expression.NewPredicate
+func init() {
+	expression.NewPredicate = func(condition syntaxStructure.Condition, metadata expression.ColumnMetadata, nulls comparison.Nulls) (expression.Predicate, error) {
+		return newResolver(condition, metadata, nulls)
+	}
+}
+
+// ResolveCondition creates a Resolver with the default NULL markers and evaluates the condition
+// against a single row.
 func ResolveCondition(condition syntaxStructure.Condition, metadata ColumnMetadata, lines []string) (bool, error) {
-	ands := make([]cond, 0)
-	ors := make([]cond, 0)
+	r, err := NewResolver(condition, metadata, comparison.NewNulls(comparison.DefaultNullMarkers))
+	if err != nil {
+		return false, err
+	}
 
-	head := condition
-	var prevOp string
+	return r.Resolve(lines)
+}
 
-	if head == nil {
-		return false, fmt.Errorf("Invalid condition head. This is internal error and a bug.")
+func newResolver(condition syntaxStructure.Condition, metadata expression.ColumnMetadata, nulls comparison.Nulls) (*resolver, error) {
+	if condition == nil {
+		return nil, fmt.Errorf("Invalid condition head. This is internal error and a bug.")
 	}
-	// setup
-	for head != nil {
-		next := head.Next()
-		p := metadata.Position(head.Column().Column())
-		if p == -1 {
-			return false, fmt.Errorf("Invalid column to compare. Column %s not found", head.Column().Column())
+
+	if condition.IsLogical() {
+		left, err := newResolver(condition.Left(), metadata, nulls)
+		if err != nil {
+			return nil, err
 		}
 
-		if next != nil {
-			if next.Operator().ConditionType() == operators.AndOperator {
-				ands = append(ands, cond{
-					toCompareValue: lines[p],
-					dataType:       head.Column().DataType(),
-					incomingValue:  head.Value().Value(),
-					op:             head.Operator().ConditionType(),
-				})
-				prevOp = operators.AndOperator
-			} else if next.Operator().ConditionType() == operators.OrOperator {
-				ors = append(ors, cond{
-					toCompareValue: lines[p],
-					dataType:       head.Column().DataType(),
-					incomingValue:  head.Value().Value(),
-					op:             head.Operator().ConditionType(),
-				})
-				prevOp = operators.OrOperator
+		var right *resolver
+		if condition.Right() != nil {
+			right, err = newResolver(condition.Right(), metadata, nulls)
+			if err != nil {
+				return nil, err
 			}
+		}
 
-			// skip operator
-			head = head.Next().Next()
-			// is this the last item?
-		} else if next == nil {
-			if prevOp == "" {
-				ands = append(ands, cond{
-					toCompareValue: lines[p],
-					dataType:       head.Column().DataType(),
-					incomingValue:  head.Value().Value(),
-					op:             head.Operator().ConditionType(),
-				})
-			}
+		return &resolver{
+			logicalOperator: condition.Operator().ConditionType(),
+			left:            left,
+			right:           right,
+		}, nil
+	}
 
-			if prevOp == operators.AndOperator {
-				ands = append(ands, cond{
-					toCompareValue: lines[p],
-					dataType:       head.Column().DataType(),
-					incomingValue:  head.Value().Value(),
-					op:             head.Operator().ConditionType(),
-				})
-			}
+	r := &resolver{
+		column:   condition.Column().Column(),
+		operator: condition.Operator().ConditionType(),
+		nulls:    nulls,
+	}
 
-			if prevOp == operators.OrOperator {
-				ors = append(ors, cond{
-					toCompareValue: lines[p],
-					dataType:       head.Column().DataType(),
-					incomingValue:  head.Value().Value(),
-					op:             head.Operator().ConditionType(),
-				})
-			}
+	if condition.Value() != nil && condition.Value().Subquery() != nil {
+		subquery, err := newSubquerySet(condition, metadata, nulls)
+		if err != nil {
+			return nil, err
+		}
+
+		r.subquery = subquery
+
+		// EXISTS does not compare a column
+		if r.operator == operators.ExistsOperator {
+			return r, nil
+		}
+	}
+
+	if condition.Column().Expression() != nil {
+		evaluator, err := expression.NewEvaluator(condition.Column().Expression(), metadata, nulls)
+		if err != nil {
+			return nil, err
+		}
 
-			break
+		r.evaluator = evaluator
+	} else {
+		r.position = metadata.Position(condition.Column().Column())
+		if r.position == -1 {
+			return nil, fmt.Errorf("Invalid column to compare. Column %s not found", condition.Column().Column())
 		}
 	}
 
-	if len(ors) == 0 {
-		processables := make([]comparison.Processor, len(ands))
-		for i, t := range ands {
-			processables[i] = comparison.NewProcessable(t.incomingValue, t.toCompareValue, t.op, t.dataType)
+	if r.subquery != nil || r.operator == operators.IsNullOperator || r.operator == operators.IsNotNullOperator {
+		return r, nil
+	}
+
+	if e := condition.Value().Expression(); e != nil && syntaxStructure.UsesColumns(e) {
+		value, err := expression.NewEvaluator(e, metadata, nulls)
+		if err != nil {
+			return nil, err
 		}
 
-		processor := comparison.NewProcessor(processables)
-		ok, err := processor.Process()
-		if !ok || err != nil {
-			return ok, err
+		r.value = value
+		r.dataType = condition.Column().DataType()
+		r.collation = condition.Column().Collation()
+
+		return r, nil
+	}
+
+	processable, err := newProcessable(condition, metadata, nulls)
+	if err != nil {
+		return nil, err
+	}
+
+	r.processable = processable
+
+	return r, nil
+}
+
+func newProcessable(condition syntaxStructure.Condition, metadata expression.ColumnMetadata, nulls comparison.Nulls) (comparison.Processable, error) {
+	op := condition.Operator().ConditionType()
+	dataType := condition.Column().DataType()
+	collation := condition.Column().Collation()
+
+	if op == operators.InOperator || op == operators.NotInOperator {
+		return comparison.NewListProcessable(condition.Value().Values(), op, dataType, collation)
+	}
+
+	if op == operators.BetweenOperator || op == operators.NotBetweenOperator {
+		values := condition.Value().Values()
+
+		return comparison.NewRangeProcessable(values[0], values[1], op, dataType, collation)
+	}
+
+	if op == operators.LikeOperator || op == operators.NotLikeOperator || op == operators.ILikeOperator || op == operators.NotILikeOperator {
+		return comparison.NewPatternProcessable(condition.Value().Value(), condition.Value().Escape(), op)
+	}
+
+	if condition.Value().Regex() != nil {
+		return comparison.NewRegexProcessable(condition.Value().Regex(), op), nil
+	}
+
+	value := condition.Value().Value()
+	// computed values do not depend on the row so they are computed only once
+	if condition.Value().Expression() != nil {
+		evaluator, err := expression.NewEvaluator(condition.Value().Expression(), metadata, nulls)
+		if err != nil {
+			return nil, err
 		}
 
-		return true, nil
+		value, _, err = evaluator.Evaluate(nil)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if len(ors) != 0 {
-		for _, t := range ors {
-			processable := comparison.NewProcessable(t.incomingValue, t.toCompareValue, t.op, t.dataType)
-			ok, err := processable.Process()
+	return comparison.NewProcessable(value, op, dataType, collation), nil
+}
+
+func (r *resolver) Resolve(lines []string) (bool, error) {
+	t, err := r.resolve(lines)
+
+	return t == isTrue, err
+}
+
+// resolve walks the tree. AND and OR short-circuit so the right operand is not evaluated
+// if the left one already decides the result.
+func (r *resolver) resolve(lines []string) (truth, error) {
+	if r.logicalOperator == "" {
+		if r.operator == operators.ExistsOperator {
+			return r.subquery.exists(lines)
+		}
+
+		var value string
+		var isNull bool
+		if r.evaluator != nil {
+			var err error
+			value, isNull, err = r.evaluator.Evaluate(lines)
 			if err != nil {
-				return false, err
+				return isFalse, fmt.Errorf("Could not compute %s: %w", r.column, err)
 			}
+		} else {
+			value = lines[r.position]
+			isNull = r.nulls.IsNull(value)
+		}
 
-			if ok {
-				return true, nil
-			}
+		if r.subquery != nil {
+			return r.compareWithSubquery(lines, value, isNull)
+		}
+
+		return r.compare(lines, value, isNull)
+	}
+
+	left, err := r.left.resolve(lines)
+	if err != nil {
+		return isFalse, err
+	}
+
+	switch r.logicalOperator {
+	case operators.NotOperator:
+		if left == isUnknown {
+			return isUnknown, nil
+		}
+
+		return toTruth(left == isFalse), nil
+	case operators.AndOperator:
+		if left == isFalse {
+			return isFalse, nil
 		}
+	case operators.OrOperator:
+		if left == isTrue {
+			return isTrue, nil
+		}
+	default:
+		return isFalse, fmt.Errorf("Internal error. Could not match logical operator %s with any of valid operators", r.logicalOperator)
+	}
+
+	right, err := r.right.resolve(lines)
+	if err != nil {
+		return isFalse, err
+	}
+
+	// the left operand did not decide the result, so it is either unknown or
+	// the neutral element of the operator (true for AND, false for OR)
+	if right == isUnknown {
+		return isUnknown, nil
+	}
+
+	if r.logicalOperator == operators.AndOperator && right == isFalse {
+		return isFalse, nil
+	}
+
+	if r.logicalOperator == operators.OrOperator && right == isTrue {
+		return isTrue, nil
+	}
+
+	return left, nil
+}
+
+func (r *resolver) compare(lines []string, value string, isNull bool) (truth, error) {
+	if r.operator == operators.IsNullOperator {
+		return toTruth(isNull), nil
+	}
+
+	if r.operator == operators.IsNotNullOperator {
+		return toTruth(!isNull), nil
+	}
+
+	if isNull {
+		return isUnknown, nil
+	}
+
+	if r.value != nil {
+		return r.compareWithRow(lines, value)
+	}
+
+	ok, err := r.processable.Process(value)
+	if err != nil {
+		return isFalse, fmt.Errorf("Could not compare value %s of column %s: %w", value, r.column, err)
+	}
+
+	return toTruth(ok), nil
+}
+
+// compareWithSubquery looks up the value in the rows of IN (SELECT ...)
+func (r *resolver) compareWithSubquery(lines []string, value string, isNull bool) (truth, error) {
+	t, err := r.subquery.in(lines, value, isNull)
+	if err != nil {
+		return isFalse, fmt.Errorf("Could not compare value %s of column %s: %w", value, r.column, err)
+	}
+
+	if r.operator == operators.NotInOperator && t != isUnknown {
+		return toTruth(t == isFalse), nil
+	}
+
+	return t, nil
+}
+
+// compareWithRow compares the value with a value computed from the same row
+func (r *resolver) compareWithRow(lines []string, value string) (truth, error) {
+	other, isNull, err := r.value.Evaluate(lines)
+	if err != nil {
+		return isFalse, fmt.Errorf("Could not compute the value compared with column %s: %w", r.column, err)
+	}
+
+	if isNull {
+		return isUnknown, nil
+	}
+
+	ok, err := comparison.Compare(value, other, r.operator, r.dataType, r.collation)
+	if err != nil {
+		return isFalse, fmt.Errorf("Could not compare value %s of column %s with %s: %w", value, r.column, other, err)
+	}
+
+	return toTruth(ok), nil
+}
+
+func toTruth(b bool) truth {
+	if b {
+		return isTrue
 	}
 
-	return false, nil
+	return isFalse
 }
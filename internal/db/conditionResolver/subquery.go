@@ -0,0 +1,177 @@
+package conditionResolver
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/db/expression"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
+	"github.com/MarioLegenda/cig/pkg"
+	"strings"
+)
+
+// subqueryKey is how a column is hashed, by the data type and the collation it is compared by
+type subqueryKey struct {
+	position  int
+	dataType  string
+	collation string
+}
+
+// subquerySet is the result of a subquery hashed once, so that every row of the outer query is
+// only looked up in it. Rows of a correlated subquery are grouped by the values of its columns
+// that are compared with columns of the outer query. A row of the outer query is looked up only
+// in the group of its own values, which are the rows the subquery would return for it.
+type subquerySet struct {
+	// correlated are the columns of the outer query, the last values of every row of the
+	// subquery are compared with them
+	correlated []subqueryKey
+	// value is how the column of IN is hashed, it is compared with the first value of a row
+	value subqueryKey
+	nulls comparison.Nulls
+
+	// groups are the groups that have at least one row
+	groups map[string]bool
+	// values are the values of IN by their group, a group is empty without correlated columns
+	values map[string]bool
+	// nullGroups are the groups that have a row with a NULL value of IN
+	nullGroups map[string]bool
+}
+
+func newSubquerySet(condition syntaxStructure.Condition, metadata expression.ColumnMetadata, nulls comparison.Nulls) (*subquerySet, error) {
+	s := &subquerySet{
+		value: subqueryKey{
+			dataType:  condition.Column().DataType(),
+			collation: condition.Column().Collation(),
+		},
+		nulls:      nulls,
+		groups:     make(map[string]bool),
+		values:     make(map[string]bool),
+		nullGroups: make(map[string]bool),
+	}
+
+	for _, c := range condition.Value().Subquery().Columns() {
+		position := metadata.Position(c.Column())
+		if position == -1 {
+			return nil, fmt.Errorf("Invalid column to compare. Column %s not found", c.Column())
+		}
+
+		s.correlated = append(s.correlated, subqueryKey{
+			position:  position,
+			dataType:  c.DataType(),
+			collation: c.Collation(),
+		})
+	}
+
+	exists := condition.Operator().ConditionType() == operators.ExistsOperator
+	// IN compares its column with the first value of every row, before the correlated ones
+	columns := len(s.correlated)
+	if !exists {
+		columns++
+	}
+
+	for _, row := range condition.Value().Rows() {
+		if len(row) < columns {
+			return nil, fmt.Errorf("Expected the subquery to return %d columns, got %d: %w", columns, len(row), pkg.InvalidSubquery)
+		}
+
+		group, ok, err := s.key(s.correlated, row[len(row)-len(s.correlated):])
+		if err != nil {
+			return nil, err
+		}
+
+		// a NULL never equals anything, so rows with a NULL in a correlated column are in no group
+		if !ok {
+			continue
+		}
+
+		s.groups[group] = true
+		if exists {
+			continue
+		}
+
+		if s.nulls.IsNull(row[0]) {
+			s.nullGroups[group] = true
+
+			continue
+		}
+
+		value, err := comparison.HashKey(s.value.dataType, s.value.collation, row[0])
+		if err != nil {
+			return nil, err
+		}
+
+		s.values[group+"\x00"+value] = true
+	}
+
+	return s, nil
+}
+
+// key hashes values by keys. It is false if any of the values is NULL.
+func (s *subquerySet) key(keys []subqueryKey, values []string) (string, bool, error) {
+	hashed := make([]string, len(keys))
+	for i, k := range keys {
+		if s.nulls.IsNull(values[i]) {
+			return "", false, nil
+		}
+
+		v, err := comparison.HashKey(k.dataType, k.collation, values[i])
+		if err != nil {
+			return "", false, err
+		}
+
+		hashed[i] = v
+	}
+
+	return strings.Join(hashed, "\x00"), true, nil
+}
+
+// group returns the group of a row of the outer query. It is false if the subquery does not
+// return any rows for it.
+func (s *subquerySet) group(lines []string) (string, bool, error) {
+	values := make([]string, len(s.correlated))
+	for i, k := range s.correlated {
+		values[i] = lines[k.position]
+	}
+
+	group, ok, err := s.key(s.correlated, values)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	return group, s.groups[group], nil
+}
+
+func (s *subquerySet) exists(lines []string) (truth, error) {
+	_, ok, err := s.group(lines)
+
+	return toTruth(ok), err
+}
+
+// in looks up a value like IN with a list of values. If the value is not found, it is unknown
+// if the value is NULL or if the subquery returned a NULL, because NULL could be any value.
+// Nothing is in a subquery that returns no rows, not even NULL.
+func (s *subquerySet) in(lines []string, value string, isNull bool) (truth, error) {
+	group, ok, err := s.group(lines)
+	if err != nil || !ok {
+		return isFalse, err
+	}
+
+	if isNull {
+		return isUnknown, nil
+	}
+
+	v, err := comparison.HashKey(s.value.dataType, s.value.collation, value)
+	if err != nil {
+		return isFalse, err
+	}
+
+	if s.values[group+"\x00"+v] {
+		return isTrue, nil
+	}
+
+	if s.nullGroups[group] {
+		return isUnknown, nil
+	}
+
+	return isFalse, nil
+}
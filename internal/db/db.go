@@ -2,11 +2,11 @@ package db
 
 import (
 	"context"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
 	"github.com/MarioLegenda/cig/internal/db/conditionResolver"
 	"github.com/MarioLegenda/cig/internal/db/selectedColumnMetadata"
 	job2 "github.com/MarioLegenda/cig/internal/job"
 	"github.com/MarioLegenda/cig/internal/syntax"
-	"os"
 	"time"
 )
 
@@ -16,8 +16,18 @@ type fileMetadata struct {
 }
 
 type db struct {
-	openFs   *os.File
+	// closers close the opened files and remove the temporary files of joins
+	closers  []func() error
 	metadata fileMetadata
+	options  Options
+}
+
+// Options are the options given to cig.New(). Joins keep the rows of a joined file in memory
+// up to MemoryLimit bytes, bigger files are joined in temporary files in TempDir.
+type Options struct {
+	NullMarkers []string
+	TempDir     string
+	MemoryLimit int64
 }
 
 type DB interface {
@@ -32,10 +42,26 @@ type Data struct {
 	Data            []map[string]string
 }
 
+// Run runs a query and the queries combined with it by set operators
 func (d *db) Run(s syntax.Structure) Data {
+	data := d.run(s)
+	if data.Error != nil || len(s.SetOperations()) == 0 {
+		return data
+	}
+
+	return d.runSetOperations(data, s.SetOperations())
+}
+
+func (d *db) run(s syntax.Structure) Data {
 	file := s.FileDB()
+	nulls := comparison.NewNulls(d.options.NullMarkers)
 
-	fileHandler, err := prepareRun(file, d)
+	condition, err := d.runSubqueries(s.Condition())
+	if err != nil {
+		return newData(nil, nil, nil, err)
+	}
+
+	rows, err := prepareRun(file, d, nulls)
 	if err != nil {
 		return newData(nil, nil, nil, err)
 	}
@@ -47,7 +73,7 @@ func (d *db) Run(s syntax.Structure) Data {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	res, err := job2.SearchFactory(selectedColumns, conditionColumnMetadata, s.Condition(), s.Constraints(), fileHandler)(0, ctx)
+	res, err := job2.SearchFactory(selectedColumns, conditionColumnMetadata, condition, s.Constraints(), nulls, rows)(0, ctx)
 	if err != nil {
 		return newData(selectedColumns.Names(), fsMetadata.columns.names(), nil, err)
 	}
@@ -56,11 +82,18 @@ func (d *db) Run(s syntax.Structure) Data {
 }
 
 func (d *db) Close() error {
-	return d.openFs.Close()
+	var err error
+	for _, c := range d.closers {
+		if e := c(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
 }
 
-func New() DB {
-	return &db{}
+func New(options Options) DB {
+	return &db{options: options}
 }
 
 func createConditionColumnMetadata(fsMetadata fileMetadata) conditionResolver.ColumnMetadata {
@@ -76,7 +109,7 @@ func createConditionColumnMetadata(fsMetadata fileMetadata) conditionResolver.Co
 }
 
 func createSelectedColumnMetadata(structure syntax.Structure, fsMetadata fileMetadata) selectedColumnMetadata.ColumnMetadata {
-	return selectedColumnMetadata.New(structure.Column().Columns(), fsMetadata.columns.names())
+	return selectedColumnMetadata.New(structure.Column(), fsMetadata.columns.names())
 }
 
 func newData(selected, all []string, data []map[string]string, err error) Data {
@@ -0,0 +1,543 @@
+package expression
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/functions"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// ColumnMetadata gives the position of a column in a row
+type ColumnMetadata interface {
+	Position(name string) int
+}
+
+// Evaluator computes the value of an expression for a single row. It is created once
+// per query so that column positions are resolved only once. The returned bool is true
+// if the value is NULL, a NULL operand makes the whole expression NULL.
+type Evaluator interface {
+	Evaluate(lines []string) (string, bool, error)
+}
+
+// Predicate tells if the condition of WHEN in CASE is true for a row
+type Predicate interface {
+	Resolve(lines []string) (bool, error)
+}
+
+// NewPredicate creates the predicate of a condition of WHEN. Conditions compare computed values, so
+// the conditionResolver package depends on this one and sets NewPredicate when it is imported.
+var NewPredicate func(condition syntaxStructure.Condition, metadata ColumnMetadata, nulls comparison.Nulls) (Predicate, error)
+
+type evaluator struct {
+	kind      string
+	operator  string
+	left      *evaluator
+	right     *evaluator
+	function  string
+	arguments []*evaluator
+
+	// conditions of CASE, arguments are the values of their branches
+	conditions []Predicate
+	elseValue  *evaluator
+
+	column   string
+	position int
+	dataType string
+	// layout is the data type with the layout hint of date and time columns
+	layout string
+	value  string
+	nulls  comparison.Nulls
+}
+
+// number is the operand of arithmetic. Integers stay integers as long as both
+// operands are integers. Decimals stay exact with integers and other decimals.
+type number struct {
+	isInt     bool
+	i         int64
+	f         float64
+	isDecimal bool
+	d         *big.Rat
+	scale     int
+}
+
+func NewEvaluator(e syntaxStructure.Expression, metadata ColumnMetadata, nulls comparison.Nulls) (Evaluator, error) {
+	return newEvaluator(e, metadata, nulls)
+}
+
+func newEvaluator(e syntaxStructure.Expression, metadata ColumnMetadata, nulls comparison.Nulls) (*evaluator, error) {
+	if e == nil {
+		return nil, fmt.Errorf("Invalid expression. This is internal error and a bug.")
+	}
+
+	ev := &evaluator{
+		kind:  e.Kind(),
+		nulls: nulls,
+	}
+
+	switch e.Kind() {
+	case syntaxStructure.ColumnExpression:
+		p := metadata.Position(e.Column())
+		if p == -1 {
+			return nil, fmt.Errorf("Invalid column in expression. Column %s not found", e.Column())
+		}
+
+		ev.column = e.Column()
+		ev.position = p
+		// columns without a data type are numbers with a decimal point in arithmetic
+		ev.dataType = e.DataType()
+		if ev.dataType == "" {
+			ev.dataType = dataTypes.Float
+		}
+
+		// dates and times are converted to ISO-8601 so that functions and arithmetic
+		// do not have to know their layout
+		if dataTypes.IsTemporal(ev.dataType) {
+			ev.layout = ev.dataType
+			ev.dataType = dataTypes.Base(ev.dataType)
+		}
+	case syntaxStructure.LiteralExpression:
+		ev.value = e.Value()
+		ev.dataType = e.DataType()
+		if ev.dataType == "" {
+			ev.dataType = dataTypes.Float
+			if _, err := strconv.ParseInt(e.Value(), 10, 64); err == nil {
+				ev.dataType = dataTypes.Int
+			}
+		}
+	case syntaxStructure.OperationExpression:
+		left, err := newEvaluator(e.Left(), metadata, nulls)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := newEvaluator(e.Right(), metadata, nulls)
+		if err != nil {
+			return nil, err
+		}
+
+		ev.operator = e.Operator()
+		ev.left = left
+		ev.right = right
+		// numbers keep decimals exact
+		if dataTypes.Base(left.dataType) == dataTypes.Decimal && right.kind == syntaxStructure.LiteralExpression && right.dataType == dataTypes.Float {
+			right.dataType = dataTypes.Decimal
+		}
+
+		if dataTypes.Base(right.dataType) == dataTypes.Decimal && left.kind == syntaxStructure.LiteralExpression && left.dataType == dataTypes.Float {
+			left.dataType = dataTypes.Decimal
+		}
+
+		if dataTypes.Base(left.dataType) == dataTypes.Decimal || dataTypes.Base(right.dataType) == dataTypes.Decimal {
+			ev.dataType = dataTypes.Decimal
+		}
+		// adding an interval to a date gives a date
+		for _, operand := range []*evaluator{left, right} {
+			if dataTypes.IsTemporal(operand.dataType) {
+				ev.dataType = operand.dataType
+			}
+		}
+	case syntaxStructure.FunctionExpression:
+		ev.function = e.Function()
+		ev.column = e.Function()
+		ev.dataType = e.DataType()
+		for _, a := range e.Arguments() {
+			argument, err := newEvaluator(a, metadata, nulls)
+			if err != nil {
+				return nil, err
+			}
+
+			ev.arguments = append(ev.arguments, argument)
+		}
+
+		// functions without arguments, like NOW(), are computed once so that they
+		// are the same for every row
+		if len(ev.arguments) == 0 {
+			value, _, err := comparison.CallFunction(ev.function, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			ev.kind = syntaxStructure.LiteralExpression
+			ev.value = value
+		}
+	case syntaxStructure.CaseExpression:
+		if NewPredicate == nil {
+			return nil, fmt.Errorf("Internal error. Conditions of CASE cannot be resolved")
+		}
+
+		ev.column = operators.CaseKeyword
+		ev.dataType = e.DataType()
+		for _, w := range e.When() {
+			condition, err := NewPredicate(w.Condition(), metadata, nulls)
+			if err != nil {
+				return nil, err
+			}
+
+			then, err := newEvaluator(w.Then(), metadata, nulls)
+			if err != nil {
+				return nil, err
+			}
+
+			ev.conditions = append(ev.conditions, condition)
+			ev.arguments = append(ev.arguments, then)
+		}
+
+		if e.Else() != nil {
+			elseValue, err := newEvaluator(e.Else(), metadata, nulls)
+			if err != nil {
+				return nil, err
+			}
+
+			ev.elseValue = elseValue
+		}
+	default:
+		return nil, fmt.Errorf("Internal error. Unknown expression %s", e.Kind())
+	}
+
+	return ev, nil
+}
+
+func (e *evaluator) Evaluate(lines []string) (string, bool, error) {
+	switch e.kind {
+	case syntaxStructure.ColumnExpression:
+		value := lines[e.position]
+		if e.nulls.IsNull(value) {
+			return value, true, nil
+		}
+
+		if e.layout != "" {
+			t, err := dataTypes.ParseTime(e.layout, value)
+			if err != nil {
+				return "", false, fmt.Errorf("Value %s of column %s is not a valid %s", value, e.column, e.dataType)
+			}
+
+			return dataTypes.FormatTime(e.dataType, t), false, nil
+		}
+
+		return value, false, nil
+	case syntaxStructure.LiteralExpression:
+		return e.value, false, nil
+	case syntaxStructure.CaseExpression:
+		return e.evaluateCase(lines)
+	case syntaxStructure.FunctionExpression:
+		if e.function == functions.Coalesce || e.function == functions.NullIf {
+			return e.evaluateNullable(lines)
+		}
+
+		values := make([]string, len(e.arguments))
+		isNull := make([]bool, len(e.arguments))
+		for i, a := range e.arguments {
+			value, null, err := a.Evaluate(lines)
+			if err != nil {
+				return "", false, err
+			}
+
+			values[i] = value
+			isNull[i] = null
+		}
+
+		return comparison.CallFunction(e.function, values, isNull)
+	}
+
+	if dataTypes.IsTemporal(e.dataType) {
+		return e.time(lines)
+	}
+
+	n, isNull, err := e.number(lines)
+	if err != nil || isNull {
+		return "", isNull, err
+	}
+
+	if n.isInt {
+		return strconv.FormatInt(n.i, 10), false, nil
+	}
+
+	if n.isDecimal {
+		return n.d.FloatString(n.scale), false, nil
+	}
+
+	return strconv.FormatFloat(n.f, 'f', -1, 64), false, nil
+}
+
+// evaluateCase returns the value of the first branch whose condition is true. Like in WHERE,
+// a condition that is unknown because of a NULL value is not true.
+func (e *evaluator) evaluateCase(lines []string) (string, bool, error) {
+	for i, c := range e.conditions {
+		matches, err := c.Resolve(lines)
+		if err != nil {
+			return "", false, err
+		}
+
+		if matches {
+			return e.arguments[i].Evaluate(lines)
+		}
+	}
+
+	if e.elseValue == nil {
+		return "", true, nil
+	}
+
+	return e.elseValue.Evaluate(lines)
+}
+
+// evaluateNullable computes COALESCE, the first of its arguments that is not NULL, and NULLIF,
+// which is NULL if its arguments are equal and the first argument otherwise
+func (e *evaluator) evaluateNullable(lines []string) (string, bool, error) {
+	if e.function == functions.Coalesce {
+		for _, a := range e.arguments {
+			value, isNull, err := a.Evaluate(lines)
+			if err != nil || !isNull {
+				return value, isNull, err
+			}
+		}
+
+		return "", true, nil
+	}
+
+	value, isNull, err := e.arguments[0].Evaluate(lines)
+	if err != nil || isNull {
+		return "", isNull, err
+	}
+
+	other, isNull, err := e.arguments[1].Evaluate(lines)
+	if err != nil || isNull {
+		return value, false, err
+	}
+
+	equal, err := comparison.Compare(value, other, operators.EqualOperator, e.dataType, "")
+	if err != nil {
+		return "", false, fmt.Errorf("Could not compare %s with %s in NULLIF: %w", value, other, err)
+	}
+
+	if equal {
+		return "", true, nil
+	}
+
+	return value, false, nil
+}
+
+// time adds an interval to a date or a time or subtracts it
+func (e *evaluator) time(lines []string) (string, bool, error) {
+	left, isNull, err := e.left.Evaluate(lines)
+	if err != nil || isNull {
+		return "", isNull, err
+	}
+
+	right, isNull, err := e.right.Evaluate(lines)
+	if err != nil || isNull {
+		return "", isNull, err
+	}
+
+	sign := 1
+	if e.operator == operators.SubtractOperator {
+		sign = -1
+	}
+
+	value, interval := left, right
+	if e.left.dataType == dataTypes.Interval {
+		value, interval = right, left
+	}
+
+	t, err := dataTypes.ParseTime(e.dataType, value)
+	if err != nil {
+		return "", false, err
+	}
+
+	i, err := dataTypes.ParseInterval(interval)
+	if err != nil {
+		return "", false, err
+	}
+
+	return dataTypes.FormatTime(e.dataType, i.Add(t, sign)), false, nil
+}
+
+func (e *evaluator) number(lines []string) (number, bool, error) {
+	if e.kind != syntaxStructure.OperationExpression {
+		value, isNull, err := e.Evaluate(lines)
+		if err != nil || isNull {
+			return number{}, isNull, err
+		}
+
+		n, err := e.toNumber(value)
+
+		return n, false, err
+	}
+
+	left, isNull, err := e.left.number(lines)
+	if err != nil || isNull {
+		return number{}, isNull, err
+	}
+
+	right, isNull, err := e.right.number(lines)
+	if err != nil || isNull {
+		return number{}, isNull, err
+	}
+
+	n, err := calculate(e.operator, left, right)
+
+	return n, false, err
+}
+
+func (e *evaluator) toNumber(value string) (number, error) {
+	if dataTypes.Base(e.dataType) == dataTypes.Decimal {
+		d, scale, err := dataTypes.ParseDecimal(e.dataType, value)
+		if err != nil {
+			return number{}, fmt.Errorf("Value %s of column %s is not a valid decimal", value, e.column)
+		}
+
+		return number{isDecimal: true, d: d, scale: scale}, nil
+	}
+
+	if e.dataType == dataTypes.Int {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return number{}, fmt.Errorf("Value %s of column %s is not a valid integer", value, e.column)
+		}
+
+		return number{isInt: true, i: i}, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return number{}, fmt.Errorf("Value %s of column %s is not a valid number", value, e.column)
+	}
+
+	return number{f: f}, nil
+}
+
+func (n number) float() float64 {
+	if n.isInt {
+		return float64(n.i)
+	}
+
+	if n.isDecimal {
+		f, _ := n.d.Float64()
+
+		return f
+	}
+
+	return n.f
+}
+
+func (n number) decimal() *big.Rat {
+	if n.isInt {
+		return new(big.Rat).SetInt64(n.i)
+	}
+
+	return n.d
+}
+
+func calculateDecimal(operator string, a, b number) (number, error) {
+	x := a.decimal()
+	y := b.decimal()
+	scale := max(a.scale, b.scale)
+
+	switch operator {
+	case operators.AddOperator:
+		return number{isDecimal: true, d: new(big.Rat).Add(x, y), scale: scale}, nil
+	case operators.SubtractOperator:
+		return number{isDecimal: true, d: new(big.Rat).Sub(x, y), scale: scale}, nil
+	case operators.MultiplyOperator:
+		return number{isDecimal: true, d: new(big.Rat).Mul(x, y), scale: a.scale + b.scale}, nil
+	case operators.DivideOperator, operators.ModuloOperator:
+		if y.Sign() == 0 {
+			return number{}, fmt.Errorf("Division by zero")
+		}
+
+		q := new(big.Rat).Quo(x, y)
+		if operator == operators.DivideOperator {
+			return number{isDecimal: true, d: q, scale: scale + dataTypes.DivisionScale}, nil
+		}
+
+		// the remainder has the sign of the dividend
+		truncated := new(big.Rat).SetInt(new(big.Int).Quo(q.Num(), q.Denom()))
+
+		return number{isDecimal: true, d: new(big.Rat).Sub(x, truncated.Mul(truncated, y)), scale: scale}, nil
+	}
+
+	return number{}, fmt.Errorf("Internal error. Could not match arithmetic operator %s with any of valid operators", operator)
+}
+
+// integerOverflow is the error of integer arithmetic whose result does not fit into an int64.
+// Integers are not silently turned into floats or decimals since the data type of the result
+// is decided when the query is validated.
+func integerOverflow(operator string, a, b number) error {
+	return fmt.Errorf("Integer overflow in %d %s %d", a.i, operator, b.i)
+}
+
+// calculate computes integers as integers, decimals exactly and everything else as floats.
+// Integer arithmetic that overflows is an error.
+func calculate(operator string, a, b number) (number, error) {
+	if (a.isDecimal || b.isDecimal) && (a.isDecimal || a.isInt) && (b.isDecimal || b.isInt) {
+		return calculateDecimal(operator, a, b)
+	}
+
+	if a.isInt && b.isInt {
+		switch operator {
+		case operators.AddOperator:
+			r := a.i + b.i
+			if (a.i^r)&(b.i^r) < 0 {
+				return number{}, integerOverflow(operator, a, b)
+			}
+
+			return number{isInt: true, i: r}, nil
+		case operators.SubtractOperator:
+			r := a.i - b.i
+			if (a.i^b.i)&(a.i^r) < 0 {
+				return number{}, integerOverflow(operator, a, b)
+			}
+
+			return number{isInt: true, i: r}, nil
+		case operators.MultiplyOperator:
+			r := a.i * b.i
+			if a.i != 0 && (r/a.i != b.i || (a.i == -1 && b.i == math.MinInt64)) {
+				return number{}, integerOverflow(operator, a, b)
+			}
+
+			return number{isInt: true, i: r}, nil
+		case operators.DivideOperator, operators.ModuloOperator:
+			if b.i == 0 {
+				return number{}, fmt.Errorf("Division by zero")
+			}
+
+			if a.i == math.MinInt64 && b.i == -1 {
+				return number{}, integerOverflow(operator, a, b)
+			}
+
+			if operator == operators.DivideOperator {
+				return number{isInt: true, i: a.i / b.i}, nil
+			}
+
+			return number{isInt: true, i: a.i % b.i}, nil
+		}
+	}
+
+	x := a.float()
+	y := b.float()
+
+	switch operator {
+	case operators.AddOperator:
+		return number{f: x + y}, nil
+	case operators.SubtractOperator:
+		return number{f: x - y}, nil
+	case operators.MultiplyOperator:
+		return number{f: x * y}, nil
+	case operators.DivideOperator, operators.ModuloOperator:
+		if y == 0 {
+			return number{}, fmt.Errorf("Division by zero")
+		}
+
+		if operator == operators.DivideOperator {
+			return number{f: x / y}, nil
+		}
+
+		return number{f: math.Mod(x, y)}, nil
+	}
+
+	return number{}, fmt.Errorf("Internal error. Could not match arithmetic operator %s with any of valid operators", operator)
+}
@@ -0,0 +1,265 @@
+package join
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
+	"sort"
+)
+
+// nearest is the pair of columns an ASOF JOIN finds the nearest row by. The operator compares
+// the column of the left table with the column of the right one.
+type nearest struct {
+	key
+	operator string
+}
+
+// asofRow is a row with the values it is sorted and matched by
+type asofRow struct {
+	values []string
+	// group is the key of the columns compared with =
+	group string
+	// position is the value of the nearest column in a form that can be compared
+	position string
+	index    int
+}
+
+type asofJoin struct {
+	joinType string
+	left     Table
+	right    Table
+	keys     []key
+	nearest  nearest
+	nulls    comparison.Nulls
+
+	rows [][]string
+	next int
+}
+
+// flipped are the operators for comparisons that are written with the column of the right
+// table first, 'b.ts' <= 'a.ts' is the same as 'a.ts' >= 'b.ts'
+var flipped = map[string]string{
+	operators.GreaterThanOrEqualOperator: operators.LessThanOrEqualOperator,
+	operators.GreaterThanOperator:        operators.LessThanOperator,
+	operators.LessThanOrEqualOperator:    operators.GreaterThanOrEqualOperator,
+	operators.LessThanOperator:           operators.GreaterThanOperator,
+}
+
+// newAsof joins every row of the left table with the nearest row of the right one. With >=, the
+// nearest row is the one with the greatest value that is not greater than the value of the left
+// row and with <= the one with the smallest value that is not smaller. Both tables are sorted by
+// the columns compared with = and then by the nearest column and merged, so every row is compared
+// only with its neighbours. The joined rows are returned in the order of the left table.
+func newAsof(left, right Table, j syntaxStructure.Join, nulls comparison.Nulls, columns []string) (Table, error) {
+	n, ok := nearestKey(j.On(), left.Columns, right.Columns)
+	if !ok {
+		return Table{}, fmt.Errorf("Invalid ASOF JOIN condition of %s. It does not compare a column of %s with another file", j.Alias(), j.Alias())
+	}
+
+	a := &asofJoin{
+		joinType: j.Type(),
+		left:     left,
+		right:    right,
+		keys:     equalityKeys(j.On(), left.Columns, right.Columns),
+		nearest:  n,
+		nulls:    nulls,
+	}
+
+	return Table{
+		Rows:    a.read,
+		Columns: columns,
+		Close: func() error {
+			return nil
+		},
+	}, nil
+}
+
+func nearestKey(on syntaxStructure.Condition, leftColumns, rightColumns []string) (nearest, bool) {
+	if on.IsLogical() {
+		if n, ok := nearestKey(on.Left(), leftColumns, rightColumns); ok {
+			return n, ok
+		}
+
+		return nearestKey(on.Right(), leftColumns, rightColumns)
+	}
+
+	operator := on.Operator().ConditionType()
+	if _, ok := flipped[operator]; !ok || on.Value().Expression() == nil {
+		return nearest{}, false
+	}
+
+	n := nearest{
+		key: key{
+			left:      position(leftColumns, on.Column().Column()),
+			right:     position(rightColumns, on.Value().Expression().Column()),
+			dataType:  on.Column().DataType(),
+			collation: on.Column().Collation(),
+		},
+		operator: operator,
+	}
+
+	if n.left == -1 || n.right == -1 {
+		n.left = position(leftColumns, on.Value().Expression().Column())
+		n.right = position(rightColumns, on.Column().Column())
+		n.operator = flipped[operator]
+	}
+
+	return n, n.left != -1 && n.right != -1
+}
+
+// readAll reads the rows of a table. Rows with a NULL in a compared column cannot match,
+// they are returned separately.
+func (a *asofJoin) readAll(t Table, right bool) ([]asofRow, []asofRow, error) {
+	rows := make([]asofRow, 0)
+	unmatched := make([]asofRow, 0)
+
+	for i := 0; ; i++ {
+		values, err := t.Rows()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(values) == 0 {
+			return rows, unmatched, nil
+		}
+
+		row := asofRow{values: values, index: i}
+
+		p := a.nearest.left
+		if right {
+			p = a.nearest.right
+		}
+
+		group, ok, err := rowKey(a.keys, a.nulls, values, right)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !ok || a.nulls.IsNull(values[p]) {
+			unmatched = append(unmatched, row)
+
+			continue
+		}
+
+		row.group = group
+		row.position = values[p]
+		if dataTypes.IsTemporal(a.nearest.dataType) {
+			row.position, err = dataTypes.SortableTime(a.nearest.dataType, values[p])
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		rows = append(rows, row)
+	}
+}
+
+func (a *asofJoin) compare(v1, v2 string) int {
+	return comparison.CompareValues(a.nearest.dataType, a.nearest.collation, v1, v2)
+}
+
+func (a *asofJoin) sort(rows []asofRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].group != rows[j].group {
+			return rows[i].group < rows[j].group
+		}
+
+		return a.compare(rows[i].position, rows[j].position) < 0
+	})
+}
+
+// join merges the sorted tables. For every left row, the right rows of the same group are
+// skipped while they are before the nearest one. Left rows are sorted as well, so the skipped
+// rows are never needed again.
+func (a *asofJoin) join() error {
+	leftRows, leftUnmatched, err := a.readAll(a.left, false)
+	if err != nil {
+		return err
+	}
+
+	rightRows, _, err := a.readAll(a.right, true)
+	if err != nil {
+		return err
+	}
+
+	a.sort(leftRows)
+	a.sort(rightRows)
+
+	// matches are the rows of the right table by the index of the left row they are joined with
+	matches := make(map[int][]string)
+
+	// before tells if a right row is still before the nearest one of the left row
+	before := func(l, r asofRow) bool {
+		cmp := a.compare(r.position, l.position)
+
+		switch a.nearest.operator {
+		case operators.GreaterThanOrEqualOperator:
+			return cmp <= 0
+		case operators.GreaterThanOperator:
+			return cmp < 0
+		case operators.LessThanOrEqualOperator:
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	}
+
+	greater := a.nearest.operator == operators.GreaterThanOrEqualOperator || a.nearest.operator == operators.GreaterThanOperator
+
+	j := 0
+	for _, l := range leftRows {
+		for j < len(rightRows) && rightRows[j].group < l.group {
+			j++
+		}
+
+		for j < len(rightRows) && rightRows[j].group == l.group && before(l, rightRows[j]) {
+			j++
+		}
+
+		// with >= and >, the nearest row is the last one that was skipped and with <= and <
+		// it is the first one that was not
+		match := j
+		if greater {
+			match = j - 1
+		}
+
+		if match >= 0 && match < len(rightRows) && rightRows[match].group == l.group {
+			matches[l.index] = rightRows[match].values
+		}
+	}
+
+	all := append(leftRows, leftUnmatched...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].index < all[j].index
+	})
+
+	for _, l := range all {
+		if r, ok := matches[l.index]; ok {
+			a.rows = append(a.rows, concat(l.values, r))
+		} else if a.joinType == operators.AsofLeftJoin {
+			a.rows = append(a.rows, concat(l.values, missing(len(a.right.Columns))))
+		}
+	}
+
+	return nil
+}
+
+func (a *asofJoin) read() ([]string, error) {
+	if a.rows == nil {
+		a.rows = make([][]string, 0)
+		if err := a.join(); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.next == len(a.rows) {
+		return nil, nil
+	}
+
+	row := a.rows[a.next]
+	a.next++
+
+	return row, nil
+}
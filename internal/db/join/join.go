@@ -0,0 +1,342 @@
+package join
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/db/conditionResolver"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
+	"strings"
+)
+
+// DefaultMemoryLimit is the memory in bytes the rows of a joined file can take if nothing else is configured
+const DefaultMemoryLimit int64 = 256 << 20
+
+// Table is one side of a join. Rows reads the rows one by one without the column row and
+// returns nil after the last one. Columns are the names of the columns of a row. Close
+// releases what the table holds, like open files.
+type Table struct {
+	Rows    func() ([]string, error)
+	Columns []string
+	Close   func() error
+}
+
+// Options are the limits of a join. If the rows of the joined file take more than MemoryLimit
+// bytes, both files are partitioned into temporary files in TempDir. A MemoryLimit of 0 means
+// that the joined file is always kept in memory and an empty TempDir is the temporary
+// directory of the system.
+type Options struct {
+	TempDir     string
+	MemoryLimit int64
+}
+
+// key is a pair of columns that ON compares with =, one of the left table and one of the right one
+type key struct {
+	left      int
+	right     int
+	dataType  string
+	collation string
+}
+
+type buildRow struct {
+	values  []string
+	matched bool
+}
+
+type hashJoin struct {
+	joinType string
+	left     Table
+	right    Table
+	keys     []key
+	resolver conditionResolver.Resolver
+	nulls    comparison.Nulls
+	options  Options
+
+	// rows of the right table by the values of their keys, rows are hashed under the
+	// same key if there are no keys
+	table map[string][]*buildRow
+	// all rows of the right table in the order of the file
+	rows []*buildRow
+	// size is the estimated memory the rows of the right table take
+	size int64
+	// spilled is set once the right table does not fit into memory
+	spilled *graceJoin
+
+	built   bool
+	probed  bool
+	pending [][]string
+	next    int
+}
+
+// New joins the rows of the left table with the rows of the right one. The right table is read
+// into a hash table by the columns that ON compares with = and the left table is streamed against
+// it, so only the right table is kept in memory. If it does not fit into the memory limit, the join
+// continues as a grace hash join on disk. Every candidate row is checked against the whole
+// ON condition. NULL values never match. The columns of a joined row are the columns of the left
+// table followed by the columns of the right one and the columns of a missing row are comparison.Missing.
+func New(left, right Table, j syntaxStructure.Join, nulls comparison.Nulls, options Options) (Table, error) {
+	columns := make([]string, 0, len(left.Columns)+len(right.Columns))
+	columns = append(columns, left.Columns...)
+	columns = append(columns, right.Columns...)
+
+	positions := make([]int, len(columns))
+	for i := range columns {
+		positions[i] = i
+	}
+
+	resolver, err := conditionResolver.NewResolver(j.On(), conditionResolver.NewColumnMetadata(positions, columns), nulls)
+	if err != nil {
+		return Table{}, fmt.Errorf("Invalid JOIN condition of %s: %w", j.Alias(), err)
+	}
+
+	if j.Type() == operators.AsofJoin || j.Type() == operators.AsofLeftJoin {
+		return newAsof(left, right, j, nulls, columns)
+	}
+
+	h := &hashJoin{
+		joinType: j.Type(),
+		left:     left,
+		right:    right,
+		keys:     equalityKeys(j.On(), left.Columns, right.Columns),
+		resolver: resolver,
+		nulls:    nulls,
+		options:  options,
+		table:    make(map[string][]*buildRow),
+	}
+
+	return Table{
+		Rows:    h.read,
+		Columns: columns,
+		Close:   h.close,
+	}, nil
+}
+
+// equalityKeys collects the columns compared with = that every joined row must satisfy,
+// which are the comparisons joined with AND at the top of the condition
+func equalityKeys(on syntaxStructure.Condition, leftColumns, rightColumns []string) []key {
+	if on.IsLogical() {
+		if on.Operator().ConditionType() != operators.AndOperator {
+			return nil
+		}
+
+		return append(equalityKeys(on.Left(), leftColumns, rightColumns), equalityKeys(on.Right(), leftColumns, rightColumns)...)
+	}
+
+	if on.Operator().ConditionType() != operators.EqualOperator || on.Column().Expression() != nil {
+		return nil
+	}
+
+	value := on.Value().Expression()
+	if value == nil || value.Kind() != syntaxStructure.ColumnExpression {
+		return nil
+	}
+
+	k := key{
+		left:      position(leftColumns, on.Column().Column()),
+		right:     position(rightColumns, value.Column()),
+		dataType:  on.Column().DataType(),
+		collation: on.Column().Collation(),
+	}
+
+	if k.left == -1 || k.right == -1 {
+		k.left = position(leftColumns, value.Column())
+		k.right = position(rightColumns, on.Column().Column())
+	}
+
+	if k.left == -1 || k.right == -1 {
+		return nil
+	}
+
+	return []key{k}
+}
+
+func position(columns []string, column string) int {
+	for i, c := range columns {
+		if c == column {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// hashKey is the key of a row in the hash table, ok is false if one of the values is NULL
+func (h *hashJoin) hashKey(row []string, right bool) (string, bool, error) {
+	return rowKey(h.keys, h.nulls, row, right)
+}
+
+// rowKey joins the values of the keys of a row of the left or the right table, converted so that
+// equal values are the same
+func rowKey(keys []key, nulls comparison.Nulls, row []string, right bool) (string, bool, error) {
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		p := k.left
+		if right {
+			p = k.right
+		}
+
+		value := row[p]
+		if nulls.IsNull(value) {
+			return "", false, nil
+		}
+
+		v, err := comparison.HashKey(k.dataType, k.collation, value)
+		if err != nil {
+			return "", false, err
+		}
+
+		values[i] = v
+	}
+
+	return strings.Join(values, "\x00"), true, nil
+}
+
+func (h *hashJoin) build() error {
+	for {
+		row, err := h.right.Rows()
+		if err != nil {
+			return err
+		}
+
+		if len(row) == 0 {
+			return nil
+		}
+
+		r := &buildRow{values: row}
+		h.rows = append(h.rows, r)
+
+		h.size += rowSize(row)
+		if h.options.MemoryLimit > 0 && h.size > h.options.MemoryLimit {
+			return h.spill()
+		}
+
+		k, ok, err := h.hashKey(row, true)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			h.table[k] = append(h.table[k], r)
+		}
+	}
+}
+
+func (h *hashJoin) read() ([]string, error) {
+	if !h.built {
+		if err := h.build(); err != nil {
+			return nil, err
+		}
+		h.built = true
+	}
+
+	if h.spilled != nil {
+		return h.spilled.read()
+	}
+
+	for len(h.pending) == 0 {
+		if h.probed {
+			return h.unmatched(), nil
+		}
+
+		row, err := h.left.Rows()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(row) == 0 {
+			h.probed = true
+
+			continue
+		}
+
+		if err := h.probe(row); err != nil {
+			return nil, err
+		}
+	}
+
+	row := h.pending[0]
+	h.pending = h.pending[1:]
+
+	return row, nil
+}
+
+// probe joins a row of the left table with the matching rows of the right one
+func (h *hashJoin) probe(row []string) error {
+	k, ok, err := h.hashKey(row, false)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		for _, r := range h.table[k] {
+			joined := concat(row, r.values)
+			matches, err := h.resolver.Resolve(joined)
+			if err != nil {
+				return err
+			}
+
+			if matches {
+				r.matched = true
+				h.pending = append(h.pending, joined)
+			}
+		}
+	}
+
+	if len(h.pending) == 0 && h.joinType == operators.LeftJoin {
+		h.pending = append(h.pending, concat(row, missing(len(h.right.Columns))))
+	}
+
+	return nil
+}
+
+// unmatched returns the rows of the right table of a RIGHT JOIN that no row matched, one by one
+func (h *hashJoin) unmatched() []string {
+	if h.joinType != operators.RightJoin {
+		return nil
+	}
+
+	for h.next < len(h.rows) {
+		r := h.rows[h.next]
+		h.next++
+
+		if !r.matched {
+			return concat(missing(len(h.left.Columns)), r.values)
+		}
+	}
+
+	return nil
+}
+
+func (h *hashJoin) close() error {
+	if h.spilled != nil {
+		return h.spilled.close()
+	}
+
+	return nil
+}
+
+// rowSize estimates the memory a row takes, the values and the headers of the strings and the slice
+func rowSize(row []string) int64 {
+	size := int64(24 + 16*len(row))
+	for _, v := range row {
+		size += int64(len(v))
+	}
+
+	return size
+}
+
+func concat(left, right []string) []string {
+	row := make([]string, 0, len(left)+len(right))
+	row = append(row, left...)
+
+	return append(row, right...)
+}
+
+func missing(n int) []string {
+	row := make([]string, n)
+	for i := range row {
+		row[i] = comparison.Missing
+	}
+
+	return row
+}
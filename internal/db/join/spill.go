@@ -0,0 +1,527 @@
+package join
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// partitions is the most partitions a file is split into at once
+const partitions = 64
+
+// maxDepth is how many times a partition that is still too big is split again. Partitions
+// that are too big after that have too many rows with the same key and are joined in chunks.
+const maxDepth = 3
+
+// spillFile is a temporary file of rows. A row is written as the number of its values followed
+// by the length and the bytes of every value, so that any value, including newlines and
+// comparison.Missing, is read back as it was written.
+type spillFile struct {
+	path string
+	rows int64
+	size int64
+	file *os.File
+	w    *bufio.Writer
+}
+
+type spillReader struct {
+	file *os.File
+	r    *bufio.Reader
+}
+
+// graceJoin joins tables that do not fit into memory. Both tables are split into partitions by
+// the hash of their keys so that the rows that can match are in partitions with the same number.
+// Partitions are joined one by one, the joined rows are written into a result file that is then
+// read row by row.
+type graceJoin struct {
+	h      *hashJoin
+	dir    string
+	result *spillFile
+	reader *spillReader
+	// files is the number of temporary files created so far, used to name them
+	files int
+}
+
+func newSpillFile(dir string, name string) (*spillFile, error) {
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return &spillFile{
+		path: f.Name(),
+		file: f,
+		w:    bufio.NewWriter(f),
+	}, nil
+}
+
+func (s *spillFile) write(row []string) error {
+	s.rows++
+	s.size += rowSize(row)
+
+	buf := binary.AppendUvarint(nil, uint64(len(row)))
+	for _, v := range row {
+		buf = binary.AppendUvarint(buf, uint64(len(v)))
+		buf = append(buf, v...)
+	}
+
+	_, err := s.w.Write(buf)
+
+	return err
+}
+
+// finish flushes the rows and closes the file so that it can be read
+func (s *spillFile) finish() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+
+	return s.file.Close()
+}
+
+func (s *spillFile) open() (*spillReader, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spillReader{
+		file: f,
+		r:    bufio.NewReader(f),
+	}, nil
+}
+
+// next returns the next row or nil after the last one
+func (r *spillReader) next() ([]string, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	row := make([]string, n)
+	for i := range row {
+		l, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return nil, err
+		}
+
+		v := make([]byte, l)
+		if _, err := io.ReadFull(r.r, v); err != nil {
+			return nil, err
+		}
+
+		row[i] = string(v)
+	}
+
+	return row, nil
+}
+
+func (r *spillReader) close() error {
+	return r.file.Close()
+}
+
+// spill moves the rows of the right table that are already read to disk and joins both
+// tables as a grace hash join. Without keys, there is only one partition and its rows are
+// compared chunk by chunk.
+func (h *hashJoin) spill() error {
+	dir, err := os.MkdirTemp(h.options.TempDir, "cig-join-")
+	if err != nil {
+		return fmt.Errorf("Could not create a temporary directory for the join: %w", err)
+	}
+
+	g := &graceJoin{h: h, dir: dir}
+	h.spilled = g
+
+	g.result, err = g.newFile()
+	if err != nil {
+		return err
+	}
+
+	count := partitions
+	if len(h.keys) == 0 {
+		count = 1
+	}
+
+	build, err := g.newFiles(count)
+	if err != nil {
+		return err
+	}
+
+	rows := h.rows
+	h.rows = nil
+	h.table = nil
+
+	for _, r := range rows {
+		if err := g.partition(r.values, true, build, 0); err != nil {
+			return err
+		}
+	}
+
+	if err := g.partitionTable(h.right.Rows, true, build); err != nil {
+		return err
+	}
+
+	probe, err := g.newFiles(count)
+	if err != nil {
+		return err
+	}
+
+	if err := g.partitionTable(h.left.Rows, false, probe); err != nil {
+		return err
+	}
+
+	for i := range build {
+		if err := g.joinPartition(build[i], probe[i], 1); err != nil {
+			return err
+		}
+	}
+
+	if err := g.result.finish(); err != nil {
+		return err
+	}
+
+	g.reader, err = g.result.open()
+
+	return err
+}
+
+func (g *graceJoin) newFile() (*spillFile, error) {
+	g.files++
+
+	return newSpillFile(g.dir, fmt.Sprintf("%d", g.files))
+}
+
+func (g *graceJoin) newFiles(count int) ([]*spillFile, error) {
+	files := make([]*spillFile, count)
+	for i := range files {
+		f, err := g.newFile()
+		if err != nil {
+			return nil, err
+		}
+
+		files[i] = f
+	}
+
+	return files, nil
+}
+
+func (g *graceJoin) partitionTable(rows func() ([]string, error), right bool, files []*spillFile) error {
+	for {
+		row, err := rows()
+		if err != nil {
+			return err
+		}
+
+		if len(row) == 0 {
+			break
+		}
+
+		if err := g.partition(row, right, files, 0); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		if err := f.finish(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// partition writes a row into the partition of its key. Rows with a NULL key never match,
+// they are written into the result right away if the join keeps them.
+func (g *graceJoin) partition(row []string, right bool, files []*spillFile, depth int) error {
+	k, ok, err := g.h.hashKey(row, right)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return g.unmatched(row, right)
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte{byte(depth)})
+	hash.Write([]byte(k))
+
+	return files[hash.Sum32()%uint32(len(files))].write(row)
+}
+
+// unmatched writes a row that nothing matched into the result if the join keeps it
+func (g *graceJoin) unmatched(row []string, right bool) error {
+	if right && g.h.joinType == operators.RightJoin {
+		return g.result.write(concat(missing(len(g.h.left.Columns)), row))
+	}
+
+	if !right && g.h.joinType == operators.LeftJoin {
+		return g.result.write(concat(row, missing(len(g.h.right.Columns))))
+	}
+
+	return nil
+}
+
+// joinPartition joins the rows of a partition of the right table with the rows of the
+// partition of the left table with the same number and removes both files
+func (g *graceJoin) joinPartition(build, probe *spillFile, depth int) error {
+	defer os.Remove(build.path)
+	defer os.Remove(probe.path)
+
+	if build.rows == 0 || probe.rows == 0 {
+		if err := g.copyUnmatched(build, true); err != nil {
+			return err
+		}
+
+		return g.copyUnmatched(probe, false)
+	}
+
+	if build.size > g.h.options.MemoryLimit && depth < maxDepth && len(g.h.keys) != 0 {
+		return g.repartition(build, probe, depth)
+	}
+
+	return g.joinChunks(build, probe)
+}
+
+func (g *graceJoin) copyUnmatched(f *spillFile, right bool) error {
+	if f.rows == 0 {
+		return nil
+	}
+
+	r, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	for {
+		row, err := r.next()
+		if err != nil {
+			return err
+		}
+
+		if row == nil {
+			return nil
+		}
+
+		if err := g.unmatched(row, right); err != nil {
+			return err
+		}
+	}
+}
+
+// repartition splits partitions that are still too big with a different hash, into as many
+// partitions as it takes for them to fit into memory
+func (g *graceJoin) repartition(build, probe *spillFile, depth int) error {
+	count := min(int(build.size/g.h.options.MemoryLimit)+2, partitions)
+
+	builds, err := g.newFiles(count)
+	if err != nil {
+		return err
+	}
+
+	probes, err := g.newFiles(count)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []struct {
+		from  *spillFile
+		to    []*spillFile
+		right bool
+	}{{build, builds, true}, {probe, probes, false}} {
+		r, err := p.from.open()
+		if err != nil {
+			return err
+		}
+
+		for {
+			row, err := r.next()
+			if err != nil {
+				r.close()
+				return err
+			}
+
+			if row == nil {
+				break
+			}
+
+			if err := g.partition(row, p.right, p.to, depth); err != nil {
+				r.close()
+				return err
+			}
+		}
+		r.close()
+
+		for _, f := range p.to {
+			if err := f.finish(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range builds {
+		if err := g.joinPartition(builds[i], probes[i], depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// joinChunks reads as many rows of the right partition as fit into memory, hashes them and
+// compares the whole left partition with them, until all rows of the right partition are read.
+// Which rows of the left partition matched is remembered for LEFT JOIN, one bool per row.
+func (g *graceJoin) joinChunks(build, probe *spillFile) error {
+	h := g.h
+
+	br, err := build.open()
+	if err != nil {
+		return err
+	}
+	defer br.close()
+
+	var probeMatched []bool
+	if h.joinType == operators.LeftJoin {
+		probeMatched = make([]bool, probe.rows)
+	}
+
+	for done := false; !done; {
+		chunk := make([]*buildRow, 0)
+		table := make(map[string][]*buildRow)
+		var size int64
+
+		for size <= h.options.MemoryLimit {
+			row, err := br.next()
+			if err != nil {
+				return err
+			}
+
+			if row == nil {
+				done = true
+				break
+			}
+
+			k, _, err := h.hashKey(row, true)
+			if err != nil {
+				return err
+			}
+
+			r := &buildRow{values: row}
+			chunk = append(chunk, r)
+			table[k] = append(table[k], r)
+			size += rowSize(row)
+		}
+
+		if len(chunk) == 0 {
+			break
+		}
+
+		if err := g.probeChunk(table, probe, probeMatched); err != nil {
+			return err
+		}
+
+		for _, r := range chunk {
+			if !r.matched {
+				if err := g.unmatched(r.values, true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if probeMatched == nil {
+		return nil
+	}
+
+	pr, err := probe.open()
+	if err != nil {
+		return err
+	}
+	defer pr.close()
+
+	for i := 0; ; i++ {
+		row, err := pr.next()
+		if err != nil {
+			return err
+		}
+
+		if row == nil {
+			return nil
+		}
+
+		if !probeMatched[i] {
+			if err := g.unmatched(row, false); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (g *graceJoin) probeChunk(table map[string][]*buildRow, probe *spillFile, probeMatched []bool) error {
+	pr, err := probe.open()
+	if err != nil {
+		return err
+	}
+	defer pr.close()
+
+	for i := 0; ; i++ {
+		row, err := pr.next()
+		if err != nil {
+			return err
+		}
+
+		if row == nil {
+			return nil
+		}
+
+		k, _, err := g.h.hashKey(row, false)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range table[k] {
+			joined := concat(row, r.values)
+			matches, err := g.h.resolver.Resolve(joined)
+			if err != nil {
+				return err
+			}
+
+			if matches {
+				r.matched = true
+				if probeMatched != nil {
+					probeMatched[i] = true
+				}
+
+				if err := g.result.write(joined); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (g *graceJoin) read() ([]string, error) {
+	return g.reader.next()
+}
+
+// close removes the temporary files of the join
+func (g *graceJoin) close() error {
+	if g.reader != nil {
+		g.reader.close()
+	} else if g.result != nil {
+		g.result.file.Close()
+	}
+
+	return os.RemoveAll(g.dir)
+}
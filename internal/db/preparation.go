@@ -2,19 +2,57 @@ package db
 
 import (
 	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
 	"github.com/MarioLegenda/cig/internal/db/fs"
+	"github.com/MarioLegenda/cig/internal/db/join"
 	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
-	"io"
 	"os"
 )
 
-func prepareRun(file syntaxStructure.FileDB, d *db) (io.ReadCloser, error) {
-	f, err := assignColumns(file.Path(), d)
+// prepareRun opens the file of the query and the files joined to it and returns the rows
+// the query searches. Columns of a query with joins are named by the alias of their file,
+// for example a.id.
+func prepareRun(file syntaxStructure.FileDB, d *db, nulls comparison.Nulls) (func() ([]string, error), error) {
+	table, err := openSource(file.Path(), file.Query(), d)
 	if err != nil {
-		return nil, fmt.Errorf("Opening file %s failed with error: %w", file.Path(), err)
+		return nil, err
+	}
+
+	if len(file.Joins()) != 0 {
+		table.Columns = qualifyColumns(file.Alias(), table.Columns)
 	}
 
-	return f, nil
+	for _, j := range file.Joins() {
+		right, err := openSource(j.Path(), j.Query(), d)
+		if err != nil {
+			return nil, err
+		}
+		right.Columns = qualifyColumns(j.Alias(), right.Columns)
+
+		table, err = join.New(table, right, j, nulls, join.Options{
+			TempDir:     d.options.TempDir,
+			MemoryLimit: d.options.MemoryLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		d.closers = append(d.closers, table.Close)
+	}
+
+	columns := make(metadataColumns, len(table.Columns))
+	for i, c := range table.Columns {
+		columns[i] = metadataColumn{
+			position: i,
+			name:     c,
+		}
+	}
+
+	d.metadata = fileMetadata{
+		columns:      columns,
+		originalPath: file.Path(),
+	}
+
+	return table.Rows, nil
 }
 
 func openFile(f string) (*os.File, error) {
@@ -26,44 +64,68 @@ func openFile(f string) (*os.File, error) {
 	return r, nil
 }
 
-func readColumns(f *os.File) (metadataColumns, error) {
-	lineReader := fs.NewLineReader(f)
-	cls, err := lineReader()
+// openTable opens a file and reads its column row, the rows of the returned table
+// are the rows after it
+func openTable(f string, d *db) (join.Table, error) {
+	r, err := openFile(f)
 	if err != nil {
-		return nil, err
+		return join.Table{}, fmt.Errorf("Opening file %s failed with error: %w", f, err)
 	}
+	d.closers = append(d.closers, r.Close)
 
-	columns := make(metadataColumns, 0)
-	for i, k := range cls {
-		columns = append(columns, metadataColumn{
-			position: i,
-			name:     k,
-		})
+	lineReader := fs.NewLineReader(r)
+	columns, err := lineReader()
+	if err != nil {
+		return join.Table{}, fmt.Errorf("Opening file %s failed with error: %w", f, err)
 	}
 
-	return columns, nil
+	return join.Table{
+		Rows:    lineReader,
+		Columns: columns,
+		Close:   r.Close,
+	}, nil
 }
 
-func assignColumns(f string, d *db) (*os.File, error) {
-	r, err := openFile(f)
-	if err != nil {
-		return nil, err
+// openSource opens the file of FROM or of a JOIN or, for derived tables and queries of WITH,
+// runs the query. The rows of a query are kept in memory, its columns are the columns it selects.
+func openSource(path string, query syntaxStructure.Query, d *db) (join.Table, error) {
+	if query == nil {
+		return openTable(path, d)
 	}
 
-	columns, err := readColumns(r)
-	if err != nil {
-		return nil, err
+	data := d.Run(query)
+	if data.Error != nil {
+		return join.Table{}, data.Error
 	}
 
-	if _, err := r.Seek(0, 0); err != nil {
-		return nil, err
-	}
+	next := 0
 
-	d.openFs = r
-	d.metadata = fileMetadata{
-		columns:      columns,
-		originalPath: f,
+	return join.Table{
+		Rows: func() ([]string, error) {
+			if next == len(data.Data) {
+				return nil, nil
+			}
+
+			row := make([]string, len(data.SelectedColumns))
+			for i, c := range data.SelectedColumns {
+				row[i] = data.Data[next][c]
+			}
+			next++
+
+			return row, nil
+		},
+		Columns: data.SelectedColumns,
+		Close: func() error {
+			return nil
+		},
+	}, nil
+}
+
+func qualifyColumns(alias string, columns []string) []string {
+	qualified := make([]string, len(columns))
+	for i, c := range columns {
+		qualified[i] = fmt.Sprintf("%s.%s", alias, c)
 	}
 
-	return r, nil
+	return qualified
 }
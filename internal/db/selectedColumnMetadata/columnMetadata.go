@@ -1,77 +1,217 @@
 package selectedColumnMetadata
 
+import "github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
+
 type columnMetadata struct {
-	positions []int
-	names     []string
+	selected   []Selected
+	names      []string
+	aggregates []Aggregate
+	distinct   bool
+	distinctOn []string
 }
 
 type ColumnMetadata interface {
-	Column(pos int) string
-	Position(name string) int
+	// Names are the names of the selected columns in the result
 	Names() []string
-	HasPosition(pos int) bool
+	// Selected are the columns of the result in the order of the SELECT list
+	Selected() []Selected
+	// Aggregates are all aggregate functions the query computes
+	Aggregates() []Aggregate
+	// Windows are the window functions the query computes, each of them only once
+	Windows() []Selected
+	Distinct() bool
+	DistinctOn() []string
+}
+
+// Selected is a column of the result. Its value is either taken from Column(), which
+// is a column of the file, the result of an aggregate function or of a Window() function,
+// or computed by Expression().
+type Selected interface {
+	Name() string
+	Column() string
+	Expression() syntaxStructure.Expression
+	Window() syntaxStructure.Window
+}
+
+// Aggregate is an aggregate function the query computes. Position is the position of
+// the column the function aggregates, -1 for COUNT(*).
+type Aggregate interface {
+	Column() string
+	Function() string
+	Name() string
+	DataType() string
+	Position() int
+}
+
+type selected struct {
+	name       string
+	column     string
+	expression syntaxStructure.Expression
+	window     syntaxStructure.Window
+}
+
+type aggregate struct {
+	column   string
+	function string
+	name     string
+	dataType string
+	position int
+}
+
+func (s selected) Name() string {
+	return s.name
+}
+
+func (s selected) Column() string {
+	return s.column
+}
+
+func (s selected) Expression() syntaxStructure.Expression {
+	return s.expression
+}
+
+func (s selected) Window() syntaxStructure.Window {
+	return s.window
+}
+
+func (a aggregate) Column() string {
+	return a.column
+}
+
+func (a aggregate) Function() string {
+	return a.function
+}
+
+func (a aggregate) Name() string {
+	return a.name
+}
+
+func (a aggregate) DataType() string {
+	return a.dataType
+}
+
+func (a aggregate) Position() int {
+	return a.position
 }
 
 func (cm columnMetadata) Names() []string {
 	return cm.names
 }
 
-func (cm columnMetadata) Column(pos int) string {
-	for p, s := range cm.positions {
-		if pos == s {
-			return cm.names[p]
-		}
-	}
+func (cm columnMetadata) Selected() []Selected {
+	return cm.selected
+}
 
-	return ""
+func (cm columnMetadata) Aggregates() []Aggregate {
+	return cm.aggregates
 }
 
-func (cm columnMetadata) Position(name string) int {
-	for p, s := range cm.names {
-		if s == name {
-			return cm.positions[p]
+func (cm columnMetadata) Windows() []Selected {
+	windows := make([]Selected, 0)
+	for _, s := range cm.selected {
+		if s.Window() == nil {
+			continue
 		}
-	}
 
-	return -1
-}
+		duplicated := false
+		for _, w := range windows {
+			if w.Column() == s.Column() {
+				duplicated = true
+			}
+		}
 
-func (cm columnMetadata) HasPosition(pos int) bool {
-	for _, s := range cm.positions {
-		if s == pos {
-			return true
+		if !duplicated {
+			windows = append(windows, s)
 		}
 	}
 
-	return false
+	return windows
+}
+
+func (cm columnMetadata) Distinct() bool {
+	return cm.distinct
 }
 
-func New(selectedColumns []string, allColumns []string) ColumnMetadata {
-	positions := make([]int, 0)
+func (cm columnMetadata) DistinctOn() []string {
+	return cm.distinctOn
+}
+
+func New(column syntaxStructure.Column, allColumns []string) ColumnMetadata {
+	selectedColumns := make([]Selected, 0)
 	names := make([]string, 0)
-	allSelected := false
-	if len(selectedColumns) == 1 && selectedColumns[0] == "*" {
-		allSelected = true
-	}
 
-	for i, s := range allColumns {
-		for _, t := range selectedColumns {
-			if allSelected {
-				positions = append(positions, i)
-				names = append(names, s)
+	for _, s := range column.Selected() {
+		if s.Function() != "" {
+			selectedColumns = append(selectedColumns, selected{
+				name:   s.Name(),
+				column: syntaxStructure.AggregateName(s.Function(), s.Column()),
+			})
+			names = append(names, s.Name())
 
-				continue
+			continue
+		}
+
+		if s.Window() != nil {
+			selectedColumns = append(selectedColumns, selected{
+				name:   s.Name(),
+				column: s.Column(),
+				window: s.Window(),
+			})
+			names = append(names, s.Name())
+
+			continue
+		}
+
+		if s.Expression() != nil {
+			selectedColumns = append(selectedColumns, selected{
+				name:       s.Name(),
+				expression: s.Expression(),
+			})
+			names = append(names, s.Name())
+
+			continue
+		}
+
+		for _, c := range allColumns {
+			if s.Column() == "*" || s.Column() == c {
+				name := c
+				if s.Column() != "*" {
+					name = s.Name()
+				}
+
+				selectedColumns = append(selectedColumns, selected{
+					name:   name,
+					column: c,
+				})
+				names = append(names, name)
 			}
+		}
+	}
 
-			if t == s {
-				positions = append(positions, i)
-				names = append(names, s)
+	aggregates := make([]Aggregate, len(column.Aggregates()))
+	for i, a := range column.Aggregates() {
+		// the position of COUNT(*) or of a column that does not exist is -1
+		position := -1
+		for p, c := range allColumns {
+			if c == a.Column() {
+				position = p
 			}
 		}
+
+		aggregates[i] = aggregate{
+			column:   a.Column(),
+			function: a.Function(),
+			name:     syntaxStructure.AggregateName(a.Function(), a.Column()),
+			dataType: a.DataType(),
+			position: position,
+		}
 	}
 
 	return columnMetadata{
-		positions: positions,
-		names:     names,
+		selected:   selectedColumns,
+		names:      names,
+		aggregates: aggregates,
+		distinct:   column.Distinct(),
+		distinctOn: column.DistinctOn(),
 	}
 }
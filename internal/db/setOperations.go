@@ -0,0 +1,114 @@
+package db
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
+	"github.com/MarioLegenda/cig/pkg"
+	"strings"
+)
+
+// runSetOperations runs the queries combined with the first one and combines their rows.
+// INTERSECT binds tighter than UNION and EXCEPT, which are applied from left to right.
+// The combined rows are named by the columns of the first query.
+func (d *db) runSetOperations(first Data, setOperations []syntaxStructure.SetOperation) Data {
+	// terms are the results of the queries that INTERSECT combined, joined by the
+	// operators between them
+	terms := [][]map[string]string{first.Data}
+	termOperators := make([]string, 0)
+
+	for _, o := range setOperations {
+		data := d.run(o.Query())
+		if data.Error != nil {
+			return newData(first.SelectedColumns, first.AllColumns, nil, data.Error)
+		}
+
+		if len(data.SelectedColumns) != len(first.SelectedColumns) {
+			return newData(first.SelectedColumns, first.AllColumns, nil, fmt.Errorf("Queries combined with %s must select the same number of columns, got %d and %d: %w", strings.ToUpper(o.Operator()), len(first.SelectedColumns), len(data.SelectedColumns), pkg.InvalidSetOperation))
+		}
+
+		rows := renameColumns(data.Data, data.SelectedColumns, first.SelectedColumns)
+
+		if o.Operator() == operators.IntersectOperator {
+			last := len(terms) - 1
+			terms[last] = combine(operators.IntersectOperator, terms[last], rows, first.SelectedColumns)
+
+			continue
+		}
+
+		terms = append(terms, rows)
+		termOperators = append(termOperators, o.Operator())
+	}
+
+	result := terms[0]
+	for i, operator := range termOperators {
+		result = combine(operator, result, terms[i+1], first.SelectedColumns)
+	}
+
+	return newData(first.SelectedColumns, first.AllColumns, result, nil)
+}
+
+// renameColumns names the values of rows by the columns of the first query, by position
+func renameColumns(rows []map[string]string, from, to []string) []map[string]string {
+	renamed := make([]map[string]string, len(rows))
+	for i, row := range rows {
+		r := make(map[string]string, len(to))
+		for j, c := range to {
+			r[c] = row[from[j]]
+		}
+
+		renamed[i] = r
+	}
+
+	return renamed
+}
+
+// combine combines the rows of two queries. UNION ALL keeps all rows, UNION keeps every row once,
+// in the order it first appears. INTERSECT and EXCEPT keep every row of the left query once,
+// if it is or it is not in the right one.
+func combine(operator string, left, right []map[string]string, columns []string) []map[string]string {
+	if operator == operators.UnionAllOperator {
+		return append(left, right...)
+	}
+
+	result := make([]map[string]string, 0)
+	seen := make(map[string]bool)
+
+	if operator == operators.UnionOperator {
+		for _, rows := range [][]map[string]string{left, right} {
+			for _, row := range rows {
+				k := rowKey(row, columns)
+				if !seen[k] {
+					seen[k] = true
+					result = append(result, row)
+				}
+			}
+		}
+
+		return result
+	}
+
+	inRight := make(map[string]bool, len(right))
+	for _, row := range right {
+		inRight[rowKey(row, columns)] = true
+	}
+
+	for _, row := range left {
+		k := rowKey(row, columns)
+		if !seen[k] && inRight[k] == (operator == operators.IntersectOperator) {
+			seen[k] = true
+			result = append(result, row)
+		}
+	}
+
+	return result
+}
+
+func rowKey(row map[string]string, columns []string) string {
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = row[c]
+	}
+
+	return strings.Join(values, "\x00")
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
+	"github.com/MarioLegenda/cig/pkg"
+)
+
+// runSubqueries runs the subqueries of IN (SELECT ...) and EXISTS (SELECT ...) before the query
+// reads any rows and returns the condition with their rows. Every subquery runs only once.
+func (d *db) runSubqueries(c syntaxStructure.Condition) (syntaxStructure.Condition, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	if c.IsLogical() {
+		left, err := d.runSubqueries(c.Left())
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := d.runSubqueries(c.Right())
+		if err != nil {
+			return nil, err
+		}
+
+		return syntaxStructure.NewLogicalCondition(c.Operator(), left, right), nil
+	}
+
+	if c.Value() == nil || c.Value().Subquery() == nil {
+		return c, nil
+	}
+
+	data := d.run(c.Value().Subquery().Query())
+	if data.Error != nil {
+		return nil, data.Error
+	}
+
+	// columns that are not in the file are not selected, but the rows of a subquery are read by
+	// the position of its columns
+	if selected, ok := selectedCount(c.Value().Subquery().Query().Column()); ok && selected != len(data.SelectedColumns) {
+		return nil, fmt.Errorf("Expected the subquery to select %d columns, got %d. Selected columns have to be columns of its file: %w", selected, len(data.SelectedColumns), pkg.InvalidSubquery)
+	}
+
+	rows := make([][]string, len(data.Data))
+	for i, row := range data.Data {
+		values := make([]string, len(data.SelectedColumns))
+		for j, name := range data.SelectedColumns {
+			values[j] = row[name]
+		}
+
+		rows[i] = values
+	}
+
+	return syntaxStructure.NewCondition(c.Column(), c.Operator(), syntaxStructure.NewConditionSubqueryValue(c.Value().Subquery(), rows, "")), nil
+}
+
+// selectedCount is the number of columns a query selects. It is false if the query selects *,
+// which selects as many columns as there are in its file.
+func selectedCount(column syntaxStructure.Column) (int, bool) {
+	for _, s := range column.Selected() {
+		if s.Column() == "*" && s.Function() == "" {
+			return 0, false
+		}
+	}
+
+	return len(column.Selected()), true
+}
@@ -0,0 +1,277 @@
+package job
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/db/selectedColumnMetadata"
+	"github.com/MarioLegenda/cig/internal/syntax/aggregates"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"math/big"
+	"strconv"
+)
+
+// accumulator folds the values of a single aggregate function. NULL values are
+// never given to an accumulator, except for COUNT(*) which counts rows.
+type accumulator interface {
+	add(value string) error
+	result() string
+}
+
+type countAccumulator struct {
+	count int64
+}
+
+type sumAccumulator struct {
+	dataType   string
+	intSum     int64
+	floatSum   float64
+	decimalSum decimalSum
+	hasValue   bool
+}
+
+type avgAccumulator struct {
+	dataType   string
+	sum        float64
+	decimalSum decimalSum
+	count      int64
+}
+
+// decimalSum adds decimals exactly. Its scale is the largest scale of the added values.
+type decimalSum struct {
+	sum   big.Rat
+	scale int
+}
+
+type extremeAccumulator struct {
+	dataType string
+	isMax    bool
+	value    string
+	hasValue bool
+}
+
+func (a *countAccumulator) add(value string) error {
+	a.count++
+
+	return nil
+}
+
+func (a *countAccumulator) result() string {
+	return strconv.FormatInt(a.count, 10)
+}
+
+func (s *decimalSum) add(dataType, value string) error {
+	v, scale, err := dataTypes.ParseDecimal(dataType, value)
+	if err != nil {
+		return err
+	}
+
+	s.sum.Add(&s.sum, v)
+	if scale > s.scale {
+		s.scale = scale
+	}
+
+	return nil
+}
+
+func (a *sumAccumulator) add(value string) error {
+	a.hasValue = true
+
+	if dataTypes.Base(a.dataType) == dataTypes.Decimal {
+		return a.decimalSum.add(a.dataType, value)
+	}
+
+	if a.dataType == dataTypes.Int {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Value %s is not a valid integer", value)
+		}
+
+		// the sum is an int like the column, a sum that does not fit into an int64 is an error
+		sum := a.intSum + v
+		if (a.intSum^sum)&(v^sum) < 0 {
+			return fmt.Errorf("Integer overflow in SUM, %d + %d does not fit into an integer", a.intSum, v)
+		}
+
+		a.intSum = sum
+
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("Value %s is not a valid number", value)
+	}
+
+	a.floatSum += v
+
+	return nil
+}
+
+// result of SUM over no values is NULL, returned as an empty string
+func (a *sumAccumulator) result() string {
+	if !a.hasValue {
+		return ""
+	}
+
+	if a.dataType == dataTypes.Int {
+		return strconv.FormatInt(a.intSum, 10)
+	}
+
+	if dataTypes.Base(a.dataType) == dataTypes.Decimal {
+		return a.decimalSum.sum.FloatString(a.decimalSum.scale)
+	}
+
+	return strconv.FormatFloat(a.floatSum, 'f', -1, 64)
+}
+
+func (a *avgAccumulator) add(value string) error {
+	if dataTypes.Base(a.dataType) == dataTypes.Decimal {
+		a.count++
+
+		return a.decimalSum.add(a.dataType, value)
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("Value %s is not a valid number", value)
+	}
+
+	a.sum += v
+	a.count++
+
+	return nil
+}
+
+func (a *avgAccumulator) result() string {
+	if a.count == 0 {
+		return ""
+	}
+
+	if dataTypes.Base(a.dataType) == dataTypes.Decimal {
+		avg := new(big.Rat).Quo(&a.decimalSum.sum, new(big.Rat).SetInt64(a.count))
+
+		return avg.FloatString(a.decimalSum.scale + dataTypes.DivisionScale)
+	}
+
+	return strconv.FormatFloat(a.sum/float64(a.count), 'f', -1, 64)
+}
+
+func (a *extremeAccumulator) add(value string) error {
+	if !a.hasValue {
+		if err := validateExtremeValue(value, a.dataType); err != nil {
+			return err
+		}
+
+		a.value = value
+		a.hasValue = true
+
+		return nil
+	}
+
+	replace, err := isLess(value, a.value, a.dataType)
+	if a.isMax {
+		replace, err = isLess(a.value, value, a.dataType)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if replace {
+		a.value = value
+	}
+
+	return nil
+}
+
+func (a *extremeAccumulator) result() string {
+	return a.value
+}
+
+func validateExtremeValue(value, dataType string) error {
+	_, err := isLess(value, value, dataType)
+
+	return err
+}
+
+// isLess compares two values by the data type of the aggregated column. Dates and
+// times are compared chronologically. Without a data type, values are compared as strings.
+func isLess(a, b, dataType string) (bool, error) {
+	switch dataType {
+	case dataTypes.Int:
+		v1, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("Value %s is not a valid integer", a)
+		}
+
+		v2, err := strconv.ParseInt(b, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("Value %s is not a valid integer", b)
+		}
+
+		return v1 < v2, nil
+	case dataTypes.Float:
+		v1, err := strconv.ParseFloat(a, 64)
+		if err != nil {
+			return false, fmt.Errorf("Value %s is not a valid float", a)
+		}
+
+		v2, err := strconv.ParseFloat(b, 64)
+		if err != nil {
+			return false, fmt.Errorf("Value %s is not a valid float", b)
+		}
+
+		return v1 < v2, nil
+	}
+
+	if dataTypes.Base(dataType) == dataTypes.Decimal {
+		v1, _, err := dataTypes.ParseDecimal(dataType, a)
+		if err != nil {
+			return false, err
+		}
+
+		v2, _, err := dataTypes.ParseDecimal(dataType, b)
+		if err != nil {
+			return false, err
+		}
+
+		return v1.Cmp(v2) < 0, nil
+	}
+
+	if dataType == dataTypes.Bool {
+		c, err := comparison.CompareBools(a, b)
+
+		return c < 0, err
+	}
+
+	if dataTypes.IsTemporal(dataType) {
+		v1, err := dataTypes.ParseTime(dataType, a)
+		if err != nil {
+			return false, err
+		}
+
+		v2, err := dataTypes.ParseTime(dataType, b)
+		if err != nil {
+			return false, err
+		}
+
+		return v1.Before(v2), nil
+	}
+
+	return a < b, nil
+}
+
+func newAccumulator(a selectedColumnMetadata.Aggregate) accumulator {
+	switch a.Function() {
+	case aggregates.Count:
+		return &countAccumulator{}
+	case aggregates.Sum:
+		return &sumAccumulator{dataType: a.DataType()}
+	case aggregates.Avg:
+		return &avgAccumulator{dataType: a.DataType()}
+	case aggregates.Min:
+		return &extremeAccumulator{dataType: a.DataType()}
+	}
+
+	return &extremeAccumulator{dataType: a.DataType(), isMax: true}
+}
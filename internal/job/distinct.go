@@ -0,0 +1,58 @@
+package job
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/conditionResolver"
+	"strings"
+)
+
+// distinctRows keeps the first row of every distinct combination of values of the
+// selected columns, or of the DISTINCT ON columns. Rows are already sorted so, as
+// in Postgres, ORDER BY decides which row of DISTINCT ON is kept.
+func distinctRows(rows [][]string, distinctOn []string, p *projection, metadata conditionResolver.ColumnMetadata) ([][]string, error) {
+	positions := make([]int, len(distinctOn))
+	for i, c := range distinctOn {
+		position := metadata.Position(c)
+		if position == -1 {
+			return nil, fmt.Errorf("Invalid DISTINCT ON column. Column %s not found", c)
+		}
+
+		positions[i] = position
+	}
+
+	values := make([]string, len(positions))
+	key := func(row []string) (string, error) {
+		if len(positions) == 0 {
+			projected, err := p.values(row)
+			if err != nil {
+				return "", err
+			}
+
+			return strings.Join(projected, "\x00"), nil
+		}
+
+		for i, position := range positions {
+			values[i] = row[position]
+		}
+
+		return strings.Join(values, "\x00"), nil
+	}
+
+	seen := make(map[string]struct{})
+	distinct := make([][]string, 0)
+	for _, row := range rows {
+		k, err := key(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := seen[k]; ok {
+			continue
+		}
+
+		seen[k] = struct{}{}
+		distinct = append(distinct, row)
+	}
+
+	return distinct, nil
+}
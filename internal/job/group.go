@@ -0,0 +1,172 @@
+package job
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/db/conditionResolver"
+	"github.com/MarioLegenda/cig/internal/db/selectedColumnMetadata"
+	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
+	"strings"
+)
+
+// groups is the hash aggregation of rows by the values of the GROUP BY columns. Every
+// group has its own accumulators. Without GROUP BY, all rows are a single group.
+type groups struct {
+	columns    []string
+	positions  []int
+	aggregates []selectedColumnMetadata.Aggregate
+	nulls      comparison.Nulls
+
+	// keys keep the order in which groups are found
+	keys   []string
+	groups map[string]*group
+}
+
+type group struct {
+	values       []string
+	accumulators []accumulator
+}
+
+func newGroups(groupBy syntaxStructure.GroupBy, aggregates []selectedColumnMetadata.Aggregate, metadata conditionResolver.ColumnMetadata, nulls comparison.Nulls) (*groups, error) {
+	columns := make([]string, 0)
+	positions := make([]int, 0)
+	if groupBy != nil {
+		for _, c := range groupBy.Columns() {
+			p := metadata.Position(c)
+			if p == -1 {
+				return nil, fmt.Errorf("Invalid GROUP BY column. Column %s not found", c)
+			}
+
+			columns = append(columns, c)
+			positions = append(positions, p)
+		}
+	}
+
+	for _, a := range aggregates {
+		if a.Position() == -1 && a.Column() != "*" {
+			return nil, fmt.Errorf("Invalid column of %s. Column %s not found", a.Name(), a.Column())
+		}
+	}
+
+	g := &groups{
+		columns:    columns,
+		positions:  positions,
+		aggregates: aggregates,
+		nulls:      nulls,
+		keys:       make([]string, 0),
+		groups:     make(map[string]*group),
+	}
+
+	// aggregates without GROUP BY always produce exactly one row, even if no row matched
+	if len(columns) == 0 {
+		g.newGroup("", []string{})
+	}
+
+	return g, nil
+}
+
+func (g *groups) newGroup(key string, values []string) *group {
+	accumulators := make([]accumulator, len(g.aggregates))
+	for i, a := range g.aggregates {
+		accumulators[i] = newAccumulator(a)
+	}
+
+	gr := &group{
+		values:       values,
+		accumulators: accumulators,
+	}
+
+	g.keys = append(g.keys, key)
+	g.groups[key] = gr
+
+	return gr
+}
+
+func (g *groups) add(lines []string) error {
+	values := make([]string, len(g.positions))
+	for i, p := range g.positions {
+		values[i] = lines[p]
+	}
+
+	key := strings.Join(values, "\x00")
+	gr, ok := g.groups[key]
+	if !ok {
+		gr = g.newGroup(key, values)
+	}
+
+	for i, a := range g.aggregates {
+		// COUNT(*)
+		if a.Position() == -1 {
+			if err := gr.accumulators[i].add(""); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		value := lines[a.Position()]
+		if g.nulls.IsNull(value) {
+			continue
+		}
+
+		if err := gr.accumulators[i].add(value); err != nil {
+			return fmt.Errorf("Could not compute %s: %w", a.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// rows returns a row for every group that satisfies HAVING. A row holds the values of the
+// GROUP BY columns followed by the results of the aggregate functions, the returned metadata
+// describes these rows so that they can be sorted like the rows of the file.
+func (g *groups) rows(having syntaxStructure.Condition) ([][]string, conditionResolver.ColumnMetadata, error) {
+	names := make([]string, 0)
+	names = append(names, g.columns...)
+	for _, a := range g.aggregates {
+		names = append(names, a.Name())
+	}
+
+	positions := make([]int, len(names))
+	for i := range names {
+		positions[i] = i
+	}
+
+	metadata := conditionResolver.NewColumnMetadata(positions, names)
+
+	var resolver conditionResolver.Resolver
+	if having != nil {
+		r, err := conditionResolver.NewResolver(having, metadata, g.nulls)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		resolver = r
+	}
+
+	rows := make([][]string, 0)
+	for _, key := range g.keys {
+		gr := g.groups[key]
+
+		row := make([]string, 0, len(names))
+		row = append(row, gr.values...)
+		for _, a := range gr.accumulators {
+			row = append(row, a.result())
+		}
+
+		if resolver != nil {
+			ok, err := resolver.Resolve(row)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if !ok {
+				continue
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, metadata, nil
+}
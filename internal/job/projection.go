@@ -0,0 +1,90 @@
+package job
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/db/conditionResolver"
+	"github.com/MarioLegenda/cig/internal/db/expression"
+	"github.com/MarioLegenda/cig/internal/db/selectedColumnMetadata"
+)
+
+// projection computes the selected columns of a row. Columns are taken from the row as
+// they are, expressions are evaluated and are empty if their value is NULL. Columns of
+// a joined file without a matching row are empty too.
+type projection struct {
+	names      []string
+	positions  []int
+	evaluators []expression.Evaluator
+}
+
+func newProjection(selectedColumns selectedColumnMetadata.ColumnMetadata, metadata conditionResolver.ColumnMetadata, nulls comparison.Nulls) (*projection, error) {
+	selected := selectedColumns.Selected()
+	p := &projection{
+		names:      make([]string, len(selected)),
+		positions:  make([]int, len(selected)),
+		evaluators: make([]expression.Evaluator, len(selected)),
+	}
+
+	for i, s := range selected {
+		p.names[i] = s.Name()
+		p.positions[i] = -1
+
+		if s.Expression() != nil {
+			e, err := expression.NewEvaluator(s.Expression(), metadata, nulls)
+			if err != nil {
+				return nil, err
+			}
+
+			p.evaluators[i] = e
+
+			continue
+		}
+
+		position := metadata.Position(s.Column())
+		if position == -1 {
+			return nil, fmt.Errorf("Column %s not found. This should not happen and is a bug", s.Column())
+		}
+
+		p.positions[i] = position
+	}
+
+	return p, nil
+}
+
+func (p *projection) values(row []string) ([]string, error) {
+	values := make([]string, len(p.names))
+	for i := range p.names {
+		if p.evaluators[i] == nil {
+			if v := row[p.positions[i]]; v != comparison.Missing {
+				values[i] = v
+			}
+
+			continue
+		}
+
+		value, isNull, err := p.evaluators[i].Evaluate(row)
+		if err != nil {
+			return nil, fmt.Errorf("Could not compute %s: %w", p.names[i], err)
+		}
+
+		if !isNull {
+			values[i] = value
+		}
+	}
+
+	return values, nil
+}
+
+func (p *projection) result(row []string) (map[string]string, error) {
+	values, err := p.values(row)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string)
+	for i, name := range p.names {
+		res[name] = values[i]
+	}
+
+	return res, nil
+}
@@ -3,11 +3,10 @@ package job
 import (
 	"context"
 	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
 	"github.com/MarioLegenda/cig/internal/db/conditionResolver"
-	"github.com/MarioLegenda/cig/internal/db/fs"
 	"github.com/MarioLegenda/cig/internal/db/selectedColumnMetadata"
 	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
-	"io"
 )
 
 func SearchFactory(
@@ -15,16 +14,40 @@ func SearchFactory(
 	metadata conditionResolver.ColumnMetadata,
 	condition syntaxStructure.Condition,
 	constraints syntaxStructure.StructureConstraints,
-	f io.ReadCloser,
+	nulls comparison.Nulls,
+	lineReader func() ([]string, error),
 ) SearchFn {
 	return func(id int, ctx context.Context) (SearchResult, error) {
 		results := make(SearchResult, 0)
-		lineReader := fs.NewLineReader(f)
 		collectedLines := make([][]string, 0)
-		// skip the column row (first row)
-		_, err := lineReader()
-		if err != nil {
-			return nil, fmt.Errorf("Error in job %d while reading file. Trying to skip the first row but failed: %w", id, err)
+
+		var err error
+		var resolver conditionResolver.Resolver
+		if condition != nil {
+			resolver, err = conditionResolver.NewResolver(condition, metadata, nulls)
+			if err != nil {
+				return nil, fmt.Errorf("Error in job %d while preparing the condition: %w", id, err)
+			}
+		}
+
+		var grouping *groups
+		if len(selectedColumns.Aggregates()) != 0 || constraints.GroupBy() != nil {
+			grouping, err = newGroups(constraints.GroupBy(), selectedColumns.Aggregates(), metadata, nulls)
+			if err != nil {
+				return nil, fmt.Errorf("Error in job %d while preparing the aggregation: %w", id, err)
+			}
+		}
+
+		// rows are folded into the groups as they are read so that aggregating
+		// a big file does not keep it in memory
+		collect := func(lines []string) error {
+			if grouping != nil {
+				return grouping.add(lines)
+			}
+
+			collectedLines = append(collectedLines, lines)
+
+			return nil
 		}
 
 		collectionFinished := false
@@ -50,17 +73,19 @@ func SearchFactory(
 					break
 				}
 
-				if condition != nil {
-					ok, err := conditionResolver.ResolveCondition(condition, metadata, lines)
+				if resolver != nil {
+					ok, err := resolver.Resolve(lines)
 					if err != nil {
 						return nil, fmt.Errorf("Error in job %d while reading from the file: %w", id, err)
 					}
 
-					if ok {
-						collectedLines = append(collectedLines, lines)
+					if !ok {
+						continue
 					}
-				} else {
-					collectedLines = append(collectedLines, lines)
+				}
+
+				if err := collect(lines); err != nil {
+					return nil, fmt.Errorf("Error in job %d while aggregating: %w", id, err)
 				}
 			}
 		}
@@ -69,8 +94,43 @@ func SearchFactory(
 		offset := constraints.Offset()
 		orderBy := constraints.OrderBy()
 
+		// grouped rows are sorted and paginated like the rows of the file but they
+		// have their own columns
+		rowMetadata := metadata
+		if grouping != nil {
+			collectedLines, rowMetadata, err = grouping.rows(constraints.Having())
+			if err != nil {
+				return nil, fmt.Errorf("Error in job %d while filtering groups: %w", id, err)
+			}
+		}
+
+		if windows := selectedColumns.Windows(); len(windows) != 0 {
+			collectedLines, rowMetadata, err = computeWindows(collectedLines, windows, rowMetadata, nulls)
+			if err != nil {
+				return nil, fmt.Errorf("Error in job %d while computing window functions: %w", id, err)
+			}
+		}
+
 		if orderBy != nil {
-			sortResults(collectedLines, orderBy, metadata)
+			var positions []int
+			collectedLines, positions, err = appendSortKeys(collectedLines, orderBy, rowMetadata, nulls)
+			if err != nil {
+				return nil, fmt.Errorf("Error in job %d while sorting: %w", id, err)
+			}
+
+			sortResults(collectedLines, orderBy, positions, nulls)
+		}
+
+		p, err := newProjection(selectedColumns, rowMetadata, nulls)
+		if err != nil {
+			return nil, fmt.Errorf("Error in job %d while preparing the selected columns: %w", id, err)
+		}
+
+		if selectedColumns.Distinct() {
+			collectedLines, err = distinctRows(collectedLines, selectedColumns.DistinctOn(), p, rowMetadata)
+			if err != nil {
+				return nil, fmt.Errorf("Error in job %d while removing duplicates: %w", id, err)
+			}
 		}
 
 		var currentCollectedOffset int64
@@ -85,7 +145,7 @@ func SearchFactory(
 				break
 			}
 
-			res, err := createResult(line, selectedColumns)
+			res, err := p.result(line)
 			if err != nil {
 				return nil, fmt.Errorf("Error in job %d while reading from the file: %w", id, err)
 			}
@@ -96,18 +156,3 @@ func SearchFactory(
 		return results, nil
 	}
 }
-
-func createResult(lines []string, selectedColumns selectedColumnMetadata.ColumnMetadata) (map[string]string, error) {
-	res := make(map[string]string)
-	for linePosition, line := range lines {
-		if selectedColumns.HasPosition(linePosition) {
-			columnName := selectedColumns.Column(linePosition)
-			if columnName == "" {
-				return nil, fmt.Errorf("Column not found for position %d. This should not happen and is a bug", linePosition)
-			}
-			res[columnName] = line
-		}
-	}
-
-	return res, nil
-}
@@ -1,103 +1,177 @@
 package job
 
 import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
 	"github.com/MarioLegenda/cig/internal/db/conditionResolver"
+	"github.com/MarioLegenda/cig/internal/db/expression"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
 	"github.com/MarioLegenda/cig/internal/syntax/operators"
 	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
 	"sort"
-	"strconv"
 )
 
-type comparableConstraint interface {
-	int64 | float64 | string
-}
-
-type By func(p1, p2 []string) bool
+// nullSortKey is the sort key of a NULL value. It cannot be read from a file so it is
+// never confused with a real value.
+const nullSortKey = "\x00"
+
+// sortKey computes the value a row is sorted by if the ORDER BY column cannot be sorted
+// by its value in the row
+type sortKey func(row []string) (string, error)
+
+// appendSortKeys computes the ORDER BY columns that are expressions or dates and times and
+// appends them to every row so that they are sorted like any other column. Dates and times
+// are converted to a form that sorts chronologically. The projection only reads the columns
+// it knows about so the appended values are never returned. It returns the position every
+// ORDER BY column is sorted by.
+func appendSortKeys(rows [][]string, orderBy syntaxStructure.OrderBy, metadata conditionResolver.ColumnMetadata, nulls comparison.Nulls) ([][]string, []int, error) {
+	columns := orderBy.Columns()
+	positions := make([]int, len(columns))
+	keys := make([]sortKey, 0)
+	for i, c := range columns {
+		position := metadata.Position(c.Column())
+		positions[i] = position
+
+		key, err := newSortKey(c, position, metadata, nulls)
+		if err != nil {
+			return nil, nil, err
+		}
 
-type resultSorter struct {
-	results [][]string
-	by      By
-}
+		if key != nil {
+			positions[i] = len(metadata.ColumnNames()) + len(keys)
+			keys = append(keys, key)
+		}
+	}
 
-func (s *resultSorter) Len() int {
-	return len(s.results)
-}
+	if len(keys) == 0 {
+		return rows, positions, nil
+	}
 
-func (s *resultSorter) Swap(i, j int) {
-	s.results[i], s.results[j] = s.results[j], s.results[i]
-}
+	for i, row := range rows {
+		extended := make([]string, len(row), len(row)+len(keys))
+		copy(extended, row)
+		for _, key := range keys {
+			value, err := key(row)
+			if err != nil {
+				return nil, nil, err
+			}
 
-// Less is part of sort.Interface. It is implemented by calling the "by" closure in the sorter.
-func (s *resultSorter) Less(i, j int) bool {
-	return s.by(s.results[i], s.results[j])
-}
+			extended = append(extended, value)
+		}
 
-func (by By) Sort(results [][]string) {
-	ps := &resultSorter{
-		results: results,
-		by:      by,
+		rows[i] = extended
 	}
 
-	sort.Sort(ps)
+	return rows, positions, nil
 }
 
-func sortResults(result [][]string, orderBy syntaxStructure.OrderBy, metadata conditionResolver.ColumnMetadata) [][]string {
-	orderByColumns := orderBy.Columns()
-	direction := orderBy.Direction()
-	for _, c := range orderByColumns {
-		currentPosition := metadata.Position(c.Column())
+func newSortKey(c syntaxStructure.OrderByColumn, position int, metadata conditionResolver.ColumnMetadata, nulls comparison.Nulls) (sortKey, error) {
+	value := func(row []string) (string, bool, error) {
+		return row[position], nulls.IsNull(row[position]), nil
+	}
 
-		fn := func(p1, p2 []string) bool {
-			v1int, p1IntErr := strconv.ParseInt(p1[currentPosition], 10, 64)
-			v2int, p2IntErr := strconv.ParseInt(p2[currentPosition], 10, 64)
+	if c.Expression() != nil && position == -1 {
+		evaluator, err := expression.NewEvaluator(c.Expression(), metadata, nulls)
+		if err != nil {
+			return nil, err
+		}
 
-			if p1IntErr != nil && p2IntErr != nil {
-				if direction == operators.Desc {
-					return v1int > v2int
+		if !dataTypes.IsTemporal(c.DataType()) {
+			return func(row []string) (string, error) {
+				value, isNull, err := evaluator.Evaluate(row)
+				if isNull {
+					return nullSortKey, err
 				}
 
-				return v1int < v2int
-			}
-
-			v1float, p1FloatErr := strconv.ParseFloat(p1[currentPosition], 64)
-			v2float, p2FloatErr := strconv.ParseFloat(p2[currentPosition], 64)
+				return value, err
+			}, nil
+		}
 
-			if p1FloatErr != nil && p2FloatErr != nil {
-				if direction == operators.Desc {
-					return v1float > v2float
-				}
+		value = evaluator.Evaluate
+	} else if position == -1 {
+		return nil, fmt.Errorf("Invalid column to sort. Column %s not found", c.Column())
+	}
 
-				return v1float < v2float
+	if dataTypes.IsTemporal(c.DataType()) {
+		return func(row []string) (string, error) {
+			value, isNull, err := value(row)
+			if err != nil || isNull {
+				return nullSortKey, err
 			}
 
-			if direction == operators.Desc {
-				return p1[currentPosition] > p2[currentPosition]
+			key, err := dataTypes.SortableTime(c.DataType(), value)
+			if err != nil {
+				return "", fmt.Errorf("Could not sort by column %s: %w", c.Column(), err)
 			}
 
-			return p1[currentPosition] < p2[currentPosition]
+			return key, nil
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// sortResults sorts the rows by all ORDER BY columns at once. A column is only compared if
+// all the columns before it are equal. The sort is stable so rows that are equal in every
+// column keep the order in which they were read.
+func sortResults(result [][]string, orderBy syntaxStructure.OrderBy, positions []int, nulls comparison.Nulls) [][]string {
+	orderByColumns := orderBy.Columns()
+	types := sortDataTypes(result, orderByColumns, positions, nulls)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		for k, c := range orderByColumns {
+			cmp := compareSortValues(c, types[k], result[i][positions[k]], result[j][positions[k]], nulls)
+			if cmp != 0 {
+				return cmp < 0
+			}
 		}
 
-		By(fn).Sort(result)
-	}
+		return false
+	})
 
 	return result
 }
 
-/*func getValue[T int64 | float64 | string](p1 string, p2 string) (T, T) {
-	v1i, errV1i := strconv.ParseInt(p1, 10, 64)
-	v2i, errV2i := strconv.ParseInt(p2, 10, 64)
+// sortDataTypes decides once for every sorted column by which data type its values are compared
+func sortDataTypes(rows [][]string, columns []syntaxStructure.OrderByColumn, positions []int, nulls comparison.Nulls) []string {
+	types := make([]string, len(columns))
+	for k, c := range columns {
+		values := make([]string, 0, len(rows))
+		for _, row := range rows {
+			v := row[positions[k]]
+			if v != nullSortKey && !nulls.IsNull(v) {
+				values = append(values, v)
+			}
+		}
 
-	if errV1i != nil && errV2i != nil {
-		return T(v1i), T(v2i)
+		types[k] = comparison.SortDataType(c.DataType(), values)
 	}
 
-	v1f, errV1f := strconv.ParseFloat(p1, 64)
-	v2f, errV2f := strconv.ParseFloat(p2, 64)
+	return types
+}
+
+// compareSortValues returns -1 if v1 comes before v2 when sorting by the column, 1 if it comes
+// after it and 0 if they are equal. NULL values come first or last no matter the direction.
+func compareSortValues(c syntaxStructure.OrderByColumn, dataType string, v1 string, v2 string, nulls comparison.Nulls) int {
+	v1Null := v1 == nullSortKey || nulls.IsNull(v1)
+	v2Null := v2 == nullSortKey || nulls.IsNull(v2)
+
+	if v1Null || v2Null {
+		if v1Null && v2Null {
+			return 0
+		}
+
+		if v1Null == c.NullsFirst() {
+			return -1
+		}
+
+		return 1
+	}
 
-	if errV1f != nil && errV2f != nil {
-		return T(v1f), T(v2f)
+	cmp := comparison.CompareValues(dataType, c.Collation(), v1, v2)
+	if c.Direction() == operators.Desc {
+		return -cmp
 	}
 
-	return T(p1), T(p2)
+	return cmp
 }
-*/
@@ -0,0 +1,257 @@
+package job
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/db/comparison"
+	"github.com/MarioLegenda/cig/internal/db/conditionResolver"
+	"github.com/MarioLegenda/cig/internal/db/expression"
+	"github.com/MarioLegenda/cig/internal/db/selectedColumnMetadata"
+	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
+	"github.com/MarioLegenda/cig/internal/syntax/windows"
+	"strconv"
+)
+
+// windowFunction computes a window function over the rows of a partition. The rows are sorted
+// by the PARTITION BY columns followed by the ORDER BY columns of the window, rows are peers
+// if they are equal in all ORDER BY columns.
+type windowFunction struct {
+	window    syntaxStructure.Window
+	arguments []expression.Evaluator
+	columns   []syntaxStructure.OrderByColumn
+	// dataTypes are the data types the columns were sorted by
+	dataTypes  []string
+	positions  []int
+	partitions int
+	nulls      comparison.Nulls
+}
+
+// computeWindows extends every row with the results of the window functions, in the order of
+// selectedColumnMetadata.ColumnMetadata.Windows(). The returned metadata describes the extended
+// rows so that the results are selected like any other column. A NULL result is comparison.Missing.
+func computeWindows(rows [][]string, selected []selectedColumnMetadata.Selected, metadata conditionResolver.ColumnMetadata, nulls comparison.Nulls) ([][]string, conditionResolver.ColumnMetadata, error) {
+	names := append([]string{}, metadata.ColumnNames()...)
+	for _, s := range selected {
+		names = append(names, s.Column())
+	}
+
+	positions := make([]int, len(names))
+	for i := range names {
+		positions[i] = i
+	}
+
+	windowMetadata := conditionResolver.NewColumnMetadata(positions, names)
+
+	columns := len(metadata.ColumnNames())
+	for i, row := range rows {
+		extended := make([]string, len(names))
+		copy(extended[:columns], row)
+		rows[i] = extended
+	}
+
+	for i, s := range selected {
+		if err := computeWindow(rows, s.Window(), columns+i, windowMetadata, nulls); err != nil {
+			return nil, nil, fmt.Errorf("Could not compute %s: %w", s.Column(), err)
+		}
+	}
+
+	return rows, windowMetadata, nil
+}
+
+// computeWindow writes the results of a window function to the slot of every row. The rows
+// are sorted like by ORDER BY but the order of rows is kept, a sorted copy of them is walked
+// partition by partition instead.
+func computeWindow(rows [][]string, window syntaxStructure.Window, slot int, metadata conditionResolver.ColumnMetadata, nulls comparison.Nulls) error {
+	partitionBy := window.PartitionBy().Columns()
+	orderBy := syntaxStructure.NewOrderBy(append(append([]syntaxStructure.OrderByColumn{}, partitionBy...), window.OrderBy().Columns()...))
+
+	// sorting can replace a row with a copy that holds its sort keys, the index of the row
+	// tells where its result is written
+	for i, row := range rows {
+		row[slot] = strconv.Itoa(i)
+	}
+
+	sorted := make([][]string, len(rows))
+	copy(sorted, rows)
+
+	sorted, positions, err := appendSortKeys(sorted, orderBy, metadata, nulls)
+	if err != nil {
+		return err
+	}
+
+	sortResults(sorted, orderBy, positions, nulls)
+
+	f := &windowFunction{
+		window:     window,
+		arguments:  make([]expression.Evaluator, len(window.Arguments())),
+		columns:    orderBy.Columns(),
+		dataTypes:  sortDataTypes(sorted, orderBy.Columns(), positions, nulls),
+		positions:  positions,
+		partitions: len(partitionBy),
+		nulls:      nulls,
+	}
+
+	for i, a := range window.Arguments() {
+		evaluator, err := expression.NewEvaluator(a, metadata, nulls)
+		if err != nil {
+			return err
+		}
+
+		f.arguments[i] = evaluator
+	}
+
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && f.equal(sorted[start], sorted[end], 0, f.partitions) {
+			end++
+		}
+
+		partition := sorted[start:end]
+		values, err := f.values(partition)
+		if err != nil {
+			return err
+		}
+
+		for i, row := range partition {
+			idx, _ := strconv.Atoi(row[slot])
+			rows[idx][slot] = values[i]
+		}
+
+		start = end
+	}
+
+	return nil
+}
+
+// equal checks if two rows are equal in the sorted columns from and to
+func (f *windowFunction) equal(a, b []string, from, to int) bool {
+	for k := from; k < to; k++ {
+		if compareSortValues(f.columns[k], f.dataTypes[k], a[f.positions[k]], b[f.positions[k]], f.nulls) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *windowFunction) isPeer(a, b []string) bool {
+	return f.equal(a, b, f.partitions, len(f.columns))
+}
+
+// values computes the results of the window function for the sorted rows of a partition
+func (f *windowFunction) values(rows [][]string) ([]string, error) {
+	values := make([]string, len(rows))
+
+	switch f.window.Function() {
+	case windows.RowNumber:
+		for i := range rows {
+			values[i] = strconv.Itoa(i + 1)
+		}
+	case windows.Rank, windows.DenseRank:
+		rank := 1
+		for i := range rows {
+			if i > 0 && !f.isPeer(rows[i-1], rows[i]) {
+				if f.window.Function() == windows.Rank {
+					rank = i + 1
+				} else {
+					rank++
+				}
+			}
+
+			values[i] = strconv.Itoa(rank)
+		}
+	case windows.Lag, windows.Lead:
+		return f.offsetValues(rows)
+	case windows.Sum:
+		return f.runningSum(rows)
+	}
+
+	return values, nil
+}
+
+// offsetValues computes LAG and LEAD, the value of the row that is offset rows before or after
+// the row in its partition. If there is no such row, it is the default value or NULL.
+func (f *windowFunction) offsetValues(rows [][]string) ([]string, error) {
+	values := make([]string, len(rows))
+
+	offset := 1
+	if len(f.arguments) > 1 {
+		value, _, err := f.arguments[1].Evaluate(rows[0])
+		if err != nil {
+			return nil, err
+		}
+
+		offset, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid offset %s: %w", value, err)
+		}
+	}
+
+	if f.window.Function() == windows.Lag {
+		offset = -offset
+	}
+
+	for i, row := range rows {
+		values[i] = comparison.Missing
+
+		evaluator := f.arguments[0]
+		source := i + offset
+		if source < 0 || source >= len(rows) {
+			if len(f.arguments) < 3 {
+				continue
+			}
+
+			evaluator = f.arguments[2]
+		} else {
+			row = rows[source]
+		}
+
+		value, isNull, err := evaluator.Evaluate(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isNull {
+			values[i] = value
+		}
+	}
+
+	return values, nil
+}
+
+// runningSum computes SUM of the rows from the start of the partition up to the row and its
+// peers. Without ORDER BY all rows are peers, so it is the sum of the whole partition.
+func (f *windowFunction) runningSum(rows [][]string) ([]string, error) {
+	values := make([]string, len(rows))
+	sum := &sumAccumulator{dataType: f.window.DataType()}
+
+	for start := 0; start < len(rows); {
+		end := start
+		for ; end < len(rows) && f.isPeer(rows[start], rows[end]); end++ {
+			value, isNull, err := f.arguments[0].Evaluate(rows[end])
+			if err != nil {
+				return nil, err
+			}
+
+			if isNull {
+				continue
+			}
+
+			if err := sum.add(value); err != nil {
+				return nil, err
+			}
+		}
+
+		result := sum.result()
+		if result == "" {
+			result = comparison.Missing
+		}
+
+		for i := start; i < end; i++ {
+			values[i] = result
+		}
+
+		start = end
+	}
+
+	return values, nil
+}
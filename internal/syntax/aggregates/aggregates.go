@@ -0,0 +1,44 @@
+package aggregates
+
+import "github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+
+const Count = "count"
+const Sum = "sum"
+const Avg = "avg"
+const Min = "min"
+const Max = "max"
+
+var Functions = []string{
+	Count,
+	Sum,
+	Avg,
+	Min,
+	Max,
+}
+
+// DataType is the data type of the result of an aggregate function
+// over a column of the given data type.
+func DataType(function, dataType string) string {
+	switch function {
+	case Count:
+		return dataTypes.Int
+	case Sum:
+		if dataType == dataTypes.Int {
+			return dataTypes.Int
+		}
+
+		if dataTypes.Base(dataType) == dataTypes.Decimal {
+			return dataTypes.Decimal
+		}
+
+		return dataTypes.Float
+	case Avg:
+		if dataTypes.Base(dataType) == dataTypes.Decimal {
+			return dataTypes.Decimal
+		}
+
+		return dataTypes.Float
+	}
+
+	return dataType
+}
@@ -0,0 +1,30 @@
+package collations
+
+// Binary compares strings byte by byte, the same as not giving a collation
+const Binary = "binary"
+
+// NoCase ignores the case of ASCII letters
+const NoCase = "nocase"
+
+// Natural compares runs of digits by their numeric value so that file10 comes after file2
+const Natural = "natural"
+
+// Unicode normalizes strings and folds their case with the Unicode rules
+const Unicode = "unicode"
+
+var Collations = []string{
+	Binary,
+	NoCase,
+	Natural,
+	Unicode,
+}
+
+func IsCollation(name string) bool {
+	for _, c := range Collations {
+		if c == name {
+			return true
+		}
+	}
+
+	return false
+}
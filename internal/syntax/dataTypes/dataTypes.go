@@ -1,11 +1,293 @@
 package dataTypes
 
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
 const Int = "int"
 const Float = "float"
 const String = "string"
+const Date = "date"
+const Timestamp = "timestamp"
+const Time = "time"
+const Bool = "bool"
+const Decimal = "decimal"
+
+// Interval is the data type of INTERVAL '7 days'. It cannot be used as the data type of a column.
+const Interval = "interval"
 
 var DataTypes = []string{
 	Int,
 	Float,
 	String,
+	Date,
+	Timestamp,
+	Time,
+	Bool,
+	Decimal,
+}
+
+// decimalValue is a decimal written with digits and an optional decimal point. big.Rat would
+// also read exponents, fractions, base prefixes and digit separators.
+var decimalValue = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+
+// DivisionScale is the number of digits added to the scale of decimals when they are divided
+const DivisionScale = 6
+
+var boolValues = map[string]bool{
+	"true":  true,
+	"t":     true,
+	"yes":   true,
+	"1":     true,
+	"false": false,
+	"f":     false,
+	"no":    false,
+	"0":     false,
+}
+
+// isoLayouts are tried in order if a temporal data type has no layout hint. Dates
+// can also be given as timestamps, the time of the day is then dropped.
+var isoLayouts = map[string][]string{
+	Date:      {"2006-01-02", time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05"},
+	Timestamp: {time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"},
+	Time:      {"15:04:05", "15:04"},
+}
+
+// sortableLayout formats times so that comparing them as strings compares them chronologically
+const sortableLayout = "2006-01-02T15:04:05.000000000"
+
+// Base returns the data type without its hint, for example date for date('02/01/2006')
+func Base(dataType string) string {
+	if i := strings.Index(dataType, "("); i != -1 {
+		return dataType[:i]
+	}
+
+	return dataType
+}
+
+// Hint returns what is between the parentheses of a data type, for example
+// '02/01/2006' for date('02/01/2006'). It is empty if there is no hint.
+func Hint(dataType string) string {
+	i := strings.Index(dataType, "(")
+	if i == -1 || !strings.HasSuffix(dataType, ")") {
+		return ""
+	}
+
+	return dataType[i+1 : len(dataType)-1]
+}
+
+func IsTemporal(dataType string) bool {
+	base := Base(dataType)
+
+	return base == Date || base == Timestamp || base == Time
+}
+
+// Layout returns the Go time layout given as the hint of a temporal data type,
+// or an empty string if there is none.
+func Layout(dataType string) string {
+	hint := Hint(dataType)
+	if len(hint) < 2 || hint[0] != '\'' || hint[len(hint)-1] != '\'' {
+		return ""
+	}
+
+	return hint[1 : len(hint)-1]
+}
+
+// ParseTime parses a value of a temporal data type from a file. If the data type has a
+// layout hint, values must be in that layout, otherwise in the ISO-8601 layouts of the data type.
+func ParseTime(dataType, value string) (time.Time, error) {
+	if layout := Layout(dataType); layout != "" {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Value %s is not a valid %s", value, Base(dataType))
+		}
+
+		return t, nil
+	}
+
+	for _, layout := range isoLayouts[Base(dataType)] {
+		if t, err := time.Parse(layout, value); err == nil {
+			if Base(dataType) == Date {
+				return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+			}
+
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Value %s is not a valid %s", value, Base(dataType))
+}
+
+// ParseQueryTime parses a value of a temporal data type written in the query. It can use
+// either the layout hint or ISO-8601.
+func ParseQueryTime(dataType, value string) (time.Time, error) {
+	if t, err := ParseTime(dataType, value); err == nil {
+		return t, nil
+	}
+
+	return ParseTime(Base(dataType), value)
+}
+
+// FormatTime formats a time in the ISO-8601 layout of the data type
+func FormatTime(dataType string, t time.Time) string {
+	switch Base(dataType) {
+	case Date:
+		return t.Format("2006-01-02")
+	case Time:
+		return t.Format("15:04:05")
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
+
+// SortableTime parses a value of a temporal data type from a file and formats it so that
+// values compare chronologically as strings.
+func SortableTime(dataType, value string) (string, error) {
+	t, err := ParseTime(dataType, value)
+	if err != nil {
+		return "", err
+	}
+
+	return t.UTC().Format(sortableLayout), nil
+}
+
+// SortableQueryTime is SortableTime of a value written in the query
+func SortableQueryTime(dataType, value string) (string, error) {
+	t, err := ParseQueryTime(dataType, value)
+	if err != nil {
+		return "", err
+	}
+
+	return t.UTC().Format(sortableLayout), nil
+}
+
+// IntervalValue is a parsed INTERVAL. Years, months and days are kept apart from the
+// duration since they do not have a fixed length.
+type IntervalValue struct {
+	Years    int
+	Months   int
+	Days     int
+	Duration time.Duration
+}
+
+var intervalUnits = map[string]func(i *IntervalValue, n int){
+	"year":   func(i *IntervalValue, n int) { i.Years += n },
+	"month":  func(i *IntervalValue, n int) { i.Months += n },
+	"week":   func(i *IntervalValue, n int) { i.Days += 7 * n },
+	"day":    func(i *IntervalValue, n int) { i.Days += n },
+	"hour":   func(i *IntervalValue, n int) { i.Duration += time.Duration(n) * time.Hour },
+	"minute": func(i *IntervalValue, n int) { i.Duration += time.Duration(n) * time.Minute },
+	"second": func(i *IntervalValue, n int) { i.Duration += time.Duration(n) * time.Second },
+}
+
+// ParseInterval parses intervals like '7 days', '1 month' or '2 hours 30 minutes'
+func ParseInterval(value string) (IntervalValue, error) {
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return IntervalValue{}, fmt.Errorf("Interval must be in form '7 days', got %s", value)
+	}
+
+	var interval IntervalValue
+	for i := 0; i < len(fields); i += 2 {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return IntervalValue{}, fmt.Errorf("Interval must be in form '7 days', got %s", value)
+		}
+
+		add, ok := intervalUnits[strings.TrimSuffix(fields[i+1], "s")]
+		if !ok {
+			return IntervalValue{}, fmt.Errorf("Unknown interval unit %s, expected years, months, weeks, days, hours, minutes or seconds", fields[i+1])
+		}
+
+		add(&interval, n)
+	}
+
+	return interval, nil
+}
+
+// Add adds the interval to the time, or subtracts it if sign is negative
+func (i IntervalValue) Add(t time.Time, sign int) time.Time {
+	return t.AddDate(sign*i.Years, sign*i.Months, sign*i.Days).Add(time.Duration(sign) * i.Duration)
+}
+
+// ParseBool parses true, false, t, f, yes, no, 1 and 0 in any case
+func ParseBool(value string) (bool, error) {
+	b, ok := boolValues[strings.ToLower(value)]
+	if !ok {
+		return false, fmt.Errorf("Value %s is not a valid bool", value)
+	}
+
+	return b, nil
+}
+
+// Precision returns the precision and the scale of decimal(p,s). ok is false if
+// the data type does not have them.
+func Precision(dataType string) (int, int, bool) {
+	parts := strings.Split(Hint(dataType), ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	precision, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	scale, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || precision < 1 || scale < 0 || scale > precision {
+		return 0, 0, false
+	}
+
+	return precision, scale, true
+}
+
+// ParseDecimal parses a decimal exactly, without going through a float. It also returns
+// the scale of the value, the scale of the data type if it has one or the number of digits
+// after the decimal point otherwise.
+func ParseDecimal(dataType, value string) (*big.Rat, int, error) {
+	if !decimalValue.MatchString(value) {
+		return nil, 0, fmt.Errorf("Value %s is not a valid decimal", value)
+	}
+	digits := strings.TrimLeft(value, "+-")
+
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, 0, fmt.Errorf("Value %s is not a valid decimal", value)
+	}
+
+	if _, scale, ok := Precision(dataType); ok {
+		return r, scale, nil
+	}
+
+	scale := 0
+	if i := strings.Index(digits, "."); i != -1 {
+		scale = len(digits) - i - 1
+	}
+
+	return r, scale, nil
+}
+
+// ValidateDecimal checks that a value fits the precision and the scale of decimal(p,s)
+func ValidateDecimal(dataType, value string) error {
+	if _, _, err := ParseDecimal(dataType, value); err != nil {
+		return err
+	}
+
+	precision, scale, ok := Precision(dataType)
+	if !ok {
+		return nil
+	}
+
+	integer, fraction, _ := strings.Cut(strings.TrimLeft(value, "+-"), ".")
+	integer = strings.TrimLeft(integer, "0")
+	if len(strings.TrimRight(fraction, "0")) > scale || len(integer) > precision-scale {
+		return fmt.Errorf("Value %s does not fit %s", value, dataType)
+	}
+
+	return nil
 }
@@ -0,0 +1,55 @@
+package functions
+
+import "github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+
+const Upper = "upper"
+const Lower = "lower"
+const Trim = "trim"
+const Substr = "substr"
+const Concat = "concat"
+const Length = "length"
+const Replace = "replace"
+const Extract = "extract"
+const DateTrunc = "date_trunc"
+const Now = "now"
+const CurrentDate = "current_date"
+const Coalesce = "coalesce"
+const NullIf = "nullif"
+
+// ExtractFields are the parts of a date or time that EXTRACT can return
+var ExtractFields = []string{"year", "quarter", "month", "week", "day", "dow", "doy", "hour", "minute", "second", "epoch"}
+
+// TruncateFields are the precisions DATE_TRUNC can truncate to
+var TruncateFields = []string{"year", "quarter", "month", "week", "day", "hour", "minute", "second"}
+
+// Function describes the number of arguments a scalar function accepts and the
+// data type of its result. MaxArguments is -1 if there is no limit.
+type Function struct {
+	MinArguments int
+	MaxArguments int
+	DataType     string
+}
+
+var Functions = map[string]Function{
+	Upper:   {MinArguments: 1, MaxArguments: 1, DataType: dataTypes.String},
+	Lower:   {MinArguments: 1, MaxArguments: 1, DataType: dataTypes.String},
+	Trim:    {MinArguments: 1, MaxArguments: 2, DataType: dataTypes.String},
+	Substr:  {MinArguments: 2, MaxArguments: 3, DataType: dataTypes.String},
+	Concat:  {MinArguments: 1, MaxArguments: -1, DataType: dataTypes.String},
+	Length:  {MinArguments: 1, MaxArguments: 1, DataType: dataTypes.Int},
+	Replace: {MinArguments: 3, MaxArguments: 3, DataType: dataTypes.String},
+	// EXTRACT(field FROM value) is called with the field as the first argument
+	Extract:     {MinArguments: 2, MaxArguments: 2, DataType: dataTypes.Int},
+	DateTrunc:   {MinArguments: 2, MaxArguments: 2, DataType: dataTypes.Timestamp},
+	Now:         {MinArguments: 0, MaxArguments: 0, DataType: dataTypes.Timestamp},
+	CurrentDate: {MinArguments: 0, MaxArguments: 0, DataType: dataTypes.Date},
+	// the data type of COALESCE and NULLIF is the data type of their arguments
+	Coalesce: {MinArguments: 1, MaxArguments: -1},
+	NullIf:   {MinArguments: 2, MaxArguments: 2},
+}
+
+func IsFunction(name string) bool {
+	_, ok := Functions[name]
+
+	return ok
+}
@@ -6,9 +6,29 @@ const LessThanOperator = "<"
 const GreaterThanOperator = ">"
 const GreaterThanOrEqualOperator = ">="
 const LessThanOrEqualOperator = "<="
+const InOperator = "in"
+const NotInOperator = "not in"
+const LikeOperator = "like"
+const NotLikeOperator = "not like"
+const ILikeOperator = "ilike"
+const NotILikeOperator = "not ilike"
+
+const ExistsOperator = "exists"
+
+const IsNullOperator = "is null"
+const IsNotNullOperator = "is not null"
+const BetweenOperator = "between"
+const NotBetweenOperator = "not between"
+const RegexMatchOperator = "~"
+const RegexIMatchOperator = "~*"
+const NotRegexMatchOperator = "!~"
+const NotRegexIMatchOperator = "!~*"
+
+const EscapeKeyword = "escape"
 
 const AndOperator = "and"
 const OrOperator = "or"
+const NotOperator = "not"
 
 var Operators = []string{
 	EqualOperator,
@@ -17,14 +37,127 @@ var Operators = []string{
 	LessThanOrEqualOperator,
 	GreaterThanOperator,
 	GreaterThanOrEqualOperator,
+	InOperator,
+	NotInOperator,
+	LikeOperator,
+	NotLikeOperator,
+	ILikeOperator,
+	NotILikeOperator,
+	RegexMatchOperator,
+	RegexIMatchOperator,
+	NotRegexMatchOperator,
+	NotRegexIMatchOperator,
+	BetweenOperator,
+	NotBetweenOperator,
+	IsNullOperator,
+	IsNotNullOperator,
+}
+
+var ListOperators = []string{
+	InOperator,
+	NotInOperator,
+}
+
+var PatternOperators = []string{
+	LikeOperator,
+	NotLikeOperator,
+	ILikeOperator,
+	NotILikeOperator,
+}
+
+var NullOperators = []string{
+	IsNullOperator,
+	IsNotNullOperator,
 }
 
+var RangeOperators = []string{
+	BetweenOperator,
+	NotBetweenOperator,
+}
+
+var RegexOperators = []string{
+	RegexMatchOperator,
+	RegexIMatchOperator,
+	NotRegexMatchOperator,
+	NotRegexIMatchOperator,
+}
+
+const AddOperator = "+"
+const SubtractOperator = "-"
+const MultiplyOperator = "*"
+const DivideOperator = "/"
+const ModuloOperator = "%"
+
+var AdditiveOperators = []string{
+	AddOperator,
+	SubtractOperator,
+}
+
+var MultiplicativeOperators = []string{
+	MultiplyOperator,
+	DivideOperator,
+	ModuloOperator,
+}
+
+const JoinKeyword = "join"
+const InnerJoin = "inner"
+const LeftJoin = "left"
+const RightJoin = "right"
+const AsofJoin = "asof"
+const AsofLeftJoin = "asof left"
+const OuterKeyword = "outer"
+const OnKeyword = "on"
+
+var Joins = []string{
+	InnerJoin,
+	LeftJoin,
+	RightJoin,
+	AsofJoin,
+}
+
+// AsofOperators are the operators that compare the columns an ASOF JOIN finds the nearest row by
+var AsofOperators = []string{
+	GreaterThanOrEqualOperator,
+	GreaterThanOperator,
+	LessThanOrEqualOperator,
+	LessThanOperator,
+}
+
+const UnionOperator = "union"
+const UnionAllOperator = "union all"
+const IntersectOperator = "intersect"
+const ExceptOperator = "except"
+const AllKeyword = "all"
+
+var SetOperators = []string{
+	UnionOperator,
+	IntersectOperator,
+	ExceptOperator,
+}
+
+const AsKeyword = "as"
+const WithKeyword = "with"
+const IntervalKeyword = "interval"
+const CollateKeyword = "collate"
+
+const CaseKeyword = "case"
+const WhenKeyword = "when"
+const ThenKeyword = "then"
+const ElseKeyword = "else"
+const EndKeyword = "end"
+
+const OverKeyword = "over"
+const PartitionKeyword = "partition"
+
 const LimitConstraint = "limit"
 const OffsetConstraint = "offset"
 const OrderByConstraint = "order by"
 
 const Asc = "asc"
 const Desc = "desc"
+const NullsKeyword = "nulls"
+const NullsFirst = "first"
+const NullsLast = "last"
 
 var Constraints = []string{
 	LimitConstraint,
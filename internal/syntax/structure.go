@@ -1,25 +1,37 @@
 package syntax
 
 import (
+	"github.com/MarioLegenda/cig/internal/syntax/aggregates"
+	"github.com/MarioLegenda/cig/internal/syntax/functions"
 	"github.com/MarioLegenda/cig/internal/syntax/operators"
 	"github.com/MarioLegenda/cig/internal/syntax/syntaxStructure"
 	"github.com/MarioLegenda/cig/internal/syntax/tokenizer"
 	"github.com/MarioLegenda/cig/internal/syntax/validation"
-	"strings"
 )
 
 type structure struct {
-	column      syntaxStructure.Column
-	fileDb      syntaxStructure.FileDB
-	condition   syntaxStructure.Condition
-	constraints syntaxStructure.StructureConstraints
+	column        syntaxStructure.Column
+	fileDb        syntaxStructure.FileDB
+	condition     syntaxStructure.Condition
+	constraints   syntaxStructure.StructureConstraints
+	setOperations []syntaxStructure.SetOperation
 }
 
-type Structure interface {
-	Column() syntaxStructure.Column
-	FileDB() syntaxStructure.FileDB
-	Condition() syntaxStructure.Condition
-	Constraints() syntaxStructure.StructureConstraints
+// Structure is the query of a statement. Subqueries refer to their queries, so the interface
+// lives in syntaxStructure.
+type Structure = syntaxStructure.Query
+
+type setOperation struct {
+	operator string
+	query    Structure
+}
+
+func (s setOperation) Operator() string {
+	return s.operator
+}
+
+func (s setOperation) Query() Structure {
+	return s.query
 }
 
 func (s structure) Column() syntaxStructure.Column {
@@ -38,108 +50,236 @@ func (s structure) Constraints() syntaxStructure.StructureConstraints {
 	return s.constraints
 }
 
+func (s structure) SetOperations() []syntaxStructure.SetOperation {
+	return s.setOperations
+}
+
 func NewStructure(sql string) (Structure, error) {
 	tokens := tokenizer.Tokenize(sql)
-	metadata, err := validation.ValidateAndCreateMetadata(tokens)
+	queries, err := validation.ValidateAndCreateQueries(tokens)
 	if err != nil {
 		return nil, err
 	}
 
-	columns := make([]string, len(metadata.SelectedColumns))
-	for i, c := range metadata.SelectedColumns {
-		columns[i] = c.Column
+	return resolveQueries(queries), nil
+}
+
+// resolveQueries resolves the first query together with the queries that set operators combine with it
+func resolveQueries(queries []validation.Query) Structure {
+	s := newStructure(queries[0].Metadata)
+	for _, q := range queries[1:] {
+		s.setOperations = append(s.setOperations, setOperation{
+			operator: q.Operator,
+			query:    newStructure(q.Metadata),
+		})
+	}
+
+	return s
+}
+
+func newStructure(metadata validation.Metadata) structure {
+	distinctOn := make([]string, len(metadata.DistinctOn))
+	for i, c := range metadata.DistinctOn {
+		distinctOn[i] = c.Column
 	}
 
 	t := structure{
-		column:      syntaxStructure.NewColumn(columns),
-		fileDb:      syntaxStructure.NewFileDB(metadata.FilePath, metadata.Alias),
-		condition:   resolveWhereClause(metadata.Conditions),
-		constraints: resolveConstraints(metadata.Limit, metadata.Offset, metadata.OrderBy),
+		column:      syntaxStructure.NewColumn(resolveSelectedColumns(metadata.SelectedColumns), resolveSelectedColumns(metadata.Aggregates), metadata.Distinct, distinctOn),
+		fileDb:      resolveFileDB(metadata),
+		condition:   resolveWhereClause(metadata.Condition),
+		constraints: resolveConstraints(metadata),
 	}
 
-	return t, nil
+	return t
 }
 
-func resolveWhereClause(conditions []validation.Condition) syntaxStructure.Condition {
-	if len(conditions) == 0 {
+func resolveFileDB(metadata validation.Metadata) syntaxStructure.FileDB {
+	joins := make([]syntaxStructure.Join, len(metadata.Joins))
+	for i, j := range metadata.Joins {
+		joins[i] = syntaxStructure.NewJoin(j.Type, j.FilePath, j.Alias, resolveWhereClause(j.On))
+		if j.Queries != nil {
+			joins[i] = syntaxStructure.NewDerivedJoin(j.Type, resolveQueries(j.Queries), j.Alias, resolveWhereClause(j.On))
+		}
+	}
+
+	if metadata.Queries != nil {
+		return syntaxStructure.NewDerivedFileDB(resolveQueries(metadata.Queries), metadata.Alias, joins)
+	}
+
+	return syntaxStructure.NewFileDB(metadata.FilePath, metadata.Alias, joins)
+}
+
+func resolveSelectedColumns(selected []validation.SelectableColumn) []syntaxStructure.SelectedColumn {
+	columns := make([]syntaxStructure.SelectedColumn, len(selected))
+	for i, c := range selected {
+		name := c.As
+		if name == "" && c.Expression != nil {
+			name = c.Original
+		}
+
+		if c.Window != nil {
+			columns[i] = syntaxStructure.NewWindowColumn(resolveWindow(c.Window), c.Column, name)
+
+			continue
+		}
+
+		columns[i] = syntaxStructure.NewNamedColumn(c.Column, c.Function, c.DataType, resolveExpression(c.Expression), name)
+	}
+
+	return columns
+}
+
+func resolveWindow(w *validation.Window) syntaxStructure.Window {
+	arguments := make([]syntaxStructure.Expression, len(w.Arguments))
+	for i, a := range w.Arguments {
+		arguments[i] = resolveExpression(a)
+	}
+
+	return syntaxStructure.NewWindow(w.Function, arguments, resolveOrderBy(w.PartitionBy), resolveOrderBy(w.OrderBy), w.DataType)
+}
+
+func resolveExpression(e *validation.Expression) syntaxStructure.Expression {
+	if e == nil {
 		return nil
 	}
 
-	var head syntaxStructure.Condition
-	var next syntaxStructure.Condition
-	for _, condition := range conditions {
-		var logicalOperator string
-		if condition.LogicalOperator == operators.AndOperator || condition.LogicalOperator == operators.OrOperator {
-			logicalOperator = operators.AndOperator
-			if strings.ToLower(condition.LogicalOperator) == operators.OrOperator {
-				logicalOperator = operators.OrOperator
-			}
+	if e.Operator != "" {
+		return syntaxStructure.NewOperationExpression(e.Operator, resolveExpression(e.Left), resolveExpression(e.Right))
+	}
+
+	if e.Function != "" {
+		arguments := make([]syntaxStructure.Expression, len(e.Arguments))
+		for i, a := range e.Arguments {
+			arguments[i] = resolveExpression(a)
 		}
 
-		if head == nil {
-			head = syntaxStructure.NewCondition(
-				syntaxStructure.NewConditionColumn(condition.Alias, condition.Column, condition.DataType, ""),
-				syntaxStructure.NewConditionOperator(condition.ComparisonOperator, ""),
-				syntaxStructure.NewConditionValue(condition.Value, ""),
-			)
+		// the data type of COALESCE and NULLIF depends on their arguments
+		dataType := functions.Functions[e.Function].DataType
+		if dataType == "" {
+			dataType = e.DataType
+		}
 
-			if logicalOperator != "" {
-				head.SetNext(syntaxStructure.NewCondition(
-					nil,
-					syntaxStructure.NewConditionOperator(logicalOperator, ""),
-					nil,
-				))
-			}
+		return syntaxStructure.NewFunctionExpression(e.Function, arguments, dataType)
+	}
 
-			next = head.Next()
+	if e.When != nil {
+		when := make([]syntaxStructure.When, len(e.When))
+		for i, w := range e.When {
+			when[i] = syntaxStructure.NewWhen(resolveWhereClause(w.Condition), resolveExpression(w.Then))
+		}
 
-			continue
+		return syntaxStructure.NewCaseExpression(when, resolveExpression(e.Else), e.DataType)
+	}
+
+	if e.Column != "" {
+		return syntaxStructure.NewColumnExpression(e.Column, e.DataType)
+	}
+
+	return syntaxStructure.NewLiteralExpression(e.Literal, e.DataType)
+}
+
+// resolveWhereClause resolves both WHERE and HAVING. Aggregate functions of HAVING are
+// compared by the name of their result, for example count(*).
+func resolveWhereClause(node *validation.ConditionNode) syntaxStructure.Condition {
+	if node == nil {
+		return nil
+	}
+
+	if node.Condition != nil {
+		c := node.Condition
+
+		value := syntaxStructure.NewConditionValue(c.Value, "")
+		if c.Values != nil {
+			value = syntaxStructure.NewConditionListValue(c.Values, "")
+		} else if c.Regex != nil {
+			value = syntaxStructure.NewConditionRegexValue(c.Value, c.Regex, "")
+		} else if c.Escape != "" {
+			value = syntaxStructure.NewConditionPatternValue(c.Value, c.Escape, "")
+		} else if c.ValueExpression != nil {
+			value = syntaxStructure.NewConditionExpressionValue(resolveExpression(c.ValueExpression), "")
+		} else if c.Subquery != nil {
+			value = syntaxStructure.NewConditionSubqueryValue(resolveSubquery(c.Subquery), nil, "")
+		}
+
+		column := syntaxStructure.NewConditionColumn(c.Alias, c.Column, c.DataType, c.Collation, "")
+		if c.Function != "" {
+			column = syntaxStructure.NewConditionColumn(c.Alias, syntaxStructure.AggregateName(c.Function, c.Column), c.DataType, c.Collation, "")
+		} else if c.Expression != nil {
+			column = syntaxStructure.NewConditionExpressionColumn(c.Alias, c.Column, c.DataType, c.Collation, resolveExpression(c.Expression))
+		}
+
+		return syntaxStructure.NewCondition(
+			column,
+			syntaxStructure.NewConditionOperator(c.ComparisonOperator, ""),
+			value,
+		)
+	}
+
+	return syntaxStructure.NewLogicalCondition(
+		syntaxStructure.NewConditionOperator(node.LogicalOperator, ""),
+		resolveWhereClause(node.Left),
+		resolveWhereClause(node.Right),
+	)
+}
+
+func resolveSubquery(s *validation.Subquery) syntaxStructure.Subquery {
+	columns := make([]syntaxStructure.ConditionColumn, len(s.Correlated))
+	for i, c := range s.Correlated {
+		columns[i] = syntaxStructure.NewConditionColumn(c.Alias, c.Column, c.DataType, c.Collation, "")
+	}
+
+	return syntaxStructure.NewSubquery(newStructure(s.Metadata), columns)
+}
+
+// resolveOrderBy resolves ORDER BY and PARTITION BY and ORDER BY of window functions. Aggregate
+// functions are ordered by the name of their result, for example count(*).
+func resolveOrderBy(ob *validation.OrderBy) syntaxStructure.OrderBy {
+	columns := make([]syntaxStructure.OrderByColumn, len(ob.Columns))
+	for i, c := range ob.Columns {
+		column := c.Column
+		dataType := c.DataType
+		if c.Function != "" {
+			column = syntaxStructure.AggregateName(c.Function, c.Column)
+			dataType = aggregates.DataType(c.Function, c.DataType)
 		}
 
-		if next != nil {
-			t := syntaxStructure.NewCondition(
-				syntaxStructure.NewConditionColumn(condition.Alias, condition.Column, condition.DataType, ""),
-				syntaxStructure.NewConditionOperator(condition.ComparisonOperator, ""),
-				syntaxStructure.NewConditionValue(condition.Value, ""),
-			)
-
-			if logicalOperator != "" {
-				t.SetNext(syntaxStructure.NewCondition(
-					nil,
-					syntaxStructure.NewConditionOperator(logicalOperator, ""),
-					nil,
-				))
-			}
-
-			next.SetNext(t)
-			next = next.Next().Next()
+		var expression syntaxStructure.Expression
+		if c.Expression != nil {
+			expression = resolveExpression(c.Expression)
 		}
+
+		columns[i] = syntaxStructure.NewOrderByColumn(column, c.Alias, dataType, c.Collation, c.Direction, c.Nulls == operators.NullsFirst, expression)
 	}
 
-	return head
+	return syntaxStructure.NewOrderBy(columns)
 }
 
-func resolveConstraints(l int64, o int64, ob *validation.OrderBy) syntaxStructure.StructureConstraints {
+func resolveConstraints(metadata validation.Metadata) syntaxStructure.StructureConstraints {
 	var limit syntaxStructure.Constraint[int64]
 	var offset syntaxStructure.Constraint[int64]
 	var orderBy syntaxStructure.OrderBy
+	var groupBy syntaxStructure.GroupBy
+
+	if metadata.Limit != -1 {
+		limit = syntaxStructure.NewLimit(metadata.Limit)
+	}
 
-	if l != -1 {
-		limit = syntaxStructure.NewLimit(l)
+	if metadata.Offset != -1 {
+		offset = syntaxStructure.NewOffset(metadata.Offset)
 	}
 
-	if o != -1 {
-		offset = syntaxStructure.NewOffset(o)
+	if metadata.OrderBy != nil {
+		orderBy = resolveOrderBy(metadata.OrderBy)
 	}
 
-	if ob != nil {
-		mapping := make(map[string]string)
-		for _, c := range ob.Columns {
-			mapping[c.Column] = c.Alias
+	if len(metadata.GroupBy) != 0 {
+		columns := make([]string, len(metadata.GroupBy))
+		for i, c := range metadata.GroupBy {
+			columns[i] = c.Column
 		}
 
-		orderBy = syntaxStructure.NewOrderBy(mapping, ob.Direction)
+		groupBy = syntaxStructure.NewGroupBy(columns)
 	}
 
-	return syntaxStructure.NewConstraints(limit, offset, orderBy)
+	return syntaxStructure.NewConstraints(limit, offset, orderBy, groupBy, resolveWhereClause(metadata.Having))
 }
@@ -23,8 +23,9 @@ func TestStructureValid(t *testing.T) {
 
 	assert.Equal(t, condition.Value().Value(), "Level 1")
 
-	assert.Nil(t, condition.Next())
-	assert.Nil(t, condition.Prev())
+	assert.False(t, condition.IsLogical())
+	assert.Nil(t, condition.Left())
+	assert.Nil(t, condition.Right())
 }
 
 func TestStructureWithMultipleConditions(t *testing.T) {
@@ -38,45 +39,70 @@ func TestStructureWithMultipleConditions(t *testing.T) {
 	assert.True(t, res.Column().HasColumn("Year"))
 	assert.Equal(t, len(res.Column().Columns()), 2)
 
-	head := res.Condition()
-	assert.Equal(t, head.Column().Alias(), "e")
-	assert.Equal(t, head.Column().Column(), "Industry_aggregation_NZSIOC")
-	assert.Equal(t, head.Column().DataType(), "")
-	assert.Equal(t, head.Value().Value(), "Level 1")
+	// ((first AND second) OR third) OR fourth
+	root := res.Condition()
+	assert.True(t, root.IsLogical())
+	assert.Equal(t, root.Operator().ConditionType(), operators.OrOperator)
+	assert.Nil(t, root.Value())
+	assert.Nil(t, root.Column())
+
+	fourthCondition := root.Right()
+	assert.Equal(t, fourthCondition.Column().Alias(), "e")
+	assert.Equal(t, fourthCondition.Column().DataType(), dataTypes.String)
+	assert.Equal(t, fourthCondition.Column().Column(), "Variable_code")
+	assert.Equal(t, fourthCondition.Value().Value(), "some value")
+
+	orOperator := root.Left()
+	assert.Equal(t, orOperator.Operator().ConditionType(), operators.OrOperator)
+	assert.Nil(t, orOperator.Value())
+	assert.Nil(t, orOperator.Column())
 
-	andOperator := head.Next()
+	thirdCondition := orOperator.Right()
+	assert.Equal(t, thirdCondition.Column().Alias(), "e")
+	assert.Equal(t, thirdCondition.Column().DataType(), "")
+	assert.Equal(t, thirdCondition.Column().Column(), "Industry_aggregation_NZSIOC")
+	assert.Equal(t, thirdCondition.Value().Value(), "Level 3")
 
+	andOperator := orOperator.Left()
 	assert.Equal(t, andOperator.Operator().ConditionType(), operators.AndOperator)
 	assert.Nil(t, andOperator.Value())
 	assert.Nil(t, andOperator.Column())
 
-	secondCondition := andOperator.Next()
+	head := andOperator.Left()
+	assert.Equal(t, head.Column().Alias(), "e")
+	assert.Equal(t, head.Column().Column(), "Industry_aggregation_NZSIOC")
+	assert.Equal(t, head.Column().DataType(), "")
+	assert.Equal(t, head.Value().Value(), "Level 1")
+
+	secondCondition := andOperator.Right()
 	assert.Equal(t, secondCondition.Column().Alias(), "e")
 	assert.Equal(t, secondCondition.Column().DataType(), dataTypes.Int)
 	assert.Equal(t, secondCondition.Column().Column(), "Year")
 	assert.Equal(t, secondCondition.Value().Value(), "2021")
+}
 
-	orOperator := secondCondition.Next()
-	assert.Equal(t, orOperator.Operator().ConditionType(), operators.OrOperator)
-	assert.Nil(t, orOperator.Value())
-	assert.Nil(t, orOperator.Column())
+func TestStructureWithParenthesesAndNot(t *testing.T) {
+	sql := "SELECT * FROM path:../../testdata/example.csv AS e WHERE NOT ('e.Year'::int = '2021' OR 'e.Year'::int = '2020') AND ('e.Variable_code' = 'H01' OR NOT 'e.Variable_code' = 'H04')"
 
-	thirdCondition := orOperator.Next()
-	assert.Equal(t, thirdCondition.Column().Alias(), "e")
-	assert.Equal(t, thirdCondition.Column().DataType(), "")
-	assert.Equal(t, thirdCondition.Column().Column(), "Industry_aggregation_NZSIOC")
-	assert.Equal(t, thirdCondition.Value().Value(), "Level 3")
+	res, err := NewStructure(sql)
 
-	orOperator = thirdCondition.Next()
-	assert.Equal(t, orOperator.Operator().ConditionType(), operators.OrOperator)
-	assert.Nil(t, orOperator.Value())
-	assert.Nil(t, orOperator.Column())
+	assert.Nil(t, err)
 
-	fourthCondition := orOperator.Next()
-	assert.Equal(t, fourthCondition.Column().Alias(), "e")
-	assert.Equal(t, fourthCondition.Column().DataType(), dataTypes.String)
-	assert.Equal(t, fourthCondition.Column().Column(), "Variable_code")
-	assert.Equal(t, fourthCondition.Value().Value(), "some value")
+	root := res.Condition()
+	assert.Equal(t, root.Operator().ConditionType(), operators.AndOperator)
+
+	not := root.Left()
+	assert.Equal(t, not.Operator().ConditionType(), operators.NotOperator)
+	assert.Nil(t, not.Right())
+	assert.Equal(t, not.Left().Operator().ConditionType(), operators.OrOperator)
+	assert.Equal(t, not.Left().Left().Value().Value(), "2021")
+	assert.Equal(t, not.Left().Right().Value().Value(), "2020")
+
+	or := root.Right()
+	assert.Equal(t, or.Operator().ConditionType(), operators.OrOperator)
+	assert.Equal(t, or.Left().Value().Value(), "H01")
+	assert.Equal(t, or.Right().Operator().ConditionType(), operators.NotOperator)
+	assert.Equal(t, or.Right().Left().Value().Value(), "H04")
 }
 
 func TestLimitConstraintValid(t *testing.T) {
@@ -99,8 +125,9 @@ func TestLimitConstraintValid(t *testing.T) {
 
 	assert.Equal(t, condition.Value().Value(), "Level 1")
 
-	assert.Nil(t, condition.Next())
-	assert.Nil(t, condition.Prev())
+	assert.False(t, condition.IsLogical())
+	assert.Nil(t, condition.Left())
+	assert.Nil(t, condition.Right())
 }
 
 func TestOffsetConstraintValid(t *testing.T) {
@@ -123,8 +150,9 @@ func TestOffsetConstraintValid(t *testing.T) {
 
 	assert.Equal(t, condition.Value().Value(), "Level 1")
 
-	assert.Nil(t, condition.Next())
-	assert.Nil(t, condition.Prev())
+	assert.False(t, condition.IsLogical())
+	assert.Nil(t, condition.Left())
+	assert.Nil(t, condition.Right())
 }
 
 func TestAllConstraintValid(t *testing.T) {
@@ -148,6 +176,7 @@ func TestAllConstraintValid(t *testing.T) {
 
 	assert.Equal(t, condition.Value().Value(), "Level 1")
 
-	assert.Nil(t, condition.Next())
-	assert.Nil(t, condition.Prev())
+	assert.False(t, condition.IsLogical())
+	assert.Nil(t, condition.Left())
+	assert.Nil(t, condition.Right())
 }
@@ -1,17 +1,86 @@
 package syntaxStructure
 
+import "fmt"
+
 type column struct {
-	columns []string
+	selected   []SelectedColumn
+	aggregates []SelectedColumn
+	distinct   bool
+	distinctOn []string
+}
+
+// SelectedColumn is a single column of the SELECT list. For aggregate functions,
+// Function() is the name of the function and Column() is its argument which is
+// * for COUNT(*). Columns computed from other columns have an Expression().
+// Window functions have a Window() and their Column() is the function as it was written.
+type SelectedColumn interface {
+	Column() string
+	Name() string
+	Function() string
+	DataType() string
+	Expression() Expression
+	Window() Window
 }
 
 type Column interface {
 	HasColumn(column string) bool
 	Columns() []string
+	Selected() []SelectedColumn
+	HasAggregates() bool
+	// Aggregates are all aggregate functions the query computes, including the
+	// ones that are only used in HAVING or ORDER BY
+	Aggregates() []SelectedColumn
+	// Distinct is true for both DISTINCT and DISTINCT ON. DistinctOn is empty
+	// for DISTINCT which removes duplicates of all selected columns.
+	Distinct() bool
+	DistinctOn() []string
+}
+
+type selectedColumn struct {
+	column     string
+	function   string
+	dataType   string
+	expression Expression
+	window     Window
+	name       string
+}
+
+func (sc selectedColumn) Column() string {
+	return sc.column
+}
+
+// Name is the name of the column in the result
+func (sc selectedColumn) Name() string {
+	if sc.name != "" {
+		return sc.name
+	}
+
+	if sc.function != "" {
+		return AggregateName(sc.function, sc.column)
+	}
+
+	return sc.column
+}
+
+func (sc selectedColumn) Function() string {
+	return sc.function
+}
+
+func (sc selectedColumn) DataType() string {
+	return sc.dataType
+}
+
+func (sc selectedColumn) Expression() Expression {
+	return sc.expression
+}
+
+func (sc selectedColumn) Window() Window {
+	return sc.window
 }
 
 func (c column) HasColumn(search string) bool {
-	for _, cl := range c.columns {
-		if cl == search {
+	for _, cl := range c.selected {
+		if cl.Column() == search {
 			return true
 		}
 	}
@@ -20,9 +89,81 @@ func (c column) HasColumn(search string) bool {
 }
 
 func (c column) Columns() []string {
-	return c.columns
+	columns := make([]string, len(c.selected))
+	for i, s := range c.selected {
+		columns[i] = s.Column()
+	}
+
+	return columns
+}
+
+func (c column) Selected() []SelectedColumn {
+	return c.selected
 }
 
-func NewColumn(columns []string) Column {
-	return column{columns: columns}
+func (c column) HasAggregates() bool {
+	for _, s := range c.selected {
+		if s.Function() != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c column) Aggregates() []SelectedColumn {
+	return c.aggregates
+}
+
+func (c column) Distinct() bool {
+	return c.distinct
+}
+
+func (c column) DistinctOn() []string {
+	return c.distinctOn
+}
+
+// AggregateName is the name of the result of an aggregate function, for example sum(Value)
+func AggregateName(function, column string) string {
+	return fmt.Sprintf("%s(%s)", function, column)
+}
+
+func NewColumn(selected []SelectedColumn, aggregates []SelectedColumn, distinct bool, distinctOn []string) Column {
+	return column{
+		selected:   selected,
+		aggregates: aggregates,
+		distinct:   distinct,
+		distinctOn: distinctOn,
+	}
+}
+
+func NewSelectedColumn(column, function, dataType string) SelectedColumn {
+	return selectedColumn{
+		column:   column,
+		function: function,
+		dataType: dataType,
+	}
+}
+
+// NewNamedColumn creates a column of the SELECT list with the name it has in the result. The name
+// is given with AS or, for expressions, is the expression as it was written.
+func NewNamedColumn(column, function, dataType string, expression Expression, name string) SelectedColumn {
+	return selectedColumn{
+		column:     column,
+		function:   function,
+		dataType:   dataType,
+		expression: expression,
+		name:       name,
+	}
+}
+
+// NewWindowColumn creates a column of the SELECT list computed by a window function. Column
+// is the function as it was written.
+func NewWindowColumn(window Window, column, name string) SelectedColumn {
+	return selectedColumn{
+		column:   column,
+		dataType: window.DataType(),
+		window:   window,
+		name:     name,
+	}
 }
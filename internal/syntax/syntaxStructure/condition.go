@@ -1,43 +1,70 @@
 package syntaxStructure
 
+import (
+	"regexp"
+	"strings"
+)
+
+// Condition is a node of the WHERE boolean expression tree. Leaf nodes hold
+// the column, the comparison operator and the value to compare against. Logical
+// nodes hold only the logical operator (and, or, not) and their operands. NOT
+// nodes have only the left operand.
 type Condition interface {
 	Value() ConditionValue
-	Next() Condition
-	Prev() Condition
-	SetNext(item Condition)
-	SetPrev(item Condition)
+	Left() Condition
+	Right() Condition
 	Column() ConditionColumn
 	Operator() ConditionOperator
+	IsLogical() bool
 	String() string
 }
 
+// ConditionColumn is the compared column. If the column is computed by an Expression,
+// Column() is the expression as it was written. Collation() is empty if the column
+// is compared without COLLATE.
 type ConditionColumn interface {
 	Alias() string
 	Column() string
 	DataType() string
+	Collation() string
+	Expression() Expression
 }
 
 type ConditionOperator interface {
 	ConditionType() string
 }
 
+// ConditionValue holds the single value of a comparison or the list of
+// values of list operators like IN. For LIKE operators, Value() is the pattern
+// and Escape() is its escape character. For regular expression operators, Regex()
+// is the expression compiled during validation. If the value is computed, Expression()
+// computes it and Value() is empty. IN (SELECT ...) and EXISTS (SELECT ...) have
+// a Subquery(), Rows() are its rows once it has run.
 type ConditionValue interface {
 	Value() string
+	Values() []string
+	Escape() string
+	Regex() *regexp.Regexp
+	Expression() Expression
+	Subquery() Subquery
+	Rows() [][]string
 }
 
 type condition struct {
 	value    ConditionValue
 	column   ConditionColumn
 	operator ConditionOperator
-	next     Condition
-	prev     Condition
+	left     Condition
+	right    Condition
 }
 
 type conditionColumn struct {
-	alias    string
-	column   string
-	dataType string
-	original string
+	alias      string
+	column     string
+	dataType   string
+	collation  string
+	original   string
+	expression Expression
 }
 
 func (cc conditionColumn) Alias() string {
@@ -52,6 +79,14 @@ func (cc conditionColumn) DataType() string {
 	return cc.dataType
 }
 
+func (cc conditionColumn) Collation() string {
+	return cc.collation
+}
+
+func (cc conditionColumn) Expression() Expression {
+	return cc.expression
+}
+
 type conditionOperator struct {
 	original      string
 	conditionType string
@@ -62,32 +97,58 @@ func (co conditionOperator) ConditionType() string {
 }
 
 type conditionValue struct {
-	original string
-	value    string
+	original   string
+	value      string
+	values     []string
+	escape     string
+	regex      *regexp.Regexp
+	expression Expression
+	subquery   Subquery
+	rows       [][]string
 }
 
 func (cv conditionValue) Value() string {
 	return cv.value
 }
 
-func (i *condition) Value() ConditionValue {
-	return i.value
+func (cv conditionValue) Values() []string {
+	return cv.values
 }
 
-func (i *condition) Next() Condition {
-	return i.next
+func (cv conditionValue) Escape() string {
+	return cv.escape
 }
 
-func (i *condition) Prev() Condition {
-	return i.prev
+func (cv conditionValue) Regex() *regexp.Regexp {
+	return cv.regex
 }
 
-func (i *condition) SetNext(item Condition) {
-	i.next = item
+func (cv conditionValue) Expression() Expression {
+	return cv.expression
+}
+
+func (cv conditionValue) Subquery() Subquery {
+	return cv.subquery
+}
+
+func (cv conditionValue) Rows() [][]string {
+	return cv.rows
+}
+
+func (i *condition) Value() ConditionValue {
+	return i.value
 }
 
-func (i *condition) SetPrev(item Condition) {
-	i.prev = item
+func (i *condition) Left() Condition {
+	return i.left
+}
+
+func (i *condition) Right() Condition {
+	return i.right
+}
+
+func (i *condition) IsLogical() bool {
+	return i.column == nil
 }
 
 func (i *condition) Column() ConditionColumn {
@@ -99,6 +160,14 @@ func (i *condition) Operator() ConditionOperator {
 }
 
 func (i *condition) String() string {
+	if i.IsLogical() {
+		if i.right == nil {
+			return i.operator.ConditionType() + " (" + i.left.String() + ")"
+		}
+
+		return "(" + i.left.String() + " " + i.operator.ConditionType() + " " + i.right.String() + ")"
+	}
+
 	base := ""
 	if i.column != nil {
 		base += i.column.Column() + " "
@@ -108,7 +177,9 @@ func (i *condition) String() string {
 		base += i.operator.ConditionType() + " "
 	}
 
-	if i.value != nil {
+	if i.value != nil && i.value.Values() != nil {
+		base += "(" + strings.Join(i.value.Values(), ", ") + ")"
+	} else if i.value != nil {
 		base += i.value.Value()
 	}
 
@@ -120,17 +191,37 @@ func NewCondition(column ConditionColumn, operator ConditionOperator, value Cond
 		value:    value,
 		column:   column,
 		operator: operator,
-		next:     nil,
-		prev:     nil,
 	}
 }
 
-func NewConditionColumn(alias, column, dataType, original string) ConditionColumn {
+// NewLogicalCondition creates an inner node of the condition tree. For the NOT operator,
+// right is nil.
+func NewLogicalCondition(operator ConditionOperator, left, right Condition) Condition {
+	return &condition{
+		operator: operator,
+		left:     left,
+		right:    right,
+	}
+}
+
+func NewConditionColumn(alias, column, dataType, collation, original string) ConditionColumn {
 	return conditionColumn{
-		dataType: dataType,
-		alias:    alias,
-		column:   column,
-		original: original,
+		dataType:  dataType,
+		collation: collation,
+		alias:     alias,
+		column:    column,
+		original:  original,
+	}
+}
+
+func NewConditionExpressionColumn(alias, column, dataType, collation string, expression Expression) ConditionColumn {
+	return conditionColumn{
+		dataType:   dataType,
+		collation:  collation,
+		alias:      alias,
+		column:     column,
+		original:   column,
+		expression: expression,
 	}
 }
 
@@ -147,3 +238,43 @@ func NewConditionValue(value, original string) ConditionValue {
 		value:    value,
 	}
 }
+
+func NewConditionListValue(values []string, original string) ConditionValue {
+	return conditionValue{
+		original: original,
+		values:   values,
+	}
+}
+
+func NewConditionPatternValue(pattern, escape, original string) ConditionValue {
+	return conditionValue{
+		original: original,
+		value:    pattern,
+		escape:   escape,
+	}
+}
+
+func NewConditionRegexValue(pattern string, regex *regexp.Regexp, original string) ConditionValue {
+	return conditionValue{
+		original: original,
+		value:    pattern,
+		regex:    regex,
+	}
+}
+
+func NewConditionExpressionValue(expression Expression, original string) ConditionValue {
+	return conditionValue{
+		original:   original,
+		expression: expression,
+	}
+}
+
+// NewConditionSubqueryValue creates the value of IN (SELECT ...) and EXISTS (SELECT ...).
+// Rows are nil until the subquery has run.
+func NewConditionSubqueryValue(subquery Subquery, rows [][]string, original string) ConditionValue {
+	return conditionValue{
+		original: original,
+		subquery: subquery,
+		rows:     rows,
+	}
+}
@@ -6,18 +6,31 @@ type Constraint[T any] interface {
 
 type OrderBy interface {
 	Columns() []OrderByColumn
-	Direction() string
 }
 
+// OrderByColumn is a column of ORDER BY. If the column is computed by an Expression,
+// Column() is the expression as it was written. Collation() is empty if the column
+// is sorted without COLLATE.
 type OrderByColumn interface {
 	Column() string
 	Alias() string
+	DataType() string
+	Collation() string
+	Expression() Expression
+	Direction() string
+	NullsFirst() bool
+}
+
+type GroupBy interface {
+	Columns() []string
 }
 
 type StructureConstraints interface {
 	Limit() Constraint[int64]
 	Offset() Constraint[int64]
 	OrderBy() OrderBy
+	GroupBy() GroupBy
+	Having() Condition
 }
 
 type limit[T comparable] struct {
@@ -29,19 +42,29 @@ type offset[T comparable] struct {
 }
 
 type orderByColumn struct {
-	column string
-	alias  string
+	column     string
+	alias      string
+	dataType   string
+	collation  string
+	expression Expression
+	direction  string
+	nullsFirst bool
 }
 
 type orderBy struct {
-	columns   []OrderByColumn
-	direction string
+	columns []OrderByColumn
+}
+
+type groupBy struct {
+	columns []string
 }
 
 type constraints struct {
 	limit   Constraint[int64]
 	offset  Constraint[int64]
 	orderBy OrderBy
+	groupBy GroupBy
+	having  Condition
 }
 
 func (obc orderByColumn) Column() string {
@@ -52,6 +75,26 @@ func (obc orderByColumn) Alias() string {
 	return obc.alias
 }
 
+func (obc orderByColumn) DataType() string {
+	return obc.dataType
+}
+
+func (obc orderByColumn) Collation() string {
+	return obc.collation
+}
+
+func (obc orderByColumn) Expression() Expression {
+	return obc.expression
+}
+
+func (obc orderByColumn) Direction() string {
+	return obc.direction
+}
+
+func (obc orderByColumn) NullsFirst() bool {
+	return obc.nullsFirst
+}
+
 func (c constraints) Limit() Constraint[int64] {
 	return c.limit
 }
@@ -64,6 +107,18 @@ func (c constraints) OrderBy() OrderBy {
 	return c.orderBy
 }
 
+func (c constraints) GroupBy() GroupBy {
+	return c.groupBy
+}
+
+func (c constraints) Having() Condition {
+	return c.having
+}
+
+func (g groupBy) Columns() []string {
+	return g.columns
+}
+
 func (c limit[T]) Value() T {
 	return c.value
 }
@@ -76,26 +131,23 @@ func (c orderBy) Columns() []OrderByColumn {
 	return c.columns
 }
 
-func (c orderBy) Direction() string {
-	return c.direction
-}
-
-func newOrderByColumn(c string, alias string) OrderByColumn {
+func NewOrderByColumn(c string, alias string, dataType string, collation string, direction string, nullsFirst bool, expression Expression) OrderByColumn {
 	return orderByColumn{
-		column: c,
-		alias:  alias,
+		column:     c,
+		alias:      alias,
+		dataType:   dataType,
+		collation:  collation,
+		expression: expression,
+		direction:  direction,
+		nullsFirst: nullsFirst,
 	}
 }
 
-func NewOrderBy(columns map[string]string, direction string) OrderBy {
-	obs := make([]OrderByColumn, 0)
-	for c, alias := range columns {
-		obs = append(obs, newOrderByColumn(c, alias))
-	}
-
+// NewOrderBy creates ORDER BY from its columns. The order of the columns is the order
+// in which rows are sorted by them.
+func NewOrderBy(columns []OrderByColumn) OrderBy {
 	return orderBy{
-		columns:   obs,
-		direction: direction,
+		columns: columns,
 	}
 }
 
@@ -107,10 +159,16 @@ func NewOffset(value int64) Constraint[int64] {
 	return offset[int64]{value: value}
 }
 
-func NewConstraints(limit Constraint[int64], offset Constraint[int64], ob OrderBy) StructureConstraints {
+func NewGroupBy(columns []string) GroupBy {
+	return groupBy{columns: columns}
+}
+
+func NewConstraints(limit Constraint[int64], offset Constraint[int64], ob OrderBy, gb GroupBy, having Condition) StructureConstraints {
 	return constraints{
 		limit:   limit,
 		offset:  offset,
 		orderBy: ob,
+		groupBy: gb,
+		having:  having,
 	}
 }
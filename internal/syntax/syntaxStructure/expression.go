@@ -0,0 +1,174 @@
+package syntaxStructure
+
+const ColumnExpression = "column"
+const LiteralExpression = "literal"
+const OperationExpression = "operation"
+const FunctionExpression = "function"
+const CaseExpression = "case"
+
+// Expression is a node of an expression that computes a value from the columns of a row.
+// Column and literal nodes are leaves, operations have both operands and function calls
+// have the name of the function and its arguments. CASE has its branches and the value of
+// ELSE, which is nil without ELSE. The DataType of a function call and of CASE is the data
+// type of its result.
+type Expression interface {
+	Kind() string
+	Operator() string
+	Left() Expression
+	Right() Expression
+	Function() string
+	Arguments() []Expression
+	When() []When
+	Else() Expression
+	Column() string
+	DataType() string
+	Value() string
+}
+
+// When is a WHEN condition THEN value branch of CASE
+type When interface {
+	Condition() Condition
+	Then() Expression
+}
+
+type expression struct {
+	kind      string
+	operator  string
+	left      Expression
+	right     Expression
+	function  string
+	arguments []Expression
+	when      []When
+	elseValue Expression
+	column    string
+	dataType  string
+	value     string
+}
+
+type when struct {
+	condition Condition
+	then      Expression
+}
+
+func (w when) Condition() Condition {
+	return w.condition
+}
+
+func (w when) Then() Expression {
+	return w.then
+}
+
+func (e expression) Kind() string {
+	return e.kind
+}
+
+func (e expression) Operator() string {
+	return e.operator
+}
+
+func (e expression) Left() Expression {
+	return e.left
+}
+
+func (e expression) Right() Expression {
+	return e.right
+}
+
+func (e expression) Function() string {
+	return e.function
+}
+
+func (e expression) Arguments() []Expression {
+	return e.arguments
+}
+
+func (e expression) When() []When {
+	return e.when
+}
+
+func (e expression) Else() Expression {
+	return e.elseValue
+}
+
+func (e expression) Column() string {
+	return e.column
+}
+
+func (e expression) DataType() string {
+	return e.dataType
+}
+
+func (e expression) Value() string {
+	return e.value
+}
+
+func NewColumnExpression(column, dataType string) Expression {
+	return expression{
+		kind:     ColumnExpression,
+		column:   column,
+		dataType: dataType,
+	}
+}
+
+func NewLiteralExpression(value, dataType string) Expression {
+	return expression{
+		kind:     LiteralExpression,
+		value:    value,
+		dataType: dataType,
+	}
+}
+
+func NewOperationExpression(operator string, left, right Expression) Expression {
+	return expression{
+		kind:     OperationExpression,
+		operator: operator,
+		left:     left,
+		right:    right,
+	}
+}
+
+func NewFunctionExpression(function string, arguments []Expression, dataType string) Expression {
+	return expression{
+		kind:      FunctionExpression,
+		function:  function,
+		arguments: arguments,
+		dataType:  dataType,
+	}
+}
+
+func NewWhen(condition Condition, then Expression) When {
+	return when{
+		condition: condition,
+		then:      then,
+	}
+}
+
+func NewCaseExpression(when []When, elseValue Expression, dataType string) Expression {
+	return expression{
+		kind:      CaseExpression,
+		when:      when,
+		elseValue: elseValue,
+		dataType:  dataType,
+	}
+}
+
+// UsesColumns checks if the value of the expression depends on the row
+func UsesColumns(e Expression) bool {
+	switch e.Kind() {
+	case ColumnExpression:
+		return true
+	case OperationExpression:
+		return UsesColumns(e.Left()) || UsesColumns(e.Right())
+	case FunctionExpression:
+		for _, a := range e.Arguments() {
+			if UsesColumns(a) {
+				return true
+			}
+		}
+	case CaseExpression:
+		// conditions of CASE compare columns
+		return true
+	}
+
+	return false
+}
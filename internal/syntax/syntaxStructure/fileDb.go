@@ -2,22 +2,87 @@ package syntaxStructure
 
 type fileDb struct {
 	path  string
+	query Query
 	alias string
+	joins []Join
 }
 
+type join struct {
+	joinType string
+	path     string
+	query    Query
+	alias    string
+	on       Condition
+}
+
+// FileDB is the file of FROM together with the files joined to it. The rows of a derived
+// table or of a query of WITH come from Query() instead of a file, Path() is then empty.
 type FileDB interface {
 	Path() string
+	Query() Query
 	Alias() string
+	Joins() []Join
+}
+
+// Join is a joined file. Type is inner, left or right and On is the condition
+// that matches its rows with the rows of the files before it. Like FileDB, its
+// rows can come from Query().
+type Join interface {
+	Type() string
+	Path() string
+	Query() Query
+	Alias() string
+	On() Condition
 }
 
 func (f fileDb) Path() string {
 	return f.path
 }
 
+func (f fileDb) Query() Query {
+	return f.query
+}
+
 func (f fileDb) Alias() string {
 	return f.alias
 }
 
-func NewFileDB(path, alias string) FileDB {
-	return fileDb{path: path, alias: alias}
+func (f fileDb) Joins() []Join {
+	return f.joins
+}
+
+func (j join) Type() string {
+	return j.joinType
+}
+
+func (j join) Path() string {
+	return j.path
+}
+
+func (j join) Query() Query {
+	return j.query
+}
+
+func (j join) Alias() string {
+	return j.alias
+}
+
+func (j join) On() Condition {
+	return j.on
+}
+
+func NewFileDB(path, alias string, joins []Join) FileDB {
+	return fileDb{path: path, alias: alias, joins: joins}
+}
+
+func NewJoin(joinType, path, alias string, on Condition) Join {
+	return join{joinType: joinType, path: path, alias: alias, on: on}
+}
+
+func NewDerivedFileDB(query Query, alias string, joins []Join) FileDB {
+	return fileDb{query: query, alias: alias, joins: joins}
+}
+
+func NewDerivedJoin(joinType string, query Query, alias string, on Condition) Join {
+	return join{joinType: joinType, query: query, alias: alias, on: on}
 }
@@ -0,0 +1,48 @@
+package syntaxStructure
+
+// Query is a validated query
+type Query interface {
+	Column() Column
+	FileDB() FileDB
+	Condition() Condition
+	Constraints() StructureConstraints
+	// SetOperations are the queries combined with this one by UNION, UNION ALL,
+	// INTERSECT or EXCEPT, in the order they are written
+	SetOperations() []SetOperation
+}
+
+// SetOperation is a query combined with the queries before it by Operator()
+type SetOperation interface {
+	Operator() string
+	Query() Query
+}
+
+// Subquery is the query of IN (SELECT ...) and EXISTS (SELECT ...). A correlated subquery
+// compares columns of its files with Columns() of the outer query. These comparisons are not in
+// its WHERE clause, the compared columns of its files are selected instead, after the column of
+// IN, so that the subquery runs only once and the rows of the outer query are looked up in
+// its rows by their values.
+type Subquery interface {
+	Query() Query
+	Columns() []ConditionColumn
+}
+
+type subquery struct {
+	query   Query
+	columns []ConditionColumn
+}
+
+func (s subquery) Query() Query {
+	return s.query
+}
+
+func (s subquery) Columns() []ConditionColumn {
+	return s.columns
+}
+
+func NewSubquery(query Query, columns []ConditionColumn) Subquery {
+	return subquery{
+		query:   query,
+		columns: columns,
+	}
+}
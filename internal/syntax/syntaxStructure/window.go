@@ -0,0 +1,50 @@
+package syntaxStructure
+
+// Window is a window function of the SELECT list. Its value for a row is computed from the
+// rows with the same values of PartitionBy() in the order of OrderBy(). Both are empty if
+// OVER does not have them. The columns of PartitionBy() do not have a direction.
+type Window interface {
+	Function() string
+	Arguments() []Expression
+	PartitionBy() OrderBy
+	OrderBy() OrderBy
+	DataType() string
+}
+
+type window struct {
+	function    string
+	arguments   []Expression
+	partitionBy OrderBy
+	orderBy     OrderBy
+	dataType    string
+}
+
+func (w window) Function() string {
+	return w.function
+}
+
+func (w window) Arguments() []Expression {
+	return w.arguments
+}
+
+func (w window) PartitionBy() OrderBy {
+	return w.partitionBy
+}
+
+func (w window) OrderBy() OrderBy {
+	return w.orderBy
+}
+
+func (w window) DataType() string {
+	return w.dataType
+}
+
+func NewWindow(function string, arguments []Expression, partitionBy OrderBy, orderBy OrderBy, dataType string) Window {
+	return window{
+		function:    function,
+		arguments:   arguments,
+		partitionBy: partitionBy,
+		orderBy:     orderBy,
+		dataType:    dataType,
+	}
+}
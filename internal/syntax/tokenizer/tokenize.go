@@ -1,5 +1,7 @@
 package tokenizer
 
+import "strings"
+
 func Tokenize(sql string) []string {
 	tokens := make([]string, 0)
 	buf := make([]byte, 0)
@@ -28,12 +30,19 @@ func Tokenize(sql string) []string {
 				continue
 			}
 
-			if !quoteMode && b == 44 {
+			// the hint of a data type is part of the token, for example 'e.created'::date('02/01/2006')
+			if b == 40 && isDataType(buf) {
+				i = appendHint(sql, i, &buf)
+				continue
+			}
+
+			// comma and parentheses are always tokens on their own
+			if !quoteMode && (b == 44 || b == 40 || b == 41) {
 				if len(buf) != 0 {
 					tokens = append(tokens, string(buf))
 				}
 
-				tokens = append(tokens, ",")
+				tokens = append(tokens, string(b))
 				buf = make([]byte, 0)
 				i++
 				break
@@ -59,3 +68,41 @@ func Tokenize(sql string) []string {
 
 	return tokens
 }
+
+// isDataType checks if the token ends with a data type, for example 'e.created'::date
+func isDataType(buf []byte) bool {
+	s := string(buf)
+	i := strings.LastIndex(s, "::")
+	if i == -1 || i+2 == len(s) {
+		return false
+	}
+
+	for _, r := range s[i+2:] {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// appendHint appends everything up to and including the closing parenthesis of the hint
+// that starts at i. Parentheses in quotes do not close the hint.
+func appendHint(sql string, i int, buf *[]byte) int {
+	quoteMode := false
+	for i < len(sql) {
+		b := sql[i]
+		*buf = append(*buf, b)
+		i++
+
+		if b == 39 {
+			quoteMode = !quoteMode
+		}
+
+		if b == 41 && !quoteMode {
+			break
+		}
+	}
+
+	return i
+}
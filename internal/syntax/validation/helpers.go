@@ -1,5 +1,36 @@
 package validation
 
+import "strings"
+
 func isEnclosedInQuote(v string) bool {
-	return v[0] == '\'' && v[len(v)-1] == '\''
+	return len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\''
+}
+
+func isOneOf(v string, values []string) bool {
+	for _, t := range values {
+		if t == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tableAliases are the aliases of the files of a query, the alias of FROM first and then
+// the aliases of the joined files
+type tableAliases []string
+
+func (t tableAliases) has(alias string) bool {
+	return isOneOf(alias, t)
+}
+
+func (t tableAliases) String() string {
+	return strings.Join(t, ", ")
+}
+
+// scope is what a query can use besides its own files, the aliases of the query a subquery
+// is in and the queries of WITH by their names
+type scope struct {
+	outer tableAliases
+	with  map[string][]Query
 }
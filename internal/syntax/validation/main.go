@@ -1,137 +1,317 @@
 package validation
 
 import (
+	"fmt"
 	"github.com/MarioLegenda/cig/pkg"
+	"regexp"
 	"strings"
 )
 
 type Limit = int64
 type Offset = int64
 
+// OrderByColumn is a column of ORDER BY. Function is set when ordering by
+// an aggregate function, DataType is then the data type of its argument. When
+// ordering by an Expression, Column is the expression as it was written. Every
+// column has its own Direction and Nulls tells if NULL values come first or last.
+// Collation is set by COLLATE.
 type OrderByColumn struct {
+	Alias      string
+	Column     string
+	Function   string
+	DataType   string
+	Collation  string
+	Expression *Expression
+	Direction  string
+	Nulls      string
+}
+
+type GroupByColumn struct {
 	Alias  string
 	Column string
 }
 
 type OrderBy struct {
-	Columns   []OrderByColumn
-	Direction string
+	Columns []OrderByColumn
 }
 
+// Condition is a single comparison. The compared column is either a column of the file,
+// an aggregate function (Function) in HAVING or an Expression like LOWER('e.name'). Column
+// of expressions is the expression as it was written. A value that is computed, like
+// NOW() - INTERVAL '30 days', or another column of the row is in ValueExpression instead
+// of Value. Collation is set
+// by COLLATE after the column. IN (SELECT ...) and EXISTS (SELECT ...) have a Subquery,
+// EXISTS does not have a Column.
 type Condition struct {
 	Alias              string
 	Value              string
+	Values             []string
+	Escape             string
+	Regex              *regexp.Regexp
 	Column             string
+	Function           string
+	Expression         *Expression
+	ValueExpression    *Expression
 	DataType           string
+	Collation          string
 	ComparisonOperator string
-	LogicalOperator    string
+	Subquery           *Subquery
+}
+
+// Subquery is the query of IN (SELECT ...) and EXISTS (SELECT ...). Comparisons of columns of
+// a correlated subquery with columns of the outer query are taken out of its WHERE clause. The
+// compared columns of its files are selected instead, after the column of IN, and Correlated are
+// the columns of the outer query they are compared with, in the same order.
+type Subquery struct {
+	Metadata   Metadata
+	Correlated []*Condition
+}
+
+// ConditionNode is a node of the boolean expression tree of the WHERE clause.
+// Leaf nodes hold a Condition, every other node holds a logical operator. NOT
+// nodes only have the Left operand.
+type ConditionNode struct {
+	LogicalOperator string
+	Left            *ConditionNode
+	Right           *ConditionNode
+	Condition       *Condition
 }
 
+// SelectableColumn is a column of the SELECT list. For aggregate functions,
+// Function is the name of the function and Column is its argument. Columns
+// computed from other columns have an Expression. As is the name given with AS.
+// Window functions have a Window and their Column is the function as it was written.
 type SelectableColumn struct {
+	Alias      string
+	Column     string
+	Original   string
+	Function   string
+	DataType   string
+	Expression *Expression
+	Window     *Window
+	As         string
+}
+
+// Window is a window function like ROW_NUMBER() OVER (PARTITION BY 'e.a' ORDER BY 'e.b').
+// Its value for a row is computed from the rows with the same values of PartitionBy, in the
+// order of OrderBy. The aliases of the query are not known while the SELECT list is parsed, so
+// the arguments and OVER are kept in tokens until they are.
+type Window struct {
+	Function    string
+	Arguments   []*Expression
+	PartitionBy *OrderBy
+	OrderBy     *OrderBy
+	DataType    string
+	arguments   []string
+	over        []string
+}
+
+// Expression is a node of an expression that computes a value from the columns of a row.
+// Operations hold the operator and both operands, function calls hold the name of the
+// function and its arguments. Leaves are either columns or literals. The DataType of
+// string literals is string, numeric literals do not have a DataType. CASE holds its
+// branches in When and the value of ELSE in Else, a CASE without ELSE is NULL if no
+// branch matches. The DataType of CASE, COALESCE and NULLIF is the data type of their values.
+type Expression struct {
+	Operator  string
+	Left      *Expression
+	Right     *Expression
+	Function  string
+	Arguments []*Expression
+	When      []*When
+	Else      *Expression
+	Alias     string
+	Column    string
+	DataType  string
+	Literal   string
+}
+
+// When is a WHEN condition THEN value branch of CASE. The aliases of the query are not known
+// while the SELECT list is parsed, so the condition of CASE in the SELECT list is kept in
+// tokens until they are.
+type When struct {
+	Condition *ConditionNode
+	Then      *Expression
+	tokens    []string
+}
+
+// Join is a file joined to the query. Type is inner, left or right and On is the
+// condition that matches the rows of the joined file. Like in Metadata, the rows can
+// come from Queries instead.
+type Join struct {
+	Type     string
+	FilePath string
+	Queries  []Query
 	Alias    string
-	Column   string
-	Original string
+	On       *ConditionNode
 }
 
 type Metadata struct {
 	SelectedColumns []SelectableColumn
 	FilePath        string
-	Alias           string
-	Conditions      []Condition
-	OrderBy         *OrderBy
-	Limit           Limit
-	Offset          Offset
+	// Queries are the queries of a derived table or of a query of WITH, the rows come from
+	// them instead of FilePath
+	Queries []Query
+	Alias   string
+	// Joins are the joined files in the order of the query. When a query has joins,
+	// every column is named by its alias, for example a.id.
+	Joins     []Join
+	Condition *ConditionNode
+	OrderBy   *OrderBy
+	GroupBy   []GroupByColumn
+	Having    *ConditionNode
+	// Aggregates are all aggregate functions the query computes, the selected ones
+	// and the ones used only in HAVING or ORDER BY
+	Aggregates []SelectableColumn
+	// Distinct is true for both DISTINCT and DISTINCT ON
+	Distinct   bool
+	DistinctOn []SelectableColumn
+	Limit      Limit
+	Offset     Offset
 }
 
 func ValidateAndCreateMetadata(tokens []string) (Metadata, error) {
+	m, _, err := validateQuery(tokens, scope{})
+
+	return m, err
+}
+
+// validateQuery validates a query. The WHERE clause of a subquery can compare columns with
+// columns of the outer aliases, the rest of the query cannot use them. These comparisons are
+// returned separately, see Subquery.
+func validateQuery(tokens []string, s scope) (Metadata, []*Condition, error) {
 	// reserve enough space so not to get "index out of range"
 	tokens = append(tokens, make([]string, 100)...)
 	currentIdx := 0
 
-	var conditions []Condition
-	var limit Limit = -1
-	var offset Offset = -1
-	var orderBy *OrderBy
+	var condition *ConditionNode
+	var correlated []correlation
+	c := constraints{
+		limit:  -1,
+		offset: -1,
+	}
 
 	if err := validSelect(tokens); err != nil {
-		return Metadata{}, err
+		return Metadata{}, nil, err
 	}
 	currentIdx++
 
-	skipIndex, selectableColumns, err := validSelectableColumns(tokens)
+	distinct, distinctOn, skipIndex, err := validateDistinct(tokens, currentIdx)
 	if err != nil {
-		return Metadata{}, err
+		return Metadata{}, nil, err
+	}
+
+	currentIdx += skipIndex
+	skipIndex, selectableColumns, err := validSelectableColumns(tokens, currentIdx)
+	if err != nil {
+		return Metadata{}, nil, err
 	}
 
 	currentIdx += skipIndex
 	if err := validateFrom(tokens[currentIdx]); err != nil {
-		return Metadata{}, err
+		return Metadata{}, nil, err
 	}
 	currentIdx++
 
-	path, err := validatePath(tokens[currentIdx])
+	from, currentIdx, err := validateSource(tokens, currentIdx, s)
 	if err != nil {
-		return Metadata{}, err
+		return Metadata{}, nil, err
 	}
 
-	currentIdx++
+	joins, aliases, currentIdx, err := validateJoins(tokens, currentIdx, tableAliases{from.alias}, s)
+	if err != nil {
+		return Metadata{}, nil, err
+	}
 
-	if err := validateAsToken(tokens[currentIdx]); err != nil {
-		return Metadata{}, err
+	for _, a := range aliases {
+		if s.outer.has(a) {
+			return Metadata{}, nil, fmt.Errorf("Alias %s is already an alias of the outer query: %w", a, pkg.InvalidSubquery)
+		}
 	}
-	currentIdx++
 
-	alias, err := validateAlias(tokens[currentIdx])
-	if err != nil {
-		return Metadata{}, err
+	resolveFunctionLiterals(aliases, selectableColumns)
+	if err := resolveCaseConditions(aliases, selectableColumns); err != nil {
+		return Metadata{}, nil, err
 	}
 
-	if err := validateSelectableColumnAlias(tokens[currentIdx], selectableColumns); err != nil {
-		return Metadata{}, err
+	if err := resolveWindows(aliases, selectableColumns); err != nil {
+		return Metadata{}, nil, err
+	}
+
+	if err := validateSelectableColumnAlias(aliases, selectableColumns); err != nil {
+		return Metadata{}, nil, err
 	}
-	currentIdx++
 
 	nextInstruction, err := decideNextInstruction(tokens[currentIdx])
 	if err != nil {
-		return Metadata{}, err
+		return Metadata{}, nil, err
 	}
 
 	if nextInstruction != "" {
 		if nextInstruction == "condition" {
 			if err := validateWhereClause(tokens[currentIdx]); err != nil {
-				return Metadata{}, err
+				return Metadata{}, nil, err
 			}
 			currentIdx++
 
-			c, err := validateConditions(alias, tokens, currentIdx)
+			cn, nextIdx, err := validateConditions(aliases, s, tokens, currentIdx)
 			if err != nil {
-				return Metadata{}, err
+				return Metadata{}, nil, err
 			}
-			currentIdx += len(conditions) * 3
+			currentIdx = nextIdx
 
-			conditions = c
+			condition, correlated, err = correlate(cn, s.outer)
+			if err != nil {
+				return Metadata{}, nil, err
+			}
 		}
 
-		l, o, ob, err := validateConstraints(alias, tokens, currentIdx)
+		c, err = validateConstraints(aliases, tokens, currentIdx)
 
 		if err != nil {
-			return Metadata{}, err
+			return Metadata{}, nil, err
 		}
-
-		limit = l
-		offset = o
-		orderBy = ob
 	}
 
-	return Metadata{
+	m := Metadata{
 		SelectedColumns: selectableColumns,
-		FilePath:        path,
-		Alias:           alias,
-		Conditions:      conditions,
-		OrderBy:         orderBy,
-		Offset:          offset,
-		Limit:           limit,
-	}, err
+		FilePath:        from.path,
+		Queries:         from.queries,
+		Alias:           from.alias,
+		Joins:           joins,
+		Condition:       condition,
+		OrderBy:         c.orderBy,
+		GroupBy:         c.groupBy,
+		Having:          c.having,
+		Distinct:        distinct,
+		DistinctOn:      distinctOn,
+		Offset:          c.offset,
+		Limit:           c.limit,
+	}
+
+	outerColumns, err := selectCorrelated(&m, correlated)
+	if err != nil {
+		return Metadata{}, nil, err
+	}
+
+	if len(joins) != 0 {
+		qualifyColumns(&m)
+	}
+
+	if err := validateWindowGrouping(m.SelectedColumns, m.GroupBy, m.Having); err != nil {
+		return Metadata{}, nil, err
+	}
+
+	if err := validateGrouping(m.SelectedColumns, m.GroupBy, m.Having, m.OrderBy); err != nil {
+		return Metadata{}, nil, err
+	}
+
+	m.Aggregates = collectAggregates(m.SelectedColumns, m.Having, m.OrderBy)
+	if err := validateDistinctOnColumns(aliases, m.DistinctOn, m.GroupBy, m.Aggregates); err != nil {
+		return Metadata{}, nil, err
+	}
+
+	return m, outerColumns, nil
 }
 
 func decideNextInstruction(token string) (string, error) {
@@ -142,7 +322,7 @@ func decideNextInstruction(token string) (string, error) {
 	t := strings.ToLower(token)
 	if t == "where" {
 		return "condition", nil
-	} else if t == "limit" || t == "offset" || t == "order" {
+	} else if t == "limit" || t == "offset" || t == "order" || t == "group" || t == "having" {
 		return "constraint", nil
 	}
 
@@ -0,0 +1,236 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/functions"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/pkg"
+	"strings"
+)
+
+func isCase(tokens []string, i int) bool {
+	return strings.ToLower(tokens[i]) == operators.CaseKeyword
+}
+
+// parseCase parses CASE WHEN condition THEN value [WHEN ...] [ELSE value] END. The conditions are
+// the same as the conditions of WHERE. Like arguments of functions, quoted values of THEN and ELSE
+// are strings if they are not columns.
+func (p *expressionParser) parseCase() (*Expression, error) {
+	p.idx++
+
+	e := &Expression{}
+	for strings.ToLower(p.current()) == operators.WhenKeyword {
+		p.idx++
+
+		thenIdx := p.thenIndex()
+		if thenIdx == -1 {
+			return nil, fmt.Errorf("Expected THEN after the condition of WHEN: %w", pkg.InvalidCase)
+		}
+
+		w := &When{
+			tokens: append([]string{}, p.tokens[p.idx:thenIdx]...),
+		}
+
+		if len(p.aliases) != 0 {
+			condition, err := validateWhenCondition(p.aliases, w.tokens)
+			if err != nil {
+				return nil, err
+			}
+
+			w.Condition = condition
+			w.tokens = nil
+		}
+		p.idx = thenIdx + 1
+
+		then, err := p.parseCaseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		w.Then = then
+		e.When = append(e.When, w)
+	}
+
+	if len(e.When) == 0 {
+		return nil, fmt.Errorf("Expected WHEN after CASE, got %s: %w", p.current(), pkg.InvalidCase)
+	}
+
+	if strings.ToLower(p.current()) == operators.ElseKeyword {
+		p.idx++
+
+		value, err := p.parseCaseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		e.Else = value
+	}
+
+	if strings.ToLower(p.current()) != operators.EndKeyword {
+		return nil, fmt.Errorf("Expected WHEN, ELSE or END in CASE, got %s: %w", p.current(), pkg.InvalidCase)
+	}
+	p.idx++
+
+	dataType, err := conditionalDataType(e)
+	if err != nil {
+		return nil, fmt.Errorf("Values of CASE must have the same data type: %w", err)
+	}
+
+	e.DataType = dataType
+
+	return e, nil
+}
+
+func (p *expressionParser) parseCaseValue() (*Expression, error) {
+	p.functionDepth++
+	e, err := p.parseAdditive()
+	p.functionDepth--
+
+	return e, err
+}
+
+// thenIndex returns the index of THEN that ends the condition of WHEN, skipping the
+// conditions of nested CASE expressions
+func (p *expressionParser) thenIndex() int {
+	depth := 0
+	for i := p.idx; p.tokens[i] != ""; i++ {
+		switch strings.ToLower(p.tokens[i]) {
+		case operators.CaseKeyword:
+			depth++
+		case operators.EndKeyword:
+			depth--
+		case operators.ThenKeyword:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// validateWhenCondition parses the condition of WHEN. Subqueries are run before the rows are
+// read, so they can be used only in WHERE and not in conditions of CASE.
+func validateWhenCondition(aliases tableAliases, tokens []string) (*ConditionNode, error) {
+	padded := append(append(make([]string, 0, len(tokens)+10), tokens...), make([]string, 10)...)
+
+	node, _, err := parseConditionTree(&conditionParser{
+		aliases:  aliases,
+		tokens:   padded,
+		caseWhen: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if node == nil {
+		return nil, fmt.Errorf("Expected a condition after WHEN: %w", pkg.InvalidCase)
+	}
+
+	return node, nil
+}
+
+// resolveCaseConditions parses the conditions of CASE expressions of the SELECT list once the
+// aliases of the query are known
+func resolveCaseConditions(aliases tableAliases, columns []SelectableColumn) error {
+	var walk func(e *Expression) error
+	walk = func(e *Expression) error {
+		if e == nil {
+			return nil
+		}
+
+		for _, w := range e.When {
+			if w.tokens != nil {
+				condition, err := validateWhenCondition(aliases, w.tokens)
+				if err != nil {
+					return err
+				}
+
+				w.Condition = condition
+				w.tokens = nil
+			}
+		}
+
+		for _, o := range append(literalOperands(e), e.Left, e.Right) {
+			if err := walk(o); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, c := range columns {
+		if err := walk(c.Expression); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// literalOperands are the operands of an expression in which a quoted value is a string if it
+// is not a column, the arguments of functions and the values of CASE
+func literalOperands(e *Expression) []*Expression {
+	operands := append([]*Expression{}, e.Arguments...)
+	for _, w := range e.When {
+		operands = append(operands, w.Then)
+	}
+
+	if e.Else != nil {
+		operands = append(operands, e.Else)
+	}
+
+	return operands
+}
+
+// isConditional checks if the value of an expression is one of its values, as in CASE, COALESCE and NULLIF
+func isConditional(e *Expression) bool {
+	return e.When != nil || e.Function == functions.Coalesce || e.Function == functions.NullIf
+}
+
+// conditionalDataType is the data type of CASE, COALESCE and NULLIF. Their values can be
+// numbers of different data types, anything else must have the same data type. NULLIF
+// compares its arguments as this data type.
+func conditionalDataType(e *Expression) (string, error) {
+	dataType := ""
+	for _, v := range literalOperands(e) {
+		dt, err := comparisonDataType(dataType, expressionDataType(v))
+		if err != nil {
+			return "", err
+		}
+
+		dataType = dt
+	}
+
+	// like in arithmetic, dates and times are computed without their layout
+	if dataTypes.IsTemporal(dataType) {
+		return dataTypes.Base(dataType), nil
+	}
+
+	return dataType, nil
+}
+
+// conditionColumns returns all columns a condition compares
+func conditionColumns(node *ConditionNode) []*Expression {
+	if node == nil {
+		return nil
+	}
+
+	c := node.Condition
+	if c == nil {
+		return append(conditionColumns(node.Left), conditionColumns(node.Right)...)
+	}
+
+	columns := expressionColumns(c.ValueExpression)
+	if c.Expression != nil {
+		return append(columns, expressionColumns(c.Expression)...)
+	}
+
+	return append(columns, &Expression{
+		Alias:    c.Alias,
+		Column:   c.Column,
+		DataType: c.DataType,
+	})
+}
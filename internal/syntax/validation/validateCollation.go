@@ -0,0 +1,30 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/collations"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/pkg"
+	"strings"
+)
+
+// validateCollation validates the optional COLLATE after a column. It returns the collation,
+// or an empty string if there is none, and the index of the first token after it. Only
+// strings can have a collation.
+func validateCollation(tokens []string, idx int, dataType string) (string, int, error) {
+	if strings.ToLower(tokens[idx]) != operators.CollateKeyword {
+		return "", idx, nil
+	}
+
+	collation := strings.ToLower(tokens[idx+1])
+	if !collations.IsCollation(collation) {
+		return "", idx, fmt.Errorf("Expected one of %s after COLLATE, got %s: %w", strings.Join(collations.Collations, ", "), tokens[idx+1], pkg.InvalidCollation)
+	}
+
+	if dataType != "" && dataType != dataTypes.String {
+		return "", idx, fmt.Errorf("Only strings can have a collation, got %s: %w", dataType, pkg.InvalidCollation)
+	}
+
+	return collation, idx + 2, nil
+}
@@ -2,143 +2,685 @@ package validation
 
 import (
 	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/aggregates"
 	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
 	"github.com/MarioLegenda/cig/internal/syntax/operators"
 	"github.com/MarioLegenda/cig/pkg"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
-func validateConditions(alias string, tokens []string, startIdx int) ([]Condition, error) {
-	if tokens[startIdx] == "" {
-		return []Condition{}, nil
+type conditionParser struct {
+	aliases tableAliases
+	tokens  []string
+	idx     int
+	// aggregates allows aggregate functions as operands, used by HAVING
+	aggregates bool
+	// join is set for the ON condition of a JOIN which also ends where WHERE or the next JOIN starts
+	join bool
+	// scope of a subquery has the aliases of its outer query, they are the last of aliases
+	scope scope
+	// caseWhen is set for the condition of WHEN in CASE
+	caseWhen bool
+}
+
+// validateConditions parses the WHERE clause into a boolean expression tree and returns
+// the root of the tree together with the index of the first token after the clause.
+// OR binds weaker than AND which binds weaker than NOT. Parentheses override precedence.
+// The WHERE clause of a subquery can also compare columns of the outer aliases.
+func validateConditions(aliases tableAliases, s scope, tokens []string, startIdx int) (*ConditionNode, int, error) {
+	all := make(tableAliases, 0, len(aliases)+len(s.outer))
+
+	return parseConditionTree(&conditionParser{
+		aliases: append(append(all, aliases...), s.outer...),
+		tokens:  tokens,
+		idx:     startIdx,
+		scope:   s,
+	})
+}
+
+// validateHavingConditions parses the HAVING clause. It is the same as the WHERE clause
+// except that aggregate functions like COUNT(*) can be compared.
+func validateHavingConditions(aliases tableAliases, tokens []string, startIdx int) (*ConditionNode, int, error) {
+	return parseConditionTree(&conditionParser{
+		aliases:    aliases,
+		tokens:     tokens,
+		idx:        startIdx,
+		aggregates: true,
+	})
+}
+
+func parseConditionTree(p *conditionParser) (*ConditionNode, int, error) {
+	startIdx := p.idx
+	if p.isEnd(p.current()) {
+		return nil, startIdx, nil
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, startIdx, err
 	}
 
-	column := tokens[startIdx]
-	operator := tokens[startIdx+1]
-	value := tokens[startIdx+2]
+	current := p.current()
+	if current == ")" {
+		return nil, startIdx, fmt.Errorf("Closing parenthesis without the opening one: %w", pkg.InvalidParenthesis)
+	}
 
-	conditions := make([]Condition, 0)
-	if column == "limit" || column == "offset" || column == "order" {
-		return conditions, nil
+	if !p.isEnd(current) {
+		return nil, startIdx, fmt.Errorf("Expected AND or OR, got %s: %w", current, pkg.InvalidLogicalOperator)
 	}
 
-	getColumnAndDataType := func(c string) (string, string) {
-		dtSplit := strings.Split(c, "::")
+	return node, p.idx, nil
+}
+
+func isConditionEnd(token string) bool {
+	t := strings.ToLower(token)
 
-		var columnOnly string
-		var dataType string
-		if len(dtSplit) == 2 {
-			columnOnly = dtSplit[0]
-			dataType = dtSplit[1]
-		} else {
-			columnOnly = c
-		}
+	return t == "" || t == "limit" || t == "offset" || t == "order" || t == "group" || t == "having"
+}
 
-		return columnOnly, dataType
+func (p *conditionParser) isEnd(token string) bool {
+	if p.join && isJoinConditionEnd(token) {
+		return true
 	}
 
-	validateColumn := func(c string) (string, error) {
-		if !isEnclosedInQuote(c) {
-			return "", pkg.InvalidSelectableColumns
+	return isConditionEnd(token)
+}
+
+func (p *conditionParser) current() string {
+	return p.tokens[p.idx]
+}
+
+func (p *conditionParser) parseOr() (*ConditionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for strings.ToLower(p.current()) == operators.OrOperator {
+		p.idx++
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
 		}
 
-		columnOnly := c[1 : len(c)-1]
-		splitted := strings.Split(columnOnly, ".")
+		left = &ConditionNode{
+			LogicalOperator: operators.OrOperator,
+			Left:            left,
+			Right:           right,
+		}
+	}
+
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (*ConditionNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for strings.ToLower(p.current()) == operators.AndOperator {
+		p.idx++
 
-		if len(splitted) != 2 {
-			return "", fmt.Errorf("Condition column have to be in form {alias}.{columnName}: %w", pkg.InvalidConditionColumn)
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
 		}
 
-		if splitted[0] != alias {
-			return "", fmt.Errorf("Invalid condition column alias. Expected %s: %w", alias, pkg.InvalidConditionAlias)
+		left = &ConditionNode{
+			LogicalOperator: operators.AndOperator,
+			Left:            left,
+			Right:           right,
 		}
+	}
+
+	return left, nil
+}
 
-		return splitted[1], nil
+func (p *conditionParser) parseNot() (*ConditionNode, error) {
+	if strings.ToLower(p.current()) != operators.NotOperator {
+		return p.parsePrimary()
 	}
 
-	validateDataType := func(dt string) error {
-		for _, d := range dataTypes.DataTypes {
-			if d == dt {
-				return nil
-			}
+	p.idx++
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConditionNode{
+		LogicalOperator: operators.NotOperator,
+		Left:            operand,
+	}, nil
+}
+
+func (p *conditionParser) parsePrimary() (*ConditionNode, error) {
+	if strings.ToLower(p.current()) == operators.ExistsOperator {
+		c, err := p.parseExists()
+		if err != nil {
+			return nil, err
 		}
 
-		return fmt.Errorf("Invalid data type. Expected one of %s, got something else: %w", strings.Join(dataTypes.DataTypes, ","), pkg.InvalidDataType)
+		return &ConditionNode{Condition: c}, nil
 	}
 
-	extractedColumn, dataType := getColumnAndDataType(column)
-	columnOnly, err := validateColumn(extractedColumn)
+	if p.current() != "(" {
+		c, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+
+		return &ConditionNode{Condition: c}, nil
+	}
 
+	p.idx++
+	node, err := p.parseOr()
 	if err != nil {
-		return conditions, err
+		return nil, err
 	}
 
-	found := false
-	for _, o := range operators.Operators {
-		if operator == o {
-			found = true
-			break
+	if p.current() != ")" {
+		return nil, fmt.Errorf("Expected closing parenthesis, got %s: %w", p.current(), pkg.InvalidParenthesis)
+	}
+	p.idx++
+
+	return node, nil
+}
+
+func (p *conditionParser) parseCondition() (*Condition, error) {
+	condition, err := p.parseConditionColumn()
+	if err != nil {
+		return nil, err
+	}
+
+	collation, nextIdx, err := validateCollation(p.tokens, p.idx, condition.DataType)
+	if err != nil {
+		return nil, err
+	}
+	p.idx = nextIdx
+
+	operator, err := p.parseComparisonOperator()
+	if err != nil {
+		return nil, err
+	}
+
+	// LIKE and regular expressions have their own case insensitive operators
+	if collation != "" && (isOneOf(operator, operators.PatternOperators) || isOneOf(operator, operators.RegexOperators)) {
+		return nil, fmt.Errorf("COLLATE cannot be used with %s, use ILIKE or ~* instead: %w", strings.ToUpper(operator), pkg.InvalidCollation)
+	}
+
+	condition.Collation = collation
+
+	condition.ComparisonOperator = operator
+	dataType := condition.DataType
+
+	if operator == operators.InOperator || operator == operators.NotInOperator {
+		if p.current() == "(" && strings.ToLower(p.tokens[p.idx+1]) == "select" {
+			if err := p.parseInSubquery(condition); err != nil {
+				return nil, err
+			}
+
+			return condition, nil
+		}
+
+		values, err := p.parseValueList(dataType)
+		if err != nil {
+			return nil, err
 		}
+
+		condition.Values = values
+
+		return condition, nil
 	}
 
-	if !found {
-		return conditions, pkg.InvalidComparisonOperator
+	if isOneOf(operator, operators.NullOperators) {
+		return condition, nil
 	}
 
-	if !isEnclosedInQuote(value) {
-		return conditions, pkg.InvalidValueToken
+	if isOneOf(operator, operators.RangeOperators) {
+		values, err := p.parseRange(dataType)
+		if err != nil {
+			return nil, err
+		}
+
+		condition.Values = values
+
+		return condition, nil
 	}
 
-	if dataType != "" {
-		if err := validateDataType(dataType); err != nil {
-			return conditions, err
+	if isOneOf(operator, operators.PatternOperators) {
+		pattern, escape, err := p.parsePattern(dataType)
+		if err != nil {
+			return nil, err
 		}
 
-		if dataType == dataTypes.Int {
-			_, err := strconv.ParseInt(value[1:len(value)-1], 10, 64)
+		condition.Value = pattern
+		condition.Escape = escape
+
+		return condition, nil
+	}
+
+	if isOneOf(operator, operators.RegexOperators) {
+		pattern, regex, err := p.parseRegex(operator, dataType)
+		if err != nil {
+			return nil, err
+		}
+
+		condition.Value = pattern
+		condition.Regex = regex
+
+		return condition, nil
+	}
+
+	if isScalarFunction(p.tokens, p.idx) || isComputed(p.tokens, p.idx) || isConditionColumnReference(p.aliases, p.current()) {
+		e, err := p.parseValueExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(expressionColumns(e)) != 0 {
+			dataType, err := comparisonDataType(condition.DataType, expressionDataType(e))
 			if err != nil {
-				return conditions, fmt.Errorf("Expected a valid integer, got something else: %w", pkg.InvalidDataType)
+				return nil, err
 			}
+
+			condition.DataType = dataType
 		}
 
-		if dataType == dataTypes.Float {
-			_, err := strconv.ParseFloat(value[1:len(value)-1], 64)
-			if err != nil {
-				return conditions, fmt.Errorf("Expected a valid float, got something else: %w", pkg.InvalidDataType)
+		condition.ValueExpression = e
+
+		return condition, nil
+	}
+
+	value, err := p.parseValue(dataType)
+	if err != nil {
+		return nil, err
+	}
+
+	condition.Value = value
+
+	return condition, nil
+}
+
+// parseValueExpression parses a value that is computed, for example NOW() - INTERVAL '30 days'
+// or 'e.budget'::float * 1.1. Values that do not use columns are computed only once.
+func (p *conditionParser) parseValueExpression() (*Expression, error) {
+	e, nextIdx, err := validateExpression(p.aliases, p.tokens, p.idx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateConditionExpression(p.aliases, e); err != nil {
+		return nil, err
+	}
+	p.idx = nextIdx
+
+	return e, nil
+}
+
+// isConditionColumnReference checks if a compared value is a column. Quoted values are columns
+// only if they are in form {alias}.{columnName} with an alias of the query, anything else
+// is a string.
+func isConditionColumnReference(aliases tableAliases, token string) bool {
+	column, _ := getColumnAndDataType(token)
+	if !isEnclosedInQuote(column) {
+		return false
+	}
+
+	splitted := strings.Split(column[1:len(column)-1], ".")
+
+	return len(splitted) == 2 && aliases.has(splitted[0]) && splitted[1] != ""
+}
+
+// comparisonDataType is the data type two columns are compared as. A column without a data
+// type is compared as the data type of the other one. Integers, floats and decimals can be
+// compared with each other, any other data types must be the same.
+func comparisonDataType(left, right string) (string, error) {
+	if left == "" || left == right {
+		return right, nil
+	}
+
+	if right == "" || dataTypes.Base(left) == dataTypes.Base(right) || (dataTypes.IsTemporal(left) && dataTypes.IsTemporal(right)) {
+		return left, nil
+	}
+
+	numeric := func(dt string) bool {
+		return dt == dataTypes.Int || dt == dataTypes.Float || dataTypes.Base(dt) == dataTypes.Decimal
+	}
+
+	if !numeric(left) || !numeric(right) {
+		return "", fmt.Errorf("Cannot compare %s with %s: %w", left, right, pkg.InvalidDataType)
+	}
+
+	if left == dataTypes.Float || right == dataTypes.Float {
+		return dataTypes.Float, nil
+	}
+
+	if left == dataTypes.Int {
+		return right, nil
+	}
+
+	return left, nil
+}
+
+// parseConditionColumn parses the left side of a condition. The data type of an aggregate
+// function is the data type of its result, for example COUNT(*) is compared as an integer.
+func (p *conditionParser) parseConditionColumn() (*Condition, error) {
+	if p.aggregates && isAggregateFunction(p.tokens, p.idx) {
+		column, nextIdx, err := validateAggregateColumn(p.tokens, p.idx)
+		if err != nil {
+			return nil, err
+		}
+
+		if column.Column != "*" && !p.aliases.has(column.Alias) {
+			return nil, fmt.Errorf("Invalid condition column alias. Expected %s: %w", p.aliases, pkg.InvalidConditionAlias)
+		}
+		p.idx = nextIdx
+
+		return &Condition{
+			Alias:    column.Alias,
+			Column:   column.Column,
+			Function: column.Function,
+			DataType: aggregates.DataType(column.Function, column.DataType),
+		}, nil
+	}
+
+	if isComputed(p.tokens, p.idx) {
+		e, nextIdx, err := validateExpression(p.aliases, p.tokens, p.idx)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := validateConditionExpression(p.aliases, e); err != nil {
+			return nil, err
+		}
+
+		original := expressionString(p.tokens[p.idx:nextIdx])
+		p.idx = nextIdx
+
+		return &Condition{
+			Alias:      p.aliases[0],
+			Column:     original,
+			Expression: e,
+			DataType:   expressionDataType(e),
+		}, nil
+	}
+
+	extractedColumn, dataType := getColumnAndDataType(p.current())
+	alias, columnOnly, err := validateConditionColumn(p.aliases, extractedColumn)
+	if err != nil {
+		return nil, err
+	}
+	p.idx++
+
+	return &Condition{
+		Alias:    alias,
+		Column:   columnOnly,
+		DataType: dataType,
+	}, nil
+}
+
+// validateConditionExpression checks the aliases of columns of an expression. The alias
+// of the query is known so, unlike in the SELECT list, it is checked right away.
+func validateConditionExpression(aliases tableAliases, e *Expression) error {
+	for _, c := range expressionColumns(e) {
+		if !aliases.has(c.Alias) {
+			return fmt.Errorf("Invalid condition column alias. Expected %s, got %s: %w", aliases, c.Alias, pkg.InvalidConditionAlias)
+		}
+	}
+
+	return nil
+}
+
+func (p *conditionParser) parseComparisonOperator() (string, error) {
+	// keyword operators can span multiple tokens, for example NOT IN
+	for _, o := range operators.Operators {
+		words := strings.Split(o, " ")
+
+		matched := true
+		for i, w := range words {
+			if strings.ToLower(p.tokens[p.idx+i]) != w {
+				matched = false
+				break
 			}
 		}
+
+		if matched {
+			p.idx += len(words)
+			return o, nil
+		}
 	}
 
-	logicalOperator := strings.ToLower(tokens[startIdx+3])
+	return "", pkg.InvalidComparisonOperator
+}
+
+func (p *conditionParser) parseValue(dataType string) (string, error) {
+	value := p.current()
+	if value == "" || !isEnclosedInQuote(value) {
+		return "", pkg.InvalidValueToken
+	}
+
+	unquoted := value[1 : len(value)-1]
+	if err := validateValueDataType(dataType, unquoted); err != nil {
+		return "", err
+	}
+	p.idx++
+
+	return unquoted, nil
+}
 
-	condition := Condition{
-		Alias:              alias,
-		Column:             columnOnly,
-		Value:              value[1 : len(value)-1],
-		DataType:           dataType,
-		ComparisonOperator: operator,
+// parseValueList parses a parenthesized, comma separated list of values like ('A', 'B', 'C')
+func (p *conditionParser) parseValueList(dataType string) ([]string, error) {
+	if p.current() != "(" {
+		return nil, fmt.Errorf("Expected a list of values enclosed in parentheses: %w", pkg.InvalidValueToken)
 	}
+	p.idx++
+
+	values := make([]string, 0)
+	for {
+		value, err := p.parseValue(dataType)
+		if err != nil {
+			return nil, err
+		}
 
-	if logicalOperator != operators.AndOperator && logicalOperator != operators.OrOperator {
-		conditions = append(conditions, condition)
+		values = append(values, value)
+
+		if p.current() == ")" {
+			p.idx++
+			return values, nil
+		}
 
-		return conditions, nil
+		if p.current() != "," {
+			return nil, fmt.Errorf("Expected a comma or a closing parenthesis in a list of values, got %s: %w", p.current(), pkg.InvalidValueToken)
+		}
+		p.idx++
 	}
+}
 
-	if logicalOperator == operators.AndOperator || logicalOperator == operators.OrOperator {
-		condition.LogicalOperator = logicalOperator
-		conditions = append(conditions, condition)
+// parseRange parses the lower and the upper bound of BETWEEN. The AND between them
+// belongs to BETWEEN and is not a logical operator.
+func (p *conditionParser) parseRange(dataType string) ([]string, error) {
+	low, err := p.parseValue(dataType)
+	if err != nil {
+		return nil, err
+	}
 
-		c, err := validateConditions(alias, tokens, startIdx+4)
+	if strings.ToLower(p.current()) != operators.AndOperator {
+		return nil, fmt.Errorf("Expected AND between the lower and the upper bound of BETWEEN, got %s: %w", p.current(), pkg.InvalidValueToken)
+	}
+	p.idx++
+
+	high, err := p.parseValue(dataType)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{low, high}, nil
+}
+
+// parsePattern parses the LIKE pattern and the optional ESCAPE clause. The default
+// escape character is a backslash.
+func (p *conditionParser) parsePattern(dataType string) (string, string, error) {
+	if dataType != "" && dataType != dataTypes.String {
+		return "", "", fmt.Errorf("Pattern matching can only be done on string columns, got %s: %w", dataType, pkg.InvalidDataType)
+	}
+
+	pattern, err := p.parseValue(dataType)
+	if err != nil {
+		return "", "", err
+	}
+
+	escape := "\\"
+	if strings.ToLower(p.current()) == operators.EscapeKeyword {
+		p.idx++
+
+		e, err := p.parseValue(dataType)
 		if err != nil {
-			return conditions, err
+			return "", "", err
+		}
+
+		if len([]rune(e)) > 1 {
+			return "", "", fmt.Errorf("ESCAPE must be a single character or empty, got %s: %w", e, pkg.InvalidValueToken)
 		}
 
-		conditions = append(conditions, c...)
+		escape = e
+	}
+
+	escaped := false
+	for _, r := range pattern {
+		escaped = !escaped && string(r) == escape
+	}
+
+	if escaped {
+		return "", "", fmt.Errorf("Pattern %s must not end with the escape character: %w", pattern, pkg.InvalidValueToken)
+	}
+
+	return pattern, escape, nil
+}
+
+// parseRegex compiles the regular expression here so that an invalid one is reported
+// before any row is read. ~* and !~* are case insensitive.
+func (p *conditionParser) parseRegex(operator, dataType string) (string, *regexp.Regexp, error) {
+	if dataType != "" && dataType != dataTypes.String {
+		return "", nil, fmt.Errorf("Regular expression matching can only be done on string columns, got %s: %w", dataType, pkg.InvalidDataType)
+	}
+
+	pattern, err := p.parseValue(dataType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	toCompile := pattern
+	if operator == operators.RegexIMatchOperator || operator == operators.NotRegexIMatchOperator {
+		toCompile = "(?i)" + pattern
+	}
+
+	regex, err := regexp.Compile(toCompile)
+	if err != nil {
+		return "", nil, fmt.Errorf("Regular expression %s does not compile: %s: %w", pattern, err.Error(), pkg.InvalidRegex)
+	}
+
+	return pattern, regex, nil
+}
+
+func getColumnAndDataType(c string) (string, string) {
+	dtSplit := strings.Split(c, "::")
+
+	var columnOnly string
+	var dataType string
+	if len(dtSplit) == 2 {
+		columnOnly = dtSplit[0]
+		dataType = dtSplit[1]
 	} else {
-		conditions = append(conditions, condition)
+		columnOnly = c
+	}
+
+	return columnOnly, dataType
+}
+
+// validateConditionColumn returns the alias and the name of a compared column
+func validateConditionColumn(aliases tableAliases, c string) (string, string, error) {
+	if c == "" || !isEnclosedInQuote(c) {
+		return "", "", pkg.InvalidSelectableColumns
+	}
+
+	columnOnly := c[1 : len(c)-1]
+	splitted := strings.Split(columnOnly, ".")
+
+	if len(splitted) != 2 {
+		return "", "", fmt.Errorf("Condition column have to be in form {alias}.{columnName}: %w", pkg.InvalidConditionColumn)
+	}
+
+	if !aliases.has(splitted[0]) {
+		return "", "", fmt.Errorf("Invalid condition column alias. Expected %s: %w", aliases, pkg.InvalidConditionAlias)
+	}
+
+	return splitted[0], splitted[1], nil
+}
+
+func validateDataType(dt string) error {
+	if dataTypes.Hint(dt) != "" || dataTypes.Base(dt) != dt {
+		if dataTypes.Base(dt) == dataTypes.Decimal {
+			if _, _, ok := dataTypes.Precision(dt); !ok {
+				return fmt.Errorf("Decimal must be in form decimal(precision,scale), got %s: %w", dt, pkg.InvalidDataType)
+			}
+		} else if !dataTypes.IsTemporal(dt) || dataTypes.Layout(dt) == "" {
+			return fmt.Errorf("Only date, timestamp and time can have a layout, in form date('02/01/2006'), got %s: %w", dt, pkg.InvalidDataType)
+		}
+	}
+
+	for _, d := range dataTypes.DataTypes {
+		if d == dataTypes.Base(dt) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Invalid data type. Expected one of %s, got something else: %w", strings.Join(dataTypes.DataTypes, ","), pkg.InvalidDataType)
+}
+
+func validateValueDataType(dataType, value string) error {
+	if dataType == "" {
+		return nil
+	}
+
+	if err := validateDataType(dataType); err != nil {
+		return err
+	}
+
+	if dataType == dataTypes.Int {
+		_, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Expected a valid integer, got something else: %w", pkg.InvalidDataType)
+		}
+	}
+
+	if dataType == dataTypes.Float {
+		_, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("Expected a valid float, got something else: %w", pkg.InvalidDataType)
+		}
+	}
+
+	if dataTypes.Base(dataType) == dataTypes.Bool {
+		if _, err := dataTypes.ParseBool(value); err != nil {
+			return fmt.Errorf("Expected a valid bool, got %s: %w", value, pkg.InvalidDataType)
+		}
+	}
+
+	if dataTypes.Base(dataType) == dataTypes.Decimal {
+		if err := dataTypes.ValidateDecimal(dataType, value); err != nil {
+			return fmt.Errorf("%s: %w", err.Error(), pkg.InvalidDataType)
+		}
+	}
+
+	if dataTypes.IsTemporal(dataType) {
+		if _, err := dataTypes.ParseQueryTime(dataType, value); err != nil {
+			return fmt.Errorf("Expected a valid %s, got %s: %w", dataTypes.Base(dataType), value, pkg.InvalidDataType)
+		}
 	}
 
-	return conditions, nil
+	return nil
 }
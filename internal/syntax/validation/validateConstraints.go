@@ -2,130 +2,274 @@ package validation
 
 import (
 	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/aggregates"
 	"github.com/MarioLegenda/cig/internal/syntax/operators"
 	"github.com/MarioLegenda/cig/pkg"
 	"strconv"
 	"strings"
 )
 
-func validateConstraints(alias string, tokens []string, startIdx int) (Limit, Offset, *OrderBy, error) {
+type constraints struct {
+	limit   Limit
+	offset  Offset
+	orderBy *OrderBy
+	groupBy []GroupByColumn
+	having  *ConditionNode
+}
+
+func validateConstraints(aliases tableAliases, tokens []string, startIdx int) (constraints, error) {
+	c := constraints{
+		limit:  -1,
+		offset: -1,
+	}
+
 	if tokens[startIdx] == "" {
-		return -1, -1, nil, nil
+		return c, nil
 	}
 
 	orderByColumns := make([]OrderByColumn, 0)
-	var direction string
-	var offset Offset = -1
-	var limit Limit = -1
-
-	validateColumn := func(alias, c string) (string, error) {
-		if !isEnclosedInQuote(c) {
-			return "", fmt.Errorf("Invalid ORDER BY column. Colums must be enclosed by single quotes: %w", pkg.InvalidOrderBy)
-		}
-
-		columnOnly := c[1 : len(c)-1]
-		splitted := strings.Split(columnOnly, ".")
-		if len(splitted) != 2 {
-			return "", fmt.Errorf("Invalid ORDER BY column. Column does not specify an alias: %w", pkg.InvalidOrderBy)
-		}
-
-		if splitted[0] != alias {
-			return "", fmt.Errorf("Invalid ORDER BY column. Expected alias %s, got %s: %w", alias, splitted[0], pkg.InvalidOrderBy)
-		}
-
-		return splitted[1], nil
-	}
 
 	/**
 		Order by validation
 
 		1. ORDER BY must follow at least one column
-	    	1.1. If the next token is a ",", then the token after that MUST be a column
-			1.2. If the next token is not a ",", the token after that CAN be either DESC or ASC
-			1.3. If the token is not DESC or ASC, consider ORDER BY validated and move on
+			1.1. Every column CAN be followed by DESC or ASC and then by NULLS FIRST or NULLS LAST
+	    	1.2. If the next token is a ",", then the token after that MUST be a column
+			1.3. If the next token is not a ",", consider ORDER BY validated and move on
 	*/
 	for i := startIdx; i < len(tokens); i++ {
 		token := strings.ToLower(tokens[i])
 
 		// end of line, only appended buffers after this
 		if token == "" {
-			return limit, offset, &OrderBy{
-				Columns:   orderByColumns,
-				Direction: direction,
-			}, nil
+			break
 		}
 
 		if token == "order" {
 			// token after order must be "by"
 			if strings.ToLower(tokens[i+1]) != "by" {
-				return limit, offset, nil, fmt.Errorf("Expected BY, got something else: %w", pkg.InvalidOrderBy)
+				return c, fmt.Errorf("Expected BY, got something else: %w", pkg.InvalidOrderBy)
 			}
 
-			// this must be a column
-			firstColumn := tokens[i+2]
-			resolvedColumn, err := validateColumn(alias, firstColumn)
+			columns, a, err := validateOrderByKeys(aliases, tokens, i+2)
 			if err != nil {
-				return limit, offset, nil, err
+				return c, err
+			}
+
+			orderByColumns = append(orderByColumns, columns...)
+
+			// skip ORDER BY, the loop increments the index
+			i = a - 1
+		} else if token == "group" {
+			if strings.ToLower(tokens[i+1]) != "by" {
+				return c, fmt.Errorf("Expected BY, got something else: %w", pkg.InvalidGroupBy)
 			}
 
-			orderByColumns = append(orderByColumns, OrderByColumn{
-				Alias:  alias,
-				Column: resolvedColumn,
-			})
-
-			// advance the pointer to be after "by" and the first column
-			a := i + 3
-			// this loop must not go to the end of all tokens
-			for a < len(tokens) {
-				comma := tokens[a]
-
-				if comma == "," {
-					nextColumn := a + 1
-					resolvedColumn, err := validateColumn(alias, tokens[nextColumn])
-					if err != nil {
-						return limit, offset, nil, err
-					}
-
-					orderByColumns = append(orderByColumns, OrderByColumn{
-						Alias:  alias,
-						Column: resolvedColumn,
-					})
-
-					a = a + 2
-
-					continue
-				} else if strings.ToLower(comma) == "desc" || strings.ToLower(comma) == "asc" {
-					direction = operators.Desc
-					if strings.ToLower(comma) == "asc" {
-						direction = operators.Asc
-					}
+			// GROUP BY is a comma separated list of columns
+			a := i + 2
+			for {
+				alias, resolvedColumn, err := validateConstraintColumn(aliases, tokens[a], "GROUP BY", pkg.InvalidGroupBy)
+				if err != nil {
+					return c, err
 				}
 
-				break
+				c.groupBy = append(c.groupBy, GroupByColumn{
+					Alias:  alias,
+					Column: resolvedColumn,
+				})
+
+				if tokens[a+1] != "," {
+					break
+				}
+
+				a += 2
+			}
+		} else if token == "having" {
+			having, nextIdx, err := validateHavingConditions(aliases, tokens, i+1)
+			if err != nil {
+				return c, err
+			}
+
+			if having == nil {
+				return c, fmt.Errorf("Expected a condition after HAVING: %w", pkg.InvalidHaving)
 			}
+
+			c.having = having
+			// skip the condition, the loop increments the index
+			i = nextIdx - 1
 		} else if token == "offset" {
 			nextToken := tokens[i+1]
 
 			value, err := strconv.ParseInt(nextToken, 10, 64)
 			if err != nil {
-				return 0, 0, nil, fmt.Errorf("Expected OFFSET to be a valid integer, got something else: %w: %w", err, pkg.InvalidOrderBy)
+				return c, fmt.Errorf("Expected OFFSET to be a valid integer, got something else: %w: %w", err, pkg.InvalidOrderBy)
 			}
 
-			offset = value
+			c.offset = value
 		} else if token == "limit" {
 			nextToken := tokens[i+1]
 
 			value, err := strconv.ParseInt(nextToken, 10, 64)
 			if err != nil {
-				return 0, 0, nil, fmt.Errorf("Expected LIMIT to be a valid integer, got something else: %w: %w", err, pkg.InvalidOrderBy)
+				return c, fmt.Errorf("Expected LIMIT to be a valid integer, got something else: %w: %w", err, pkg.InvalidOrderBy)
+			}
+
+			c.limit = value
+		}
+	}
+
+	c.orderBy = &OrderBy{
+		Columns: orderByColumns,
+	}
+
+	return c, nil
+}
+
+// validateOrderByColumn validates a column of ORDER BY which is either computed, an aggregate
+// function or a column with an optional data type
+func validateOrderByColumn(aliases tableAliases, tokens []string, i int) (OrderByColumn, int, error) {
+	if isComputed(tokens, i) {
+		e, nextIdx, err := validateExpression(aliases, tokens, i)
+		if err != nil {
+			return OrderByColumn{}, i, err
+		}
+
+		for _, c := range expressionColumns(e) {
+			if !aliases.has(c.Alias) {
+				return OrderByColumn{}, i, fmt.Errorf("Invalid ORDER BY column. Expected alias %s, got %s: %w", aliases, c.Alias, pkg.InvalidOrderBy)
 			}
+		}
+
+		return OrderByColumn{
+			Alias:      aliases[0],
+			Column:     expressionString(tokens[i:nextIdx]),
+			DataType:   expressionDataType(e),
+			Expression: e,
+		}, nextIdx, nil
+	}
+
+	if isAggregateFunction(tokens, i) {
+		column, nextIdx, err := validateAggregateColumn(tokens, i)
+		if err != nil {
+			return OrderByColumn{}, i, err
+		}
 
-			limit = value
+		if column.Column != "*" && !aliases.has(column.Alias) {
+			return OrderByColumn{}, i, fmt.Errorf("Invalid ORDER BY column. Expected alias %s, got %s: %w", aliases, column.Alias, pkg.InvalidOrderBy)
 		}
+
+		return OrderByColumn{
+			Alias:    column.Alias,
+			Column:   column.Column,
+			Function: column.Function,
+			DataType: column.DataType,
+		}, nextIdx, nil
+	}
+
+	// a data type decides how the column is sorted, for example 'e.created'::date
+	column, dataType := getColumnAndDataType(tokens[i])
+	if dataType != "" {
+		if err := validateDataType(dataType); err != nil {
+			return OrderByColumn{}, i, err
+		}
+	}
+
+	alias, resolvedColumn, err := validateConstraintColumn(aliases, column, "ORDER BY", pkg.InvalidOrderBy)
+	if err != nil {
+		return OrderByColumn{}, i, err
+	}
+
+	return OrderByColumn{
+		Alias:    alias,
+		Column:   resolvedColumn,
+		DataType: dataType,
+	}, i + 1, nil
+}
+
+// validateOrderByKey validates a column followed by an optional direction and an optional
+// NULLS FIRST or NULLS LAST. As in SQL, NULL values are the largest values by default, so
+// they come last when ascending and first when descending.
+func validateOrderByKey(aliases tableAliases, tokens []string, i int) (OrderByColumn, int, error) {
+	column, a, err := validateOrderByColumn(aliases, tokens, i)
+	if err != nil {
+		return OrderByColumn{}, i, err
+	}
+
+	dataType := column.DataType
+	if column.Function != "" {
+		dataType = aggregates.DataType(column.Function, column.DataType)
+	}
+
+	column.Collation, a, err = validateCollation(tokens, a, dataType)
+	if err != nil {
+		return OrderByColumn{}, i, err
+	}
+
+	column.Direction = operators.Asc
+	if d := strings.ToLower(tokens[a]); d == operators.Asc || d == operators.Desc {
+		column.Direction = d
+		a++
+	}
+
+	column.Nulls = operators.NullsLast
+	if column.Direction == operators.Desc {
+		column.Nulls = operators.NullsFirst
+	}
+
+	if strings.ToLower(tokens[a]) == operators.NullsKeyword {
+		n := strings.ToLower(tokens[a+1])
+		if n != operators.NullsFirst && n != operators.NullsLast {
+			return OrderByColumn{}, i, fmt.Errorf("Expected NULLS FIRST or NULLS LAST, got NULLS %s: %w", tokens[a+1], pkg.InvalidOrderBy)
+		}
+
+		column.Nulls = n
+		a += 2
+	}
+
+	if d := strings.ToLower(tokens[a]); d == operators.Asc || d == operators.Desc || d == operators.NullsKeyword {
+		return OrderByColumn{}, i, fmt.Errorf("Expected a column in form [ASC|DESC] [NULLS FIRST|NULLS LAST], got %s after it: %w", tokens[a], pkg.InvalidOrderBy)
+	}
+
+	return column, a, nil
+}
+
+// validateOrderByKeys validates a comma separated list of ORDER BY columns. There must be
+// at least one column.
+func validateOrderByKeys(aliases tableAliases, tokens []string, i int) ([]OrderByColumn, int, error) {
+	column, a, err := validateOrderByKey(aliases, tokens, i)
+	if err != nil {
+		return nil, i, err
+	}
+
+	columns := []OrderByColumn{column}
+	for tokens[a] == "," {
+		column, a, err = validateOrderByKey(aliases, tokens, a+1)
+		if err != nil {
+			return nil, i, err
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, a, nil
+}
+
+// validateConstraintColumn validates a column of ORDER BY or GROUP BY, clause is used
+// in error messages and err is the error to wrap. It returns the alias and the name of the column.
+func validateConstraintColumn(aliases tableAliases, c, clause string, err error) (string, string, error) {
+	if !isEnclosedInQuote(c) {
+		return "", "", fmt.Errorf("Invalid %s column. Colums must be enclosed by single quotes: %w", clause, err)
+	}
+
+	columnOnly := c[1 : len(c)-1]
+	splitted := strings.Split(columnOnly, ".")
+	if len(splitted) != 2 {
+		return "", "", fmt.Errorf("Invalid %s column. Column does not specify an alias: %w", clause, err)
+	}
+
+	if !aliases.has(splitted[0]) {
+		return "", "", fmt.Errorf("Invalid %s column. Expected alias %s, got %s: %w", clause, aliases, splitted[0], err)
 	}
 
-	return limit, offset, &OrderBy{
-		Columns:   orderByColumns,
-		Direction: direction,
-	}, nil
+	return splitted[0], splitted[1], nil
 }
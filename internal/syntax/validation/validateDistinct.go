@@ -0,0 +1,61 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/pkg"
+	"strings"
+)
+
+// validateDistinct validates the optional DISTINCT or DISTINCT ON ('alias.column', ...) after
+// SELECT. It returns the number of tokens to skip and the columns of DISTINCT ON.
+func validateDistinct(tokens []string, startIdx int) (bool, []SelectableColumn, int, error) {
+	if strings.ToLower(tokens[startIdx]) != "distinct" {
+		return false, nil, 0, nil
+	}
+
+	if strings.ToLower(tokens[startIdx+1]) != "on" {
+		return true, nil, 1, nil
+	}
+
+	i := startIdx + 2
+	if tokens[i] != "(" {
+		return false, nil, 0, fmt.Errorf("Expected DISTINCT ON columns enclosed in parentheses: %w", pkg.InvalidDistinct)
+	}
+	i++
+
+	columns := make([]SelectableColumn, 0)
+	for {
+		column, nextIdx, err := validateSelectableColumn(tokens, i)
+		if err != nil {
+			return false, nil, 0, fmt.Errorf("Invalid DISTINCT ON column: %w: %w", err, pkg.InvalidDistinct)
+		}
+
+		columns = append(columns, column)
+		i = nextIdx
+
+		if tokens[i] == ")" {
+			return true, columns, i + 1 - startIdx, nil
+		}
+
+		if tokens[i] != "," {
+			return false, nil, 0, fmt.Errorf("Expected a comma or a closing parenthesis in DISTINCT ON, got %s: %w", tokens[i], pkg.InvalidDistinct)
+		}
+		i++
+	}
+}
+
+// validateDistinctOnColumns checks the aliases of DISTINCT ON columns and, if the query is grouped,
+// that they are grouped since only grouped columns exist after grouping.
+func validateDistinctOnColumns(aliases tableAliases, columns []SelectableColumn, groupBy []GroupByColumn, aggregates []SelectableColumn) error {
+	for _, c := range columns {
+		if !aliases.has(c.Alias) {
+			return fmt.Errorf("Invalid DISTINCT ON column. Expected alias %s, got %s: %w", aliases, c.Alias, pkg.InvalidDistinct)
+		}
+
+		if (len(groupBy) != 0 || len(aggregates) != 0) && !isGrouped(c.Column, groupBy) {
+			return fmt.Errorf("DISTINCT ON column %s must be in GROUP BY: %w", c.Column, pkg.InvalidDistinct)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,536 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/functions"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/internal/syntax/windows"
+	"github.com/MarioLegenda/cig/pkg"
+	"strconv"
+	"strings"
+)
+
+type expressionParser struct {
+	tokens []string
+	idx    int
+	// aliases of the query, empty in the SELECT list since they are not known yet
+	aliases tableAliases
+	// depth of function calls, quoted values in arguments of functions can be strings
+	functionDepth int
+}
+
+// validateExpression parses an expression like 'e.Value'::float * 1000 or LOWER('e.name') and
+// returns it together with the index of the first token after it. * / and % bind stronger than
+// + and -, parentheses override precedence. Operators must be separated from operands by spaces.
+func validateExpression(aliases tableAliases, tokens []string, startIdx int) (*Expression, int, error) {
+	p := &expressionParser{
+		tokens:  tokens,
+		idx:     startIdx,
+		aliases: aliases,
+	}
+
+	e, err := p.parseAdditive()
+	if err != nil {
+		return nil, startIdx, err
+	}
+
+	if err := validateExpressionTypes(e); err != nil {
+		return nil, startIdx, err
+	}
+
+	return e, p.idx, nil
+}
+
+// isExpressionStart decides if the token can start a column or an expression of the SELECT list
+func isExpressionStart(tokens []string, i int) bool {
+	token := tokens[i]
+	if token == "" {
+		return false
+	}
+
+	column, _ := getColumnAndDataType(token)
+	if isEnclosedInQuote(column) || token == "(" || isWindowFunction(tokens, i) || isAggregateFunction(tokens, i) || isScalarFunction(tokens, i) || isCase(tokens, i) {
+		return true
+	}
+
+	_, err := strconv.ParseFloat(token, 64)
+
+	return err == nil
+}
+
+// isComputed checks if a condition or an ORDER BY column at i is computed, either by a function,
+// by CASE or by arithmetic like 'e.created'::date + INTERVAL '1 day'
+func isComputed(tokens []string, i int) bool {
+	if isScalarFunction(tokens, i) || isCase(tokens, i) {
+		return true
+	}
+
+	return i+1 < len(tokens) && (isOneOf(tokens[i+1], operators.AdditiveOperators) || isOneOf(tokens[i+1], operators.MultiplicativeOperators))
+}
+
+// isScalarFunction checks if a function is called at i. CURRENT_DATE is called without parentheses.
+func isScalarFunction(tokens []string, i int) bool {
+	name := strings.ToLower(tokens[i])
+	if name == functions.CurrentDate {
+		return true
+	}
+
+	return functions.IsFunction(name) && i+1 < len(tokens) && tokens[i+1] == "("
+}
+
+func (p *expressionParser) current() string {
+	return p.tokens[p.idx]
+}
+
+func (p *expressionParser) parseAdditive() (*Expression, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for isOneOf(p.current(), operators.AdditiveOperators) {
+		operator := p.current()
+		p.idx++
+
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &Expression{
+			Operator: operator,
+			Left:     arithmeticOperand(left),
+			Right:    arithmeticOperand(right),
+		}
+	}
+
+	return left, nil
+}
+
+func (p *expressionParser) parseMultiplicative() (*Expression, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for isOneOf(p.current(), operators.MultiplicativeOperators) {
+		operator := p.current()
+		p.idx++
+
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &Expression{
+			Operator: operator,
+			Left:     arithmeticOperand(left),
+			Right:    arithmeticOperand(right),
+		}
+	}
+
+	return left, nil
+}
+
+func (p *expressionParser) parseOperand() (*Expression, error) {
+	token := p.current()
+
+	if isWindowFunction(p.tokens, p.idx) {
+		return nil, fmt.Errorf("Window functions can only be selected, %s cannot be used in an expression: %w", strings.ToUpper(token), pkg.InvalidWindow)
+	}
+
+	if isScalarFunction(p.tokens, p.idx) {
+		return p.parseFunction()
+	}
+
+	if isCase(p.tokens, p.idx) {
+		return p.parseCase()
+	}
+
+	if token == "(" {
+		p.idx++
+		e, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.current() != ")" {
+			return nil, fmt.Errorf("Expected closing parenthesis in expression, got %s: %w", p.current(), pkg.InvalidParenthesis)
+		}
+		p.idx++
+
+		return e, nil
+	}
+
+	if _, err := strconv.ParseFloat(token, 64); err == nil {
+		p.idx++
+
+		return &Expression{Literal: token}, nil
+	}
+
+	if strings.ToLower(token) == operators.IntervalKeyword {
+		return p.parseInterval()
+	}
+
+	// numbers can be quoted like the values of conditions, they are strings only in arguments
+	// of functions and values of CASE, unless they are used in arithmetic
+	if isEnclosedInQuote(token) && isNumber(token[1:len(token)-1]) {
+		p.idx++
+
+		if p.functionDepth > 0 {
+			return &Expression{
+				Literal:  token[1 : len(token)-1],
+				DataType: dataTypes.String,
+			}, nil
+		}
+
+		return &Expression{Literal: token[1 : len(token)-1]}, nil
+	}
+
+	if p.functionDepth > 0 && isEnclosedInQuote(token) && !p.isColumnReference(token) {
+		p.idx++
+
+		return &Expression{
+			Literal:  token[1 : len(token)-1],
+			DataType: dataTypes.String,
+		}, nil
+	}
+
+	extractedColumn, dataType := getColumnAndDataType(token)
+	column, _, err := validateSelectableColumn([]string{extractedColumn}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if dataType != "" {
+		if err := validateDataType(dataType); err != nil {
+			return nil, err
+		}
+	}
+	p.idx++
+
+	return &Expression{
+		Alias:    column.Alias,
+		Column:   column.Column,
+		DataType: dataType,
+	}, nil
+}
+
+// arithmeticOperand turns a quoted number in arguments of functions, which is a string, into
+// a number when it is used in arithmetic
+func arithmeticOperand(e *Expression) *Expression {
+	if e.Column == "" && e.Function == "" && e.Operator == "" && e.DataType == dataTypes.String && isNumber(e.Literal) {
+		e.DataType = ""
+	}
+
+	return e
+}
+
+func isNumber(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+
+	return err == nil
+}
+
+// parseInterval parses INTERVAL '7 days'
+func (p *expressionParser) parseInterval() (*Expression, error) {
+	p.idx++
+	value := p.current()
+	if !isEnclosedInQuote(value) {
+		return nil, fmt.Errorf("Expected a quoted value after INTERVAL, got %s: %w", value, pkg.InvalidDataType)
+	}
+
+	value = value[1 : len(value)-1]
+	if _, err := dataTypes.ParseInterval(value); err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), pkg.InvalidDataType)
+	}
+	p.idx++
+
+	return &Expression{
+		Literal:  value,
+		DataType: dataTypes.Interval,
+	}, nil
+}
+
+// parseFunction parses a call of a scalar function like REPLACE('e.code', '-', ”)
+func (p *expressionParser) parseFunction() (*Expression, error) {
+	name := strings.ToLower(p.current())
+	if name == functions.CurrentDate && p.tokens[p.idx+1] != "(" {
+		p.idx++
+
+		return &Expression{Function: name}, nil
+	}
+
+	// skip the name and the opening parenthesis
+	p.idx += 2
+
+	arguments := make([]*Expression, 0)
+	if name == functions.Extract {
+		// EXTRACT(field FROM value), the field is passed as the first argument
+		field := strings.ToLower(p.current())
+		if strings.ToLower(p.tokens[p.idx+1]) != "from" {
+			return nil, fmt.Errorf("Expected EXTRACT(field FROM value): %w", pkg.InvalidFunction)
+		}
+		p.idx += 2
+
+		arguments = append(arguments, &Expression{Literal: field, DataType: dataTypes.String})
+	}
+
+	if p.current() == ")" {
+		p.idx++
+	} else {
+		for {
+			p.functionDepth++
+			argument, err := p.parseAdditive()
+			p.functionDepth--
+
+			if err != nil {
+				return nil, err
+			}
+
+			arguments = append(arguments, argument)
+
+			if p.current() == ")" {
+				p.idx++
+				break
+			}
+
+			if p.current() != "," {
+				return nil, fmt.Errorf("Expected a comma or a closing parenthesis in arguments of %s, got %s: %w", strings.ToUpper(name), p.current(), pkg.InvalidFunction)
+			}
+			p.idx++
+		}
+	}
+
+	f := functions.Functions[name]
+	if len(arguments) < f.MinArguments || (f.MaxArguments != -1 && len(arguments) > f.MaxArguments) {
+		return nil, fmt.Errorf("Invalid number of arguments of %s, got %d: %w", strings.ToUpper(name), len(arguments), pkg.InvalidFunction)
+	}
+
+	if name == functions.Extract || name == functions.DateTrunc {
+		if err := validateTemporalFunction(name, arguments); err != nil {
+			return nil, err
+		}
+	}
+
+	e := &Expression{
+		Function:  name,
+		Arguments: arguments,
+	}
+
+	if isConditional(e) {
+		dataType, err := conditionalDataType(e)
+		if err != nil {
+			return nil, fmt.Errorf("Arguments of %s must have the same data type: %w", strings.ToUpper(name), err)
+		}
+
+		e.DataType = dataType
+	}
+
+	return e, nil
+}
+
+// validateTemporalFunction checks the field of EXTRACT and DATE_TRUNC and that the value
+// can be a date or a time. Columns without a data type and strings are parsed as ISO-8601.
+func validateTemporalFunction(name string, arguments []*Expression) error {
+	fields := functions.ExtractFields
+	if name == functions.DateTrunc {
+		fields = functions.TruncateFields
+	}
+
+	field := arguments[0]
+	if field.DataType != dataTypes.String || !isOneOf(strings.ToLower(field.Literal), fields) {
+		return fmt.Errorf("%s expects one of %s, got %s: %w", strings.ToUpper(name), strings.Join(fields, ", "), field.Literal, pkg.InvalidFunction)
+	}
+	field.Literal = strings.ToLower(field.Literal)
+
+	dataType := expressionDataType(arguments[1])
+	if arguments[1].Literal != "" && arguments[1].DataType == "" {
+		dataType = dataTypes.Int
+	}
+
+	if dataType != "" && dataType != dataTypes.String && !dataTypes.IsTemporal(dataType) {
+		return fmt.Errorf("%s expects a date or a time, got %s: %w", strings.ToUpper(name), dataType, pkg.InvalidFunction)
+	}
+
+	return nil
+}
+
+// isColumnReference decides if a quoted value in arguments of a function is a column. It is a column
+// if it is in form 'alias.column' and, once the aliases of the query are known, if the alias is one of them.
+func (p *expressionParser) isColumnReference(token string) bool {
+	splitted := strings.Split(token[1:len(token)-1], ".")
+	if len(splitted) != 2 || splitted[0] == "" || splitted[1] == "" {
+		return false
+	}
+
+	return len(p.aliases) == 0 || p.aliases.has(splitted[0])
+}
+
+// resolveFunctionLiterals turns quoted values in arguments of functions and in values of CASE of
+// the SELECT list that looked like columns into strings if their alias is not an alias of the
+// query. The aliases are not known while the SELECT list is parsed.
+func resolveFunctionLiterals(aliases tableAliases, columns []SelectableColumn) {
+	var walk func(e *Expression)
+	walk = func(e *Expression) {
+		if e == nil {
+			return
+		}
+
+		for _, a := range literalOperands(e) {
+			if a.Column != "" && a.DataType == "" && !aliases.has(a.Alias) {
+				a.Literal = fmt.Sprintf("%s.%s", a.Alias, a.Column)
+				a.DataType = dataTypes.String
+				a.Alias = ""
+				a.Column = ""
+			}
+
+			walk(a)
+		}
+
+		walk(e.Left)
+		walk(e.Right)
+
+		// a value that became a string changes the data type of CASE, COALESCE and NULLIF
+		if isConditional(e) {
+			e.DataType, _ = conditionalDataType(e)
+		}
+	}
+
+	for _, c := range columns {
+		walk(c.Expression)
+	}
+}
+
+// expressionDataType is the data type of the value of an expression. Arithmetic gives
+// an int only if both operands are integers. Numbers without a data type are floats.
+func expressionDataType(e *Expression) string {
+	if isConditional(e) {
+		return e.DataType
+	}
+
+	if e.Function != "" {
+		return functions.Functions[e.Function].DataType
+	}
+
+	if e.Operator != "" {
+		// adding an interval to a date gives a date
+		for _, operand := range []*Expression{e.Left, e.Right} {
+			if dataTypes.IsTemporal(expressionDataType(operand)) {
+				return dataTypes.Base(expressionDataType(operand))
+			}
+		}
+
+		if expressionDataType(e.Left) == dataTypes.Int && expressionDataType(e.Right) == dataTypes.Int {
+			return dataTypes.Int
+		}
+
+		// decimals stay exact with integers and numbers, a float makes the result a float
+		if isExact(e.Left) && isExact(e.Right) && (isDecimal(e.Left) || isDecimal(e.Right)) {
+			return dataTypes.Decimal
+		}
+
+		return dataTypes.Float
+	}
+
+	if e.Column == "" && e.DataType == "" {
+		if _, err := strconv.ParseInt(e.Literal, 10, 64); err == nil {
+			return dataTypes.Int
+		}
+
+		return dataTypes.Float
+	}
+
+	return e.DataType
+}
+
+func isDecimal(e *Expression) bool {
+	return dataTypes.Base(expressionDataType(e)) == dataTypes.Decimal
+}
+
+// isExact checks if an operand can be used in decimal arithmetic without losing precision
+func isExact(e *Expression) bool {
+	return isDecimal(e) || expressionDataType(e) == dataTypes.Int || (e.Literal != "" && e.DataType == "")
+}
+
+// validateExpressionTypes checks that only numbers are used in arithmetic
+func validateExpressionTypes(e *Expression) error {
+	if e == nil {
+		return nil
+	}
+
+	for _, a := range literalOperands(e) {
+		if err := validateExpressionTypes(a); err != nil {
+			return err
+		}
+	}
+
+	if e.Operator == "" {
+		return nil
+	}
+
+	left := expressionDataType(e.Left)
+	right := expressionDataType(e.Right)
+	if dataTypes.IsTemporal(left) || dataTypes.IsTemporal(right) || left == dataTypes.Interval || right == dataTypes.Interval {
+		// an interval can be added to a date or subtracted from it
+		valid := dataTypes.IsTemporal(left) && right == dataTypes.Interval && isOneOf(e.Operator, operators.AdditiveOperators)
+		valid = valid || (left == dataTypes.Interval && dataTypes.IsTemporal(right) && e.Operator == operators.AddOperator)
+		if !valid {
+			return fmt.Errorf("Only an INTERVAL can be added to or subtracted from dates and times, got %s: %w", e.Operator, pkg.InvalidDataType)
+		}
+	}
+
+	for _, operand := range []*Expression{e.Left, e.Right} {
+		if expressionDataType(operand) == dataTypes.String {
+			return fmt.Errorf("Strings cannot be used with %s: %w", e.Operator, pkg.InvalidDataType)
+		}
+
+		if expressionDataType(operand) == dataTypes.Bool {
+			return fmt.Errorf("Bools cannot be used with %s: %w", e.Operator, pkg.InvalidDataType)
+		}
+
+		if err := validateExpressionTypes(operand); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expressionColumns returns all columns of the expression
+func expressionColumns(e *Expression) []*Expression {
+	if e == nil {
+		return nil
+	}
+
+	if e.Column != "" {
+		return []*Expression{e}
+	}
+
+	columns := make([]*Expression, 0)
+	for _, a := range literalOperands(e) {
+		columns = append(columns, expressionColumns(a)...)
+	}
+
+	for _, w := range e.When {
+		columns = append(columns, conditionColumns(w.Condition)...)
+	}
+
+	return append(columns, append(expressionColumns(e.Left), expressionColumns(e.Right)...)...)
+}
+
+// expressionString is the expression as it was written, used as the name of the expression
+func expressionString(tokens []string) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		isCall := t == "(" && i > 0 && (isScalarFunction(tokens, i-1) || windows.IsWindow(strings.ToLower(tokens[i-1])))
+		if i > 0 && t != ")" && t != "," && tokens[i-1] != "(" && !isCall {
+			b.WriteString(" ")
+		}
+
+		b.WriteString(t)
+	}
+
+	return b.String()
+}
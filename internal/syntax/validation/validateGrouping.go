@@ -0,0 +1,197 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/pkg"
+)
+
+// validateGrouping checks that a query with GROUP BY, HAVING or aggregate functions only
+// selects, compares and orders by grouped columns or aggregate functions. Without GROUP BY,
+// aggregate functions make a single group of all rows.
+func validateGrouping(columns []SelectableColumn, groupBy []GroupByColumn, having *ConditionNode, orderBy *OrderBy) error {
+	hasAggregates := false
+	for _, c := range columns {
+		if c.Function != "" {
+			hasAggregates = true
+		}
+	}
+
+	if len(groupBy) == 0 && !hasAggregates && having == nil {
+		return nil
+	}
+
+	if len(groupBy) == 0 {
+		if err := validateAggregateColumns(columns); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range columns {
+		if c.Expression != nil {
+			for _, e := range expressionColumns(c.Expression) {
+				if !isGrouped(e.Column, groupBy) {
+					return fmt.Errorf("Column %s must be in GROUP BY or used in an aggregate function: %w", e.Column, pkg.InvalidGroupBy)
+				}
+			}
+
+			continue
+		}
+
+		if c.Function == "" && !isGrouped(c.Column, groupBy) {
+			return fmt.Errorf("Column %s must be in GROUP BY or used in an aggregate function: %w", c.Column, pkg.InvalidGroupBy)
+		}
+	}
+
+	if err := validateHavingColumns(having, groupBy); err != nil {
+		return err
+	}
+
+	if orderBy != nil {
+		for _, c := range orderBy.Columns {
+			if c.Expression != nil {
+				for _, e := range expressionColumns(c.Expression) {
+					if !isGrouped(e.Column, groupBy) {
+						return fmt.Errorf("ORDER BY column %s must be in GROUP BY or used in an aggregate function: %w", e.Column, pkg.InvalidOrderBy)
+					}
+				}
+
+				continue
+			}
+
+			if c.Function == "" && !isGrouped(c.Column, groupBy) {
+				return fmt.Errorf("ORDER BY column %s must be in GROUP BY or used in an aggregate function: %w", c.Column, pkg.InvalidOrderBy)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateAggregateColumns checks that aggregate functions are not mixed with plain columns
+// when there is no GROUP BY
+func validateAggregateColumns(columns []SelectableColumn) error {
+	hasAggregates := false
+	hasColumns := false
+	for _, c := range columns {
+		if c.Function != "" {
+			hasAggregates = true
+		} else {
+			hasColumns = true
+		}
+	}
+
+	if hasAggregates && hasColumns {
+		return fmt.Errorf("Aggregate functions cannot be combined with plain columns without GROUP BY: %w", pkg.InvalidAggregate)
+	}
+
+	return nil
+}
+
+func validateHavingColumns(node *ConditionNode, groupBy []GroupByColumn) error {
+	if node == nil {
+		return nil
+	}
+
+	if node.Condition != nil {
+		c := node.Condition
+		if c.Expression != nil {
+			for _, e := range expressionColumns(c.Expression) {
+				if !isGrouped(e.Column, groupBy) {
+					return fmt.Errorf("HAVING column %s must be in GROUP BY or used in an aggregate function: %w", e.Column, pkg.InvalidHaving)
+				}
+			}
+
+			return nil
+		}
+
+		if c.Function == "" && !isGrouped(c.Column, groupBy) {
+			return fmt.Errorf("HAVING column %s must be in GROUP BY or used in an aggregate function: %w", c.Column, pkg.InvalidHaving)
+		}
+
+		for _, e := range expressionColumns(c.ValueExpression) {
+			if !isGrouped(e.Column, groupBy) {
+				return fmt.Errorf("HAVING column %s must be in GROUP BY or used in an aggregate function: %w", e.Column, pkg.InvalidHaving)
+			}
+		}
+
+		return nil
+	}
+
+	if err := validateHavingColumns(node.Left, groupBy); err != nil {
+		return err
+	}
+
+	return validateHavingColumns(node.Right, groupBy)
+}
+
+func isGrouped(column string, groupBy []GroupByColumn) bool {
+	for _, g := range groupBy {
+		if g.Column == column {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectAggregates returns every aggregate function of the query only once, the ones that
+// are selected first. HAVING and ORDER BY can use aggregate functions that are not selected.
+func collectAggregates(columns []SelectableColumn, having *ConditionNode, orderBy *OrderBy) []SelectableColumn {
+	collected := make([]SelectableColumn, 0)
+	add := func(c SelectableColumn) {
+		for _, a := range collected {
+			if aggregateName(a) == aggregateName(c) {
+				return
+			}
+		}
+
+		collected = append(collected, c)
+	}
+
+	for _, c := range columns {
+		if c.Function != "" {
+			add(c)
+		}
+	}
+
+	var walk func(node *ConditionNode)
+	walk = func(node *ConditionNode) {
+		if node == nil {
+			return
+		}
+
+		if node.Condition != nil {
+			c := node.Condition
+			if c.Function != "" {
+				add(SelectableColumn{
+					Alias:    c.Alias,
+					Column:   c.Column,
+					Function: c.Function,
+					DataType: c.DataType,
+				})
+			}
+
+			return
+		}
+
+		walk(node.Left)
+		walk(node.Right)
+	}
+
+	walk(having)
+
+	if orderBy != nil {
+		for _, c := range orderBy.Columns {
+			if c.Function != "" {
+				add(SelectableColumn{
+					Alias:    c.Alias,
+					Column:   c.Column,
+					Function: c.Function,
+					DataType: c.DataType,
+				})
+			}
+		}
+	}
+
+	return collected
+}
@@ -0,0 +1,263 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/pkg"
+	"strings"
+)
+
+// validateJoins validates the joined files that follow the FROM clause, for example
+// LEFT JOIN path:b.csv AS b ON 'a.id' = 'b.id'. INNER and OUTER are optional. The ON condition
+// can use the columns of every file that is joined before it. ASOF JOIN and ASOF LEFT JOIN
+// have a condition of their own, see validateAsofCondition. It returns the joins, the aliases
+// of all files and the index of the first token after the joins.
+func validateJoins(tokens []string, startIdx int, aliases tableAliases, s scope) ([]Join, tableAliases, int, error) {
+	joins := make([]Join, 0)
+	i := startIdx
+
+	for isJoinStart(tokens[i]) {
+		joinType := strings.ToLower(tokens[i])
+		if joinType == operators.JoinKeyword {
+			joinType = operators.InnerJoin
+		} else {
+			i++
+		}
+
+		if joinType == operators.AsofJoin && strings.ToLower(tokens[i]) == operators.LeftJoin {
+			joinType = operators.AsofLeftJoin
+			i++
+		}
+
+		if (joinType == operators.LeftJoin || joinType == operators.RightJoin) && strings.ToLower(tokens[i]) == operators.OuterKeyword {
+			i++
+		}
+
+		if strings.ToLower(tokens[i]) != operators.JoinKeyword {
+			return nil, nil, i, fmt.Errorf("Expected JOIN after %s, got '%s': %w", strings.ToUpper(joinType), tokens[i], pkg.InvalidJoin)
+		}
+		i++
+
+		joined, nextIdx, err := validateSource(tokens, i, s)
+		if err != nil {
+			return nil, nil, i, err
+		}
+
+		alias := joined.alias
+		if aliases.has(alias) {
+			return nil, nil, i, fmt.Errorf("Alias %s is used more than once: %w", alias, pkg.InvalidJoin)
+		}
+		aliases = append(aliases, alias)
+		i = nextIdx
+
+		if strings.ToLower(tokens[i]) != operators.OnKeyword {
+			return nil, nil, i, fmt.Errorf("Expected ON after the alias %s, got '%s': %w", alias, tokens[i], pkg.InvalidJoin)
+		}
+		i++
+
+		on, nextIdx, err := parseConditionTree(&conditionParser{
+			aliases: aliases,
+			tokens:  tokens,
+			idx:     i,
+			join:    true,
+		})
+		if err != nil {
+			return nil, nil, i, err
+		}
+
+		if on == nil {
+			return nil, nil, i, fmt.Errorf("JOIN of %s does not have a condition: %w", alias, pkg.InvalidJoin)
+		}
+		i = nextIdx
+
+		if joinType == operators.AsofJoin || joinType == operators.AsofLeftJoin {
+			if err := validateAsofCondition(on, alias); err != nil {
+				return nil, nil, i, err
+			}
+		}
+
+		joins = append(joins, Join{
+			Type:     joinType,
+			FilePath: joined.path,
+			Queries:  joined.queries,
+			Alias:    alias,
+			On:       on,
+		})
+	}
+
+	return joins, aliases, i, nil
+}
+
+// validateAsofCondition checks that the condition of an ASOF JOIN compares columns of the joined
+// file with columns of the files before it, joined with AND. Any number of columns can be compared
+// with = and exactly one with >=, >, <= or <, which is the column the nearest row is found by.
+func validateAsofCondition(on *ConditionNode, alias string) error {
+	inequalities := 0
+
+	var validate func(node *ConditionNode) error
+	validate = func(node *ConditionNode) error {
+		if node.Condition == nil {
+			if node.LogicalOperator != operators.AndOperator {
+				return fmt.Errorf("ASOF JOIN conditions can only be joined with AND: %w", pkg.InvalidJoin)
+			}
+
+			if err := validate(node.Left); err != nil {
+				return err
+			}
+
+			return validate(node.Right)
+		}
+
+		c := node.Condition
+		value := c.ValueExpression
+		if c.Expression != nil || c.Function != "" || value == nil || value.Column == "" {
+			return fmt.Errorf("ASOF JOIN can only compare columns: %w", pkg.InvalidJoin)
+		}
+
+		if (c.Alias == alias) == (value.Alias == alias) {
+			return fmt.Errorf("ASOF JOIN must compare a column of %s with a column of another file: %w", alias, pkg.InvalidJoin)
+		}
+
+		if isOneOf(c.ComparisonOperator, operators.AsofOperators) {
+			inequalities++
+		} else if c.ComparisonOperator != operators.EqualOperator {
+			return fmt.Errorf("ASOF JOIN cannot compare columns with %s: %w", strings.ToUpper(c.ComparisonOperator), pkg.InvalidJoin)
+		}
+
+		return nil
+	}
+
+	if err := validate(on); err != nil {
+		return err
+	}
+
+	if inequalities != 1 {
+		return fmt.Errorf("ASOF JOIN must compare exactly one column with >=, >, <= or <, got %d: %w", inequalities, pkg.InvalidJoin)
+	}
+
+	return nil
+}
+
+func isJoinStart(token string) bool {
+	t := strings.ToLower(token)
+
+	return t == operators.JoinKeyword || isOneOf(t, operators.Joins)
+}
+
+func isJoinConditionEnd(token string) bool {
+	return strings.ToLower(token) == "where" || isJoinStart(token)
+}
+
+// qualifyColumns names every column of a query with joins by its alias, for example 'b.name'
+// becomes b.name, so that columns with the same name in different files are told apart.
+func qualifyColumns(m *Metadata) {
+	qualifySelectableColumns(m.SelectedColumns)
+	qualifySelectableColumns(m.DistinctOn)
+	qualifyConditionNode(m.Condition)
+	qualifyConditionNode(m.Having)
+
+	for _, j := range m.Joins {
+		qualifyConditionNode(j.On)
+	}
+
+	for i := range m.GroupBy {
+		m.GroupBy[i].Column = qualifiedName(m.GroupBy[i].Alias, m.GroupBy[i].Column)
+	}
+
+	qualifyOrderBy(m.OrderBy)
+}
+
+func qualifyOrderBy(orderBy *OrderBy) {
+	if orderBy == nil {
+		return
+	}
+
+	for i, c := range orderBy.Columns {
+		if c.Expression != nil {
+			qualifyExpression(c.Expression)
+
+			continue
+		}
+
+		orderBy.Columns[i].Column = qualifiedName(c.Alias, c.Column)
+	}
+}
+
+func qualifySelectableColumns(columns []SelectableColumn) {
+	for i, c := range columns {
+		if c.Window != nil {
+			for _, a := range c.Window.Arguments {
+				qualifyExpression(a)
+			}
+
+			qualifyOrderBy(c.Window.PartitionBy)
+			qualifyOrderBy(c.Window.OrderBy)
+
+			continue
+		}
+
+		if c.Expression != nil {
+			qualifyExpression(c.Expression)
+
+			continue
+		}
+
+		columns[i].Column = qualifiedName(c.Alias, c.Column)
+	}
+}
+
+func qualifyConditionNode(node *ConditionNode) {
+	if node == nil {
+		return
+	}
+
+	if c := node.Condition; c != nil {
+		if c.Expression != nil {
+			qualifyExpression(c.Expression)
+		} else {
+			c.Column = qualifiedName(c.Alias, c.Column)
+		}
+
+		qualifyExpression(c.ValueExpression)
+
+		if c.Subquery != nil {
+			for _, o := range c.Subquery.Correlated {
+				o.Column = qualifiedName(o.Alias, o.Column)
+			}
+		}
+
+		return
+	}
+
+	qualifyConditionNode(node.Left)
+	qualifyConditionNode(node.Right)
+}
+
+func qualifyExpression(e *Expression) {
+	if e == nil {
+		return
+	}
+
+	if e.Column != "" {
+		e.Column = qualifiedName(e.Alias, e.Column)
+	}
+
+	qualifyExpression(e.Left)
+	qualifyExpression(e.Right)
+
+	for _, a := range literalOperands(e) {
+		qualifyExpression(a)
+	}
+
+	for _, w := range e.When {
+		qualifyConditionNode(w.Condition)
+	}
+}
+
+func qualifiedName(alias, column string) string {
+	if column == "*" || column == "" {
+		return column
+	}
+
+	return fmt.Sprintf("%s.%s", alias, column)
+}
@@ -5,14 +5,29 @@ import (
 	"github.com/MarioLegenda/cig/pkg"
 )
 
-func validateSelectableColumnAlias(alias string, selectableColumns []SelectableColumn) error {
+func validateSelectableColumnAlias(aliases tableAliases, selectableColumns []SelectableColumn) error {
 	if len(selectableColumns) == 1 && selectableColumns[0].Column == "*" {
 		return nil
 	}
 
 	for _, c := range selectableColumns {
-		if c.Alias != alias {
-			return fmt.Errorf("Expected alias %s, got %s for column %s: %w", alias, c.Alias, c.Column, pkg.InvalidColumnAlias)
+		// COUNT(*) does not have an alias, the columns of window functions are checked by resolveWindows
+		if c.Column == "*" || c.Window != nil {
+			continue
+		}
+
+		if c.Expression != nil {
+			for _, e := range expressionColumns(c.Expression) {
+				if !aliases.has(e.Alias) {
+					return fmt.Errorf("Expected alias %s, got %s for column %s: %w", aliases, e.Alias, e.Column, pkg.InvalidColumnAlias)
+				}
+			}
+
+			continue
+		}
+
+		if !aliases.has(c.Alias) {
+			return fmt.Errorf("Expected alias %s, got %s for column %s: %w", aliases, c.Alias, c.Column, pkg.InvalidColumnAlias)
 		}
 	}
 
@@ -2,87 +2,229 @@ package validation
 
 import (
 	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/aggregates"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
 	"github.com/MarioLegenda/cig/pkg"
 	"sort"
 	"strings"
 )
 
-func validSelectableColumns(tokens []string) (int, []SelectableColumn, error) {
-	if tokens[1] == "*" {
+func validSelectableColumns(tokens []string, startIdx int) (int, []SelectableColumn, error) {
+	if tokens[startIdx] == "*" {
 		return 1, []SelectableColumn{
 			{
 				Alias:    "",
 				Column:   "*",
-				Original: tokens[1],
+				Original: tokens[startIdx],
 			},
 		}, nil
 	}
 
 	selectableColumns := make([]SelectableColumn, 0)
-	columnNamesToValidate := make([]string, 0)
 
-	nextToSkip := 0
-	columnMode := true
-	commaMode := false
-	for i := 1; i < len(tokens); i++ {
+	i := startIdx
+	for {
 		token := tokens[i]
-		nextToSkip++
 
 		if token == "" {
 			return -1, nil, fmt.Errorf("Selectable column is invalid. Expected column, got something else: %w", pkg.InvalidSelectableColumns)
 		}
 
-		if columnMode {
-			if !isEnclosedInQuote(token) {
-				return -1, nil, fmt.Errorf("Selectable columns should be enclosed inside single quotes: %w", pkg.InvalidSelectableColumns)
-			}
+		var column SelectableColumn
+		var err error
+		if isWindowFunction(tokens, i) {
+			column, i, err = validateWindowColumn(tokens, i)
+		} else if isAggregateFunction(tokens, i) {
+			column, i, err = validateAggregateColumn(tokens, i)
+		} else {
+			column, i, err = validateSelectableExpression(tokens, i)
+		}
 
-			// check proper column with alias
-			columnOnly := token[1 : len(token)-1]
-			splitted := strings.Split(columnOnly, ".")
+		if err != nil {
+			return -1, nil, err
+		}
 
-			if len(splitted) != 2 {
-				return -1, nil, fmt.Errorf("Selectable columns have to be in form {alias}.{columnName}: %w", pkg.InvalidSelectableColumns)
+		if strings.ToLower(tokens[i]) == operators.AsKeyword {
+			name, err := validateColumnName(tokens[i+1])
+			if err != nil {
+				return -1, nil, err
 			}
 
-			columnNamesToValidate = append(columnNamesToValidate, splitted[1])
-
-			selectableColumns = append(selectableColumns, SelectableColumn{
-				Alias:    splitted[0],
-				Column:   splitted[1],
-				Original: columnOnly,
-			})
-
-			nextPossibleColumn := i + 2
-			// the next column is not a "column" but something else, stop validating selectable columns
-			if tokens[i+1] == "," && isEnclosedInQuote(tokens[nextPossibleColumn]) {
-				commaMode = true
-				columnMode = false
-				continue
-			} else {
-				break
-			}
+			column.As = name
+			i += 2
 		}
 
-		if commaMode {
-			if token != "," {
-				return -1, nil, fmt.Errorf("Invalid column separator. Expected comma (,), got something else: %w", pkg.InvalidSelectableColumns)
-			}
+		selectableColumns = append(selectableColumns, column)
 
-			columnMode = true
-			commaMode = false
+		// the next column is not a "column" but something else, stop validating selectable columns
+		if tokens[i] != "," || !isExpressionStart(tokens, i+1) {
+			break
 		}
+		i++
+	}
+
+	names := make([]string, len(selectableColumns))
+	for i, c := range selectableColumns {
+		names[i] = selectableColumnKey(c)
 	}
 
-	sort.Strings(columnNamesToValidate)
-	for i, s := range columnNamesToValidate {
-		if i < len(columnNamesToValidate)-1 {
-			next := columnNamesToValidate[i+1]
+	sort.Strings(names)
+	for i, s := range names {
+		if i < len(names)-1 {
+			next := names[i+1]
 			if next == s {
 				return -1, nil, fmt.Errorf("Duplicate column found: %w", pkg.InvalidDuplicatedColumn)
 			}
 		}
 	}
 
-	return nextToSkip, selectableColumns, nil
+	return i - startIdx, selectableColumns, nil
+}
+
+// validateSelectableColumn validates a column in form 'alias.column' and returns the index
+// of the token after it.
+func validateSelectableColumn(tokens []string, i int) (SelectableColumn, int, error) {
+	token := tokens[i]
+	if !isEnclosedInQuote(token) {
+		return SelectableColumn{}, i, fmt.Errorf("Selectable columns should be enclosed inside single quotes: %w", pkg.InvalidSelectableColumns)
+	}
+
+	// check proper column with alias
+	columnOnly := token[1 : len(token)-1]
+	splitted := strings.Split(columnOnly, ".")
+
+	if len(splitted) != 2 {
+		return SelectableColumn{}, i, fmt.Errorf("Selectable columns have to be in form {alias}.{columnName}: %w", pkg.InvalidSelectableColumns)
+	}
+
+	return SelectableColumn{
+		Alias:    splitted[0],
+		Column:   splitted[1],
+		Original: columnOnly,
+	}, i + 1, nil
+}
+
+// validateSelectableExpression validates a column or an arithmetic expression over columns
+// and returns the index of the token after it. A single column is a plain column.
+func validateSelectableExpression(tokens []string, i int) (SelectableColumn, int, error) {
+	e, nextIdx, err := validateExpression(nil, tokens, i)
+	if err != nil {
+		return SelectableColumn{}, i, err
+	}
+
+	if e.Column != "" {
+		return SelectableColumn{
+			Alias:    e.Alias,
+			Column:   e.Column,
+			Original: fmt.Sprintf("%s.%s", e.Alias, e.Column),
+			DataType: e.DataType,
+		}, nextIdx, nil
+	}
+
+	return SelectableColumn{
+		Original:   expressionString(tokens[i:nextIdx]),
+		Expression: e,
+	}, nextIdx, nil
+}
+
+// validateColumnName validates the name given to a column with AS. It must start
+// with a letter or an underscore and contain only letters, digits and underscores.
+func validateColumnName(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("Expected a name after AS: %w", pkg.InvalidSelectableColumns)
+	}
+
+	for i, r := range name {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_'
+		isDigit := r >= '0' && r <= '9'
+
+		if !isLetter && (i == 0 || !isDigit) {
+			return "", fmt.Errorf("Invalid column name %s. Names can contain only letters, digits and underscores: %w", name, pkg.InvalidSelectableColumns)
+		}
+	}
+
+	return name, nil
+}
+
+// validateAggregateColumn validates aggregate functions like COUNT(*) or SUM('e.Value'::float)
+// and returns the index of the token after the closing parenthesis.
+func validateAggregateColumn(tokens []string, i int) (SelectableColumn, int, error) {
+	function := strings.ToLower(tokens[i])
+	argument := tokens[i+2]
+
+	if tokens[i+3] != ")" {
+		return SelectableColumn{}, i, fmt.Errorf("Expected closing parenthesis after the argument of %s: %w", function, pkg.InvalidAggregate)
+	}
+
+	original := fmt.Sprintf("%s(%s)", tokens[i], argument)
+
+	if argument == "*" {
+		if function != aggregates.Count {
+			return SelectableColumn{}, i, fmt.Errorf("Only COUNT accepts *, got %s: %w", original, pkg.InvalidAggregate)
+		}
+
+		return SelectableColumn{
+			Column:   "*",
+			Original: original,
+			Function: function,
+		}, i + 4, nil
+	}
+
+	extractedColumn, dataType := getColumnAndDataType(argument)
+	column, _, err := validateSelectableColumn([]string{extractedColumn}, 0)
+	if err != nil {
+		return SelectableColumn{}, i, err
+	}
+
+	if dataType != "" {
+		if err := validateDataType(dataType); err != nil {
+			return SelectableColumn{}, i, err
+		}
+	}
+
+	if (function == aggregates.Sum || function == aggregates.Avg) && (dataType == dataTypes.String || dataType == dataTypes.Bool || dataTypes.IsTemporal(dataType)) {
+		return SelectableColumn{}, i, fmt.Errorf("%s can only be used with numeric columns: %w", original, pkg.InvalidAggregate)
+	}
+
+	column.Original = original
+	column.Function = function
+	column.DataType = dataType
+
+	return column, i + 4, nil
+}
+
+func isAggregateFunction(tokens []string, i int) bool {
+	return isOneOf(strings.ToLower(tokens[i]), aggregates.Functions) && tokens[i+1] == "("
+}
+
+// selectableColumnName is the name of the column in the result
+func selectableColumnName(c SelectableColumn) string {
+	if c.As != "" {
+		return c.As
+	}
+
+	if c.Function != "" {
+		return aggregateName(c)
+	}
+
+	if c.Expression != nil {
+		return c.Original
+	}
+
+	return c.Column
+}
+
+// selectableColumnKey tells duplicated columns apart, columns of different joined
+// files can have the same name
+func selectableColumnKey(c SelectableColumn) string {
+	if c.As != "" || c.Expression != nil || c.Window != nil {
+		return selectableColumnName(c)
+	}
+
+	return fmt.Sprintf("%s.%s", c.Alias, selectableColumnName(c))
+}
+
+func aggregateName(c SelectableColumn) string {
+	return fmt.Sprintf("%s(%s)", c.Function, c.Column)
 }
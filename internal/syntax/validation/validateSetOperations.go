@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/pkg"
+	"strings"
+)
+
+// Query is one of the queries of a statement that combines queries with UNION, UNION ALL,
+// INTERSECT or EXCEPT. Operator combines it with the queries before it and is empty for the
+// first query.
+type Query struct {
+	Operator string
+	Metadata Metadata
+}
+
+// ValidateAndCreateQueries validates a statement, the queries of WITH and then the queries that
+// set operators combine, see validateQueries.
+func ValidateAndCreateQueries(tokens []string) ([]Query, error) {
+	with, start, err := validateWith(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	if start != 0 && start == len(tokens) {
+		return nil, fmt.Errorf("Expected a query after WITH: %w", pkg.InvalidDerivedTable)
+	}
+
+	return validateQueries(tokens[start:], scope{with: with})
+}
+
+// validateQueries splits tokens into the queries that set operators combine and validates every
+// query on its own. Every query has its own WHERE, ORDER BY and LIMIT. Queries must return the
+// same number of columns, which is checked here if none of them selects *.
+func validateQueries(tokens []string, s scope) ([]Query, error) {
+	queries := make([]Query, 0)
+	operator := ""
+	start := 0
+	depth := 0
+
+	add := func(end int) error {
+		if start == end {
+			return fmt.Errorf("Expected a query around %s: %w", strings.ToUpper(operator), pkg.InvalidSetOperation)
+		}
+
+		// the capacity is limited so that the tokens of the next query are not overwritten
+		// when the tokens of this one are extended
+		metadata, _, err := validateQuery(tokens[start:end:end], s)
+		if err != nil {
+			return err
+		}
+
+		queries = append(queries, Query{
+			Operator: operator,
+			Metadata: metadata,
+		})
+
+		return nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] == "(" {
+			depth++
+		} else if tokens[i] == ")" {
+			depth--
+		}
+
+		t := strings.ToLower(tokens[i])
+		if depth != 0 || !isOneOf(t, operators.SetOperators) {
+			continue
+		}
+
+		if err := add(i); err != nil {
+			return nil, err
+		}
+
+		operator = t
+		if t == operators.UnionOperator && i+1 < len(tokens) && strings.ToLower(tokens[i+1]) == operators.AllKeyword {
+			operator = operators.UnionAllOperator
+			i++
+		}
+		start = i + 1
+	}
+
+	if err := add(len(tokens)); err != nil {
+		return nil, err
+	}
+
+	if err := validateSetOperationColumns(queries); err != nil {
+		return nil, err
+	}
+
+	return queries, nil
+}
+
+func validateSetOperationColumns(queries []Query) error {
+	for _, q := range queries {
+		for _, c := range q.Metadata.SelectedColumns {
+			if c.Column == "*" && c.Function == "" {
+				return nil
+			}
+		}
+	}
+
+	for _, q := range queries[1:] {
+		if len(q.Metadata.SelectedColumns) != len(queries[0].Metadata.SelectedColumns) {
+			return fmt.Errorf("Queries combined with %s must select the same number of columns, got %d and %d: %w", strings.ToUpper(q.Operator), len(queries[0].Metadata.SelectedColumns), len(q.Metadata.SelectedColumns), pkg.InvalidSetOperation)
+		}
+	}
+
+	return nil
+}
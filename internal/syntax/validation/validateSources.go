@@ -0,0 +1,145 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/pkg"
+	"strings"
+)
+
+// source is where the rows of FROM or of a JOIN come from, either a file or the queries
+// of a derived table
+type source struct {
+	path    string
+	queries []Query
+	alias   string
+}
+
+// validateSource validates the file of FROM or of a JOIN and its alias. Instead of path:file.csv AS alias,
+// the rows can come from a derived table, (SELECT ...) AS alias, or from a query of WITH by its name,
+// with an optional alias. It returns the index of the first token after the alias.
+func validateSource(tokens []string, i int, s scope) (source, int, error) {
+	var src source
+
+	if queries, ok := s.with[tokens[i]]; ok {
+		src.queries = queries
+		src.alias = tokens[i]
+		i++
+
+		if strings.ToLower(tokens[i]) != operators.AsKeyword {
+			return src, i, nil
+		}
+	} else if tokens[i] == "(" {
+		if strings.ToLower(tokens[i+1]) != "select" {
+			return source{}, i, fmt.Errorf("Expected a query after the opening parenthesis, got %s: %w", tokens[i+1], pkg.InvalidDerivedTable)
+		}
+
+		queryTokens, nextIdx, err := enclosedQuery(tokens, i)
+		if err != nil {
+			return source{}, i, err
+		}
+
+		// a derived table cannot use the files of the query it is in
+		queries, err := validateQueries(queryTokens, scope{with: s.with})
+		if err != nil {
+			return source{}, i, err
+		}
+
+		src.queries = queries
+		i = nextIdx
+	} else {
+		path, err := validatePath(tokens[i])
+		if err != nil {
+			return source{}, i, err
+		}
+
+		src.path = path
+		i++
+	}
+
+	if err := validateAsToken(tokens[i]); err != nil {
+		return source{}, i, err
+	}
+	i++
+
+	alias, err := validateAlias(tokens[i])
+	if err != nil {
+		return source{}, i, err
+	}
+	src.alias = alias
+
+	return src, i + 1, nil
+}
+
+// enclosedQuery returns a copy of the tokens of a query enclosed in parentheses that starts at
+// the opening parenthesis and the index of the first token after the closing one. The tokens
+// are copied because they are extended while they are validated.
+func enclosedQuery(tokens []string, i int) ([]string, int, error) {
+	end := i
+	for depth := 0; ; end++ {
+		if end == len(tokens) || tokens[end] == "" {
+			return nil, i, fmt.Errorf("Expected closing parenthesis after the query: %w", pkg.InvalidParenthesis)
+		}
+
+		if tokens[end] == "(" {
+			depth++
+		} else if tokens[end] == ")" {
+			depth--
+		}
+
+		if depth == 0 {
+			break
+		}
+	}
+
+	queryTokens := make([]string, end-i-1)
+	copy(queryTokens, tokens[i+1:end])
+
+	return queryTokens, end + 1, nil
+}
+
+// validateWith validates the queries of WITH name AS (SELECT ...), name AS (SELECT ...) at the start
+// of a statement. Every query can use the queries before it by their names, the statement can use all
+// of them. It returns the index of the first token after them.
+func validateWith(tokens []string) (map[string][]Query, int, error) {
+	with := make(map[string][]Query)
+	if len(tokens) == 0 || strings.ToLower(tokens[0]) != operators.WithKeyword {
+		return with, 0, nil
+	}
+
+	padded := append(tokens[:len(tokens):len(tokens)], make([]string, 4)...)
+
+	i := 1
+	for {
+		name := padded[i]
+		if _, err := validateColumnName(name); err != nil {
+			return nil, i, fmt.Errorf("Invalid name %s of a query of WITH. Names can contain only letters, digits and underscores: %w", name, pkg.InvalidDerivedTable)
+		}
+
+		if _, ok := with[name]; ok {
+			return nil, i, fmt.Errorf("Query %s of WITH is defined more than once: %w", name, pkg.InvalidDerivedTable)
+		}
+
+		if strings.ToLower(padded[i+1]) != operators.AsKeyword || padded[i+2] != "(" || strings.ToLower(padded[i+3]) != "select" {
+			return nil, i, fmt.Errorf("Expected %s AS (SELECT ...): %w", name, pkg.InvalidDerivedTable)
+		}
+
+		queryTokens, nextIdx, err := enclosedQuery(padded, i+2)
+		if err != nil {
+			return nil, i, err
+		}
+
+		queries, err := validateQueries(queryTokens, scope{with: with})
+		if err != nil {
+			return nil, i, err
+		}
+
+		with[name] = queries
+		i = nextIdx
+
+		if padded[i] != "," {
+			return with, i, nil
+		}
+		i++
+	}
+}
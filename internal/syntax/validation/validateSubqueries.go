@@ -0,0 +1,233 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/aggregates"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/pkg"
+	"strings"
+)
+
+// correlation is a comparison of a column of a subquery with a column of the outer query
+type correlation struct {
+	inner SelectableColumn
+	outer *Condition
+}
+
+// parseSubquery parses the query of IN (SELECT ...) and EXISTS (SELECT ...), from the opening
+// parenthesis to the matching closing one. Its WHERE clause can compare its columns with columns
+// of the query it is in, but not with columns of the queries around that one.
+func (p *conditionParser) parseSubquery() (*Subquery, error) {
+	if p.aggregates || p.join {
+		return nil, fmt.Errorf("Subqueries can only be used in WHERE: %w", pkg.InvalidSubquery)
+	}
+
+	if p.caseWhen {
+		return nil, fmt.Errorf("Subqueries cannot be used in conditions of CASE: %w", pkg.InvalidSubquery)
+	}
+
+	if p.current() != "(" || strings.ToLower(p.tokens[p.idx+1]) != "select" {
+		return nil, fmt.Errorf("Expected a query enclosed in parentheses, got %s: %w", p.current(), pkg.InvalidSubquery)
+	}
+
+	tokens, nextIdx, err := enclosedQuery(p.tokens, p.idx)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, correlated, err := validateQuery(tokens, scope{
+		outer: p.aliases[:len(p.aliases)-len(p.scope.outer)],
+		with:  p.scope.with,
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.idx = nextIdx
+
+	return &Subquery{
+		Metadata:   metadata,
+		Correlated: correlated,
+	}, nil
+}
+
+// parseInSubquery parses IN (SELECT ...). The subquery must select a single column, which is
+// compared with the column of the condition.
+func (p *conditionParser) parseInSubquery(condition *Condition) error {
+	s, err := p.parseSubquery()
+	if err != nil {
+		return err
+	}
+
+	selected := s.Metadata.SelectedColumns
+	if len(selected)-len(s.Correlated) != 1 || (selected[0].Column == "*" && selected[0].Function == "") {
+		return fmt.Errorf("%s (SELECT ...) must select exactly one column: %w", strings.ToUpper(condition.ComparisonOperator), pkg.InvalidSubquery)
+	}
+
+	dataType, err := comparisonDataType(condition.DataType, selectedDataType(selected[0]))
+	if err != nil {
+		return err
+	}
+
+	condition.DataType = dataType
+	condition.Subquery = s
+
+	return nil
+}
+
+// parseExists parses EXISTS (SELECT ...). What the subquery selects does not matter, a correlated
+// one selects only the columns that are compared with the outer query.
+func (p *conditionParser) parseExists() (*Condition, error) {
+	p.idx++
+
+	s, err := p.parseSubquery()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.Correlated) != 0 {
+		selected := s.Metadata.SelectedColumns
+		s.Metadata.SelectedColumns = selected[len(selected)-len(s.Correlated):]
+	}
+
+	return &Condition{
+		ComparisonOperator: operators.ExistsOperator,
+		Subquery:           s,
+	}, nil
+}
+
+func selectedDataType(c SelectableColumn) string {
+	if c.Window != nil {
+		return c.Window.DataType
+	}
+
+	if c.Function != "" {
+		return aggregates.DataType(c.Function, c.DataType)
+	}
+
+	if c.Expression != nil {
+		return expressionDataType(c.Expression)
+	}
+
+	return c.DataType
+}
+
+// correlate takes the comparisons with columns of the outer query out of the WHERE clause of a
+// subquery. They must compare a column of the subquery with a column of the outer query with =
+// and be joined with the rest of the clause by AND.
+func correlate(node *ConditionNode, outer tableAliases) (*ConditionNode, []correlation, error) {
+	if node == nil || len(outer) == 0 || !referencesAliases(node, outer) {
+		return node, nil, nil
+	}
+
+	if node.LogicalOperator == operators.AndOperator {
+		left, leftCorrelated, err := correlate(node.Left, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		right, rightCorrelated, err := correlate(node.Right, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		correlated := append(leftCorrelated, rightCorrelated...)
+		if left == nil {
+			return right, correlated, nil
+		}
+
+		if right == nil {
+			return left, correlated, nil
+		}
+
+		node.Left = left
+		node.Right = right
+
+		return node, correlated, nil
+	}
+
+	c := node.Condition
+	if c == nil {
+		return nil, nil, fmt.Errorf("Columns of the outer query can only be compared in conditions joined with AND: %w", pkg.InvalidSubquery)
+	}
+
+	v := c.ValueExpression
+	if c.ComparisonOperator != operators.EqualOperator || c.Expression != nil || c.Function != "" || v == nil || v.Column == "" {
+		return nil, nil, fmt.Errorf("Columns of the outer query can only be compared with columns of the subquery with =: %w", pkg.InvalidSubquery)
+	}
+
+	inner := SelectableColumn{Alias: c.Alias, Column: c.Column}
+	outerColumn := &Condition{Alias: v.Alias, Column: v.Column}
+	if outer.has(c.Alias) {
+		inner = SelectableColumn{Alias: v.Alias, Column: v.Column}
+		outerColumn = &Condition{Alias: c.Alias, Column: c.Column}
+	}
+
+	if outer.has(inner.Alias) {
+		return nil, nil, fmt.Errorf("Columns of the outer query can only be compared with columns of the subquery with =: %w", pkg.InvalidSubquery)
+	}
+
+	// the values are compared as they are in the file, by the data type of the comparison
+	inner.Original = fmt.Sprintf("%s.%s", inner.Alias, inner.Column)
+	inner.As = inner.Original
+	outerColumn.DataType = c.DataType
+	outerColumn.Collation = c.Collation
+	outerColumn.ComparisonOperator = operators.EqualOperator
+
+	return nil, []correlation{{inner: inner, outer: outerColumn}}, nil
+}
+
+// referencesAliases tells if a condition compares columns of any of the aliases
+func referencesAliases(node *ConditionNode, aliases tableAliases) bool {
+	if node == nil {
+		return false
+	}
+
+	c := node.Condition
+	if c == nil {
+		return referencesAliases(node.Left, aliases) || referencesAliases(node.Right, aliases)
+	}
+
+	if c.Column != "" && c.Expression == nil && aliases.has(c.Alias) {
+		return true
+	}
+
+	for _, e := range append(expressionColumns(c.Expression), expressionColumns(c.ValueExpression)...) {
+		if aliases.has(e.Alias) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectCorrelated selects the columns of a correlated subquery that were compared with the
+// outer query and returns the columns of the outer query. The subquery runs only once for all
+// rows of the outer query, so it cannot limit or aggregate its rows.
+func selectCorrelated(m *Metadata, correlated []correlation) ([]*Condition, error) {
+	if len(correlated) == 0 {
+		return nil, nil
+	}
+
+	aggregated := len(m.GroupBy) != 0 || m.Having != nil || len(m.DistinctOn) != 0
+	for _, c := range m.SelectedColumns {
+		aggregated = aggregated || c.Function != ""
+	}
+
+	if m.OrderBy != nil {
+		for _, c := range m.OrderBy.Columns {
+			aggregated = aggregated || c.Function != ""
+		}
+	}
+
+	if aggregated || m.Limit != -1 || m.Offset != -1 {
+		return nil, fmt.Errorf("A subquery that compares columns of the outer query cannot have GROUP BY, HAVING, DISTINCT ON, LIMIT, OFFSET or aggregate functions: %w", pkg.InvalidSubquery)
+	}
+
+	outer := make([]*Condition, len(correlated))
+	for i, c := range correlated {
+		m.SelectedColumns = append(m.SelectedColumns, c.inner)
+		outer[i] = c.outer
+	}
+
+	return outer, nil
+}
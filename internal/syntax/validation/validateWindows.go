@@ -0,0 +1,243 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+	"github.com/MarioLegenda/cig/internal/syntax/operators"
+	"github.com/MarioLegenda/cig/internal/syntax/windows"
+	"github.com/MarioLegenda/cig/pkg"
+	"strconv"
+	"strings"
+)
+
+// isWindowFunction checks if a window function like ROW_NUMBER() OVER (...) starts at i. SUM
+// is a window function only when OVER follows it, otherwise it is an aggregate function.
+func isWindowFunction(tokens []string, i int) bool {
+	if !windows.IsWindow(strings.ToLower(tokens[i])) || tokens[i+1] != "(" {
+		return false
+	}
+
+	_, nextIdx, err := enclosedQuery(tokens, i+1)
+
+	return err == nil && strings.ToLower(tokens[nextIdx]) == operators.OverKeyword
+}
+
+// validateWindowColumn validates a window function of the SELECT list and returns the index of
+// the token after the closing parenthesis of OVER. The arguments and OVER are parsed by
+// resolveWindows once the aliases of the query are known.
+func validateWindowColumn(tokens []string, i int) (SelectableColumn, int, error) {
+	function := strings.ToLower(tokens[i])
+
+	arguments, overIdx, err := enclosedQuery(tokens, i+1)
+	if err != nil {
+		return SelectableColumn{}, i, err
+	}
+
+	if tokens[overIdx+1] != "(" {
+		return SelectableColumn{}, i, fmt.Errorf("Expected OVER (...) after %s: %w", strings.ToUpper(function), pkg.InvalidWindow)
+	}
+
+	over, nextIdx, err := enclosedQuery(tokens, overIdx+1)
+	if err != nil {
+		return SelectableColumn{}, i, err
+	}
+
+	original := expressionString(tokens[i:nextIdx])
+
+	return SelectableColumn{
+		Column:   original,
+		Original: original,
+		Window: &Window{
+			Function:  function,
+			arguments: arguments,
+			over:      over,
+		},
+	}, nextIdx, nil
+}
+
+// resolveWindows parses the arguments and OVER of window functions of the SELECT list once
+// the aliases of the query are known
+func resolveWindows(aliases tableAliases, columns []SelectableColumn) error {
+	for _, c := range columns {
+		if c.Window == nil || c.Window.over == nil {
+			continue
+		}
+
+		if err := resolveWindowArguments(aliases, c.Window); err != nil {
+			return err
+		}
+
+		if err := resolveOver(aliases, c.Window); err != nil {
+			return err
+		}
+
+		c.Window.arguments = nil
+		c.Window.over = nil
+	}
+
+	return nil
+}
+
+// resolveWindowArguments parses the arguments of a window function. Like arguments of scalar
+// functions, quoted values that are not columns are strings. The offset of LAG and LEAD must be
+// a number of rows and their default value must be comparable with the value.
+func resolveWindowArguments(aliases tableAliases, w *Window) error {
+	name := strings.ToUpper(w.Function)
+	tokens := append(w.arguments, make([]string, 10)...)
+
+	arguments := make([]*Expression, 0)
+	for i := 0; tokens[i] != ""; {
+		if len(arguments) != 0 {
+			if tokens[i] != "," {
+				return fmt.Errorf("Expected a comma between arguments of %s, got %s: %w", name, tokens[i], pkg.InvalidWindow)
+			}
+			i++
+		}
+
+		p := &expressionParser{
+			tokens:        tokens,
+			idx:           i,
+			aliases:       aliases,
+			functionDepth: 1,
+		}
+
+		argument, err := p.parseAdditive()
+		if err != nil {
+			return err
+		}
+
+		if err := validateExpressionTypes(argument); err != nil {
+			return err
+		}
+
+		for _, c := range expressionColumns(argument) {
+			if !aliases.has(c.Alias) {
+				return fmt.Errorf("Expected alias %s, got %s for column %s: %w", aliases, c.Alias, c.Column, pkg.InvalidColumnAlias)
+			}
+		}
+
+		arguments = append(arguments, argument)
+		i = p.idx
+	}
+
+	f := windows.Functions[w.Function]
+	if len(arguments) < f.MinArguments || len(arguments) > f.MaxArguments {
+		return fmt.Errorf("Invalid number of arguments of %s, got %d: %w", name, len(arguments), pkg.InvalidWindow)
+	}
+
+	dataType := ""
+	if len(arguments) != 0 {
+		dataType = expressionDataType(arguments[0])
+	}
+
+	if w.Function == windows.Sum && (dataType == dataTypes.String || dataType == dataTypes.Bool || dataType == dataTypes.Interval || dataTypes.IsTemporal(dataType)) {
+		return fmt.Errorf("%s can only be used with numeric values: %w", name, pkg.InvalidWindow)
+	}
+
+	if len(arguments) > 1 {
+		offset := arguments[1]
+		if n, err := strconv.ParseInt(offset.Literal, 10, 64); err != nil || offset.DataType != "" || n < 0 {
+			return fmt.Errorf("The offset of %s must be a number of rows: %w", name, pkg.InvalidWindow)
+		}
+	}
+
+	if len(arguments) > 2 {
+		dt, err := comparisonDataType(dataType, expressionDataType(arguments[2]))
+		if err != nil {
+			return fmt.Errorf("The default value of %s must have the data type of its value: %w", name, err)
+		}
+
+		dataType = dt
+	}
+
+	w.Arguments = arguments
+	w.DataType = windows.DataType(w.Function, dataType)
+
+	return nil
+}
+
+// resolveOver parses OVER ([PARTITION BY column, ...] [ORDER BY column [ASC|DESC] [NULLS FIRST|NULLS LAST], ...]).
+// Columns of PARTITION BY are columns of ORDER BY without a direction.
+func resolveOver(aliases tableAliases, w *Window) error {
+	tokens := append(w.over, make([]string, 10)...)
+	name := strings.ToUpper(w.Function)
+
+	partitionBy := make([]OrderByColumn, 0)
+	orderBy := make([]OrderByColumn, 0)
+
+	i := 0
+	if strings.ToLower(tokens[i]) == operators.PartitionKeyword {
+		if strings.ToLower(tokens[i+1]) != "by" {
+			return fmt.Errorf("Expected PARTITION BY in OVER of %s: %w", name, pkg.InvalidWindow)
+		}
+		i += 2
+
+		for {
+			column, nextIdx, err := validateOrderByColumn(aliases, tokens, i)
+			if err != nil {
+				return err
+			}
+
+			column.Direction = operators.Asc
+			column.Nulls = operators.NullsLast
+			partitionBy = append(partitionBy, column)
+
+			i = nextIdx
+			if tokens[i] != "," {
+				break
+			}
+			i++
+		}
+	}
+
+	if strings.ToLower(tokens[i]) == "order" {
+		if strings.ToLower(tokens[i+1]) != "by" {
+			return fmt.Errorf("Expected ORDER BY in OVER of %s: %w", name, pkg.InvalidWindow)
+		}
+
+		columns, nextIdx, err := validateOrderByKeys(aliases, tokens, i+2)
+		if err != nil {
+			return err
+		}
+
+		orderBy = columns
+		i = nextIdx
+	}
+
+	if tokens[i] != "" {
+		return fmt.Errorf("Expected PARTITION BY or ORDER BY in OVER of %s, got %s: %w", name, tokens[i], pkg.InvalidWindow)
+	}
+
+	for _, c := range append(partitionBy, orderBy...) {
+		if c.Function != "" {
+			return fmt.Errorf("Aggregate functions cannot be used in OVER of %s: %w", name, pkg.InvalidWindow)
+		}
+	}
+
+	w.PartitionBy = &OrderBy{Columns: partitionBy}
+	w.OrderBy = &OrderBy{Columns: orderBy}
+
+	return nil
+}
+
+// validateWindowGrouping checks that window functions are not used in a query that groups its rows.
+// Window functions are computed from the rows that were read, not from the groups.
+func validateWindowGrouping(columns []SelectableColumn, groupBy []GroupByColumn, having *ConditionNode) error {
+	hasWindows := false
+	hasAggregates := false
+	for _, c := range columns {
+		if c.Window != nil {
+			hasWindows = true
+		}
+
+		if c.Function != "" {
+			hasAggregates = true
+		}
+	}
+
+	if hasWindows && (hasAggregates || len(groupBy) != 0 || having != nil) {
+		return fmt.Errorf("Window functions cannot be combined with GROUP BY, HAVING or aggregate functions: %w", pkg.InvalidWindow)
+	}
+
+	return nil
+}
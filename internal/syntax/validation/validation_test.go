@@ -113,21 +113,47 @@ func TestValidWhereClause(t *testing.T) {
 
 func TestValidConditions(t *testing.T) {
 	statements := map[string]error{
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE a' = b":                             pkg.InvalidSelectableColumns,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'a.b' = b":                          pkg.InvalidConditionAlias,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' 56 b":                         pkg.InvalidComparisonOperator,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = b":                          pkg.InvalidValueToken,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' AND a' = b":             pkg.InvalidSelectableColumns,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' AND 'a.b' = b":          pkg.InvalidConditionAlias,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' AND 'g.b' 56 b":         pkg.InvalidComparisonOperator,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' AND 'g.b' = b":          pkg.InvalidValueToken,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' OR a' = b":              pkg.InvalidSelectableColumns,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' or 'a.b' = b":           pkg.InvalidConditionAlias,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' or 'g.b' 56 b":          pkg.InvalidComparisonOperator,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' Or 'g.b' = b":           pkg.InvalidValueToken,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::unknown = 'b' Or 'g.b' = b":  pkg.InvalidDataType,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' Or 'g.b'::unknown = b":  pkg.InvalidValueToken,
-		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' ANd 'g.b'::unknown = b": pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE a' = b":                               pkg.InvalidSelectableColumns,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'a.b' = b":                            pkg.InvalidConditionAlias,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' 56 b":                           pkg.InvalidComparisonOperator,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = b":                            pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' AND a' = b":               pkg.InvalidSelectableColumns,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' AND 'a.b' = b":            pkg.InvalidConditionAlias,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' AND 'g.b' 56 b":           pkg.InvalidComparisonOperator,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' AND 'g.b' = b":            pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' OR a' = b":                pkg.InvalidSelectableColumns,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' or 'a.b' = b":             pkg.InvalidConditionAlias,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' or 'g.b' 56 b":            pkg.InvalidComparisonOperator,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' Or 'g.b' = b":             pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::unknown = 'b' Or 'g.b' = b":    pkg.InvalidDataType,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' Or 'g.b'::unknown = b":    pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' ANd 'g.b'::unknown = b":   pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE ('g.b' = 'b' OR 'g.b' = 'c'":          pkg.InvalidParenthesis,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' OR 'g.b' = 'c')":          pkg.InvalidParenthesis,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE NOT (('g.b' = 'b') OR ('g.b' = 'c')":  pkg.InvalidParenthesis,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' 'g.b' = 'c'":              pkg.InvalidLogicalOperator,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' = 'b' AND NOT":                  pkg.InvalidSelectableColumns,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' IN 'b'":                         pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' IN ()":                          pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' IN ('a' 'b')":                   pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' NOT IN ('a', b)":                pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::int IN ('1', 'b')":             pkg.InvalidDataType,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' NOT ('a')":                      pkg.InvalidComparisonOperator,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::int LIKE '1%'":                 pkg.InvalidDataType,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' LIKE a%":                        pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' LIKE 'a%' ESCAPE '!!'":          pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' NOT ILIKE 'a%!' ESCAPE '!'":     pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' ~ '^/api/v[0-9+/'":              pkg.InvalidRegex,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' !~* '(a'":                       pkg.InvalidRegex,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::float ~ 'a'":                   pkg.InvalidDataType,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' ~ a":                            pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::int BETWEEN '1' '5'":           pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::int BETWEEN '1' OR '5'":        pkg.InvalidValueToken,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::int BETWEEN '1' AND 'b'":       pkg.InvalidDataType,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::float NOT BETWEEN 'a' AND '5'": pkg.InvalidDataType,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' IS 'b'":                         pkg.InvalidComparisonOperator,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' IS NOT 'b'":                     pkg.InvalidComparisonOperator,
+		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b' IS NULL 'b'":                    pkg.InvalidLogicalOperator,
 	}
 
 	for sql, stmtErr := range statements {
@@ -163,6 +189,10 @@ func TestValidConstraints(t *testing.T) {
 		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::int = '5' Or 'g.b' = 'b' LIMIT 6 OFFSET 12 ORDER By 'g.Year    ,'g.Entity'    DESC":                     pkg.InvalidOrderBy,
 		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::int = '5' Or 'g.b' = 'b' LIMIT 6 OFFSET 12 ORDER By 'g.Year'    ,    g.Entity'    DESC":                 pkg.InvalidOrderBy,
 		"SELECT      *      FROM path:../../../testdata/example.csv As g WHERE 'g.b'::int = '5' Or 'g.b' = 'b' LIMIT 6 OFFSET 12 ORDER By 'g.Year'    ,    'a.Entity'    DESC":                pkg.InvalidOrderBy,
+		"SELECT * FROM path:../../../testdata/example.csv As g ORDER BY 'g.Year' DESC NULLS":                                                                                                  pkg.InvalidOrderBy,
+		"SELECT * FROM path:../../../testdata/example.csv As g ORDER BY 'g.Year' NULLS MIDDLE":                                                                                                pkg.InvalidOrderBy,
+		"SELECT * FROM path:../../../testdata/example.csv As g ORDER BY 'g.Year' NULLS FIRST DESC":                                                                                            pkg.InvalidOrderBy,
+		"SELECT * FROM path:../../../testdata/example.csv As g ORDER BY 'g.Year' DESC, ":                                                                                                      pkg.InvalidOrderBy,
 	}
 
 	for sql, sqlErr := range statements {
@@ -193,12 +223,952 @@ SELECT
 	assert.Equal(t, len(metadata.SelectedColumns), 4)
 	assert.Equal(t, metadata.Alias, "e")
 	assert.Equal(t, metadata.FilePath, "../../../testdata/example.csv")
-	assert.Equal(t, len(metadata.Conditions), 4)
+	assert.Equal(t, countConditions(metadata.Condition), 4)
 
 	assert.NotNil(t, metadata.OrderBy)
 	assert.Equal(t, len(metadata.OrderBy.Columns), 2)
-	assert.Equal(t, metadata.OrderBy.Direction, operators.Desc)
+	assert.Equal(t, metadata.OrderBy.Columns[0].Direction, operators.Asc)
+	assert.Equal(t, metadata.OrderBy.Columns[1].Direction, operators.Desc)
 
 	assert.Equal(t, metadata.Offset, int64(8))
 	assert.Equal(t, metadata.Limit, int64(6))
 }
+
+func TestValidOrderBy(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g ORDER BY 'g.a'::int DESC, 'g.b' ASC NULLS FIRST, 'g.c' nulls last, 'g.d' LIMIT 5"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+	assert.Equal(t, metadata.OrderBy.Columns, []OrderByColumn{
+		{Alias: "g", Column: "a", DataType: "int", Direction: operators.Desc, Nulls: operators.NullsFirst},
+		{Alias: "g", Column: "b", Direction: operators.Asc, Nulls: operators.NullsFirst},
+		{Alias: "g", Column: "c", Direction: operators.Asc, Nulls: operators.NullsLast},
+		{Alias: "g", Column: "d", Direction: operators.Asc, Nulls: operators.NullsLast},
+	})
+	assert.Equal(t, metadata.Limit, int64(5))
+}
+
+func TestValidConditionTree(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a' = '1' OR 'g.b' = '2' AND NOT ('g.c' = '3' OR 'g.d' = '4') LIMIT 5"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+
+	// 'g.a' = '1' OR ('g.b' = '2' AND (NOT ('g.c' = '3' OR 'g.d' = '4')))
+	root := metadata.Condition
+	assert.Equal(t, root.LogicalOperator, operators.OrOperator)
+	assert.Equal(t, root.Left.Condition.Column, "a")
+
+	and := root.Right
+	assert.Equal(t, and.LogicalOperator, operators.AndOperator)
+	assert.Equal(t, and.Left.Condition.Column, "b")
+
+	not := and.Right
+	assert.Equal(t, not.LogicalOperator, operators.NotOperator)
+	assert.Nil(t, not.Right)
+	assert.Equal(t, not.Left.LogicalOperator, operators.OrOperator)
+	assert.Equal(t, not.Left.Left.Condition.Column, "c")
+	assert.Equal(t, not.Left.Right.Condition.Column, "d")
+
+	assert.Equal(t, metadata.Limit, int64(5))
+}
+
+func TestValidListConditions(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::int IN ('1', '2','3') AND 'g.b' not in ('x')"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+
+	in := metadata.Condition.Left.Condition
+	assert.Equal(t, in.ComparisonOperator, operators.InOperator)
+	assert.Equal(t, in.Values, []string{"1", "2", "3"})
+
+	notIn := metadata.Condition.Right.Condition
+	assert.Equal(t, notIn.ComparisonOperator, operators.NotInOperator)
+	assert.Equal(t, notIn.Values, []string{"x"})
+}
+
+func TestValidPatternConditions(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a' LIKE 'a!_%' ESCAPE '!' OR 'g.b' NOT ILIKE '%b%'"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+
+	like := metadata.Condition.Left.Condition
+	assert.Equal(t, like.ComparisonOperator, operators.LikeOperator)
+	assert.Equal(t, like.Value, "a!_%")
+	assert.Equal(t, like.Escape, "!")
+
+	notILike := metadata.Condition.Right.Condition
+	assert.Equal(t, notILike.ComparisonOperator, operators.NotILikeOperator)
+	assert.Equal(t, notILike.Value, "%b%")
+	assert.Equal(t, notILike.Escape, "\\")
+}
+
+func TestValidRegexConditions(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.path' ~ '^/api/v[0-9]+/' AND 'g.b' !~* 'abc'"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+
+	match := metadata.Condition.Left.Condition
+	assert.Equal(t, match.ComparisonOperator, operators.RegexMatchOperator)
+	assert.True(t, match.Regex.MatchString("/api/v12/users"))
+	assert.False(t, match.Regex.MatchString("/api/vx/users"))
+
+	notMatch := metadata.Condition.Right.Condition
+	assert.Equal(t, notMatch.ComparisonOperator, operators.NotRegexIMatchOperator)
+	assert.True(t, notMatch.Regex.MatchString("xABCx"))
+}
+
+func TestValidRangeConditions(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::int BETWEEN '2015' AND '2020' AND 'g.b'::float NOT BETWEEN '1.5' AND '2.5' OR 'g.c' = 'd'"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+
+	// (BETWEEN AND NOT BETWEEN) OR =
+	root := metadata.Condition
+	assert.Equal(t, root.LogicalOperator, operators.OrOperator)
+	assert.Equal(t, root.Right.Condition.Column, "c")
+
+	between := root.Left.Left.Condition
+	assert.Equal(t, between.ComparisonOperator, operators.BetweenOperator)
+	assert.Equal(t, between.Values, []string{"2015", "2020"})
+
+	notBetween := root.Left.Right.Condition
+	assert.Equal(t, notBetween.ComparisonOperator, operators.NotBetweenOperator)
+	assert.Equal(t, notBetween.Values, []string{"1.5", "2.5"})
+}
+
+func TestValidNullConditions(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a' IS NULL OR 'g.b'::int is not null AND 'g.c' = 'd'"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+
+	isNull := metadata.Condition.Left.Condition
+	assert.Equal(t, isNull.ComparisonOperator, operators.IsNullOperator)
+	assert.Equal(t, isNull.Column, "a")
+
+	isNotNull := metadata.Condition.Right.Left.Condition
+	assert.Equal(t, isNotNull.ComparisonOperator, operators.IsNotNullOperator)
+	assert.Equal(t, isNotNull.Column, "b")
+	assert.Equal(t, isNotNull.DataType, "int")
+}
+
+func countConditions(node *ConditionNode) int {
+	if node == nil {
+		return 0
+	}
+
+	if node.Condition != nil {
+		return 1
+	}
+
+	return countConditions(node.Left) + countConditions(node.Right)
+}
+
+func TestValidAggregates(t *testing.T) {
+	sql := "SELECT COUNT(*), sum('g.a'::int), AVG('g.a'::float), MIN('g.b'), MAX('g.b'::int) FROM path:../../../testdata/example.csv As g WHERE 'g.c' = 'd'"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(metadata.SelectedColumns))
+
+	assert.Equal(t, metadata.SelectedColumns[0].Function, "count")
+	assert.Equal(t, metadata.SelectedColumns[0].Column, "*")
+
+	assert.Equal(t, metadata.SelectedColumns[1].Function, "sum")
+	assert.Equal(t, metadata.SelectedColumns[1].Column, "a")
+	assert.Equal(t, metadata.SelectedColumns[1].DataType, "int")
+
+	assert.Equal(t, metadata.SelectedColumns[3].Function, "min")
+	assert.Equal(t, metadata.SelectedColumns[3].DataType, "")
+
+	assert.Equal(t, countConditions(metadata.Condition), 1)
+}
+
+func TestInvalidAggregates(t *testing.T) {
+	statements := []string{
+		"SELECT COUNT(*), 'g.a' FROM path:../../../testdata/example.csv As g",
+		"SELECT 'g.a', MAX('g.a') FROM path:../../../testdata/example.csv As g",
+		"SELECT SUM(*) FROM path:../../../testdata/example.csv As g",
+		"SELECT SUM('g.a'::string) FROM path:../../../testdata/example.csv As g",
+		"SELECT AVG('g.a'::string) FROM path:../../../testdata/example.csv As g",
+		"SELECT COUNT('g.a' FROM path:../../../testdata/example.csv As g",
+	}
+
+	for _, s := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(s))
+
+		assert.NotNil(t, err)
+		assert.True(t, errors.Is(err, pkg.InvalidAggregate), s)
+	}
+
+	_, err := ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT COUNT('g.a'), count('g.a') FROM path:../../../testdata/example.csv As g"))
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, pkg.InvalidDuplicatedColumn))
+
+	_, err = ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT COUNT('f.a') FROM path:../../../testdata/example.csv As g"))
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, pkg.InvalidColumnAlias))
+}
+
+func TestValidGroupBy(t *testing.T) {
+	sql := "SELECT 'g.a', 'g.b', COUNT(*), SUM('g.c'::int) FROM path:../../../testdata/example.csv As g WHERE 'g.d' = 'e' GROUP BY 'g.a', 'g.b' HAVING COUNT(*) > '5' AND MAX('g.c'::int) < '10' OR 'g.a' = 'f' ORDER BY AVG('g.c') DESC LIMIT 5"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+
+	assert.Equal(t, countConditions(metadata.Condition), 1)
+	assert.Equal(t, metadata.GroupBy, []GroupByColumn{{Alias: "g", Column: "a"}, {Alias: "g", Column: "b"}})
+
+	// (COUNT(*) > '5' AND MAX(c) < '10') OR 'g.a' = 'f'
+	assert.Equal(t, countConditions(metadata.Having), 3)
+	count := metadata.Having.Left.Left.Condition
+	assert.Equal(t, count.Function, "count")
+	assert.Equal(t, count.Column, "*")
+	assert.Equal(t, count.DataType, "int")
+	assert.Equal(t, count.Value, "5")
+
+	assert.Equal(t, metadata.OrderBy.Columns, []OrderByColumn{{Alias: "g", Column: "c", Function: "avg", Direction: operators.Desc, Nulls: operators.NullsFirst}})
+	assert.Equal(t, metadata.Limit, int64(5))
+
+	// aggregates used only in HAVING and ORDER BY are computed too
+	names := make([]string, 0)
+	for _, a := range metadata.Aggregates {
+		names = append(names, selectableColumnName(a))
+	}
+	assert.Equal(t, names, []string{"count(*)", "sum(c)", "max(c)", "avg(c)"})
+}
+
+func TestInvalidGroupBy(t *testing.T) {
+	statements := map[string]error{
+		"SELECT 'g.a', COUNT(*) FROM path:../../../testdata/example.csv As g GROUP BY 'g.b'":                       pkg.InvalidGroupBy,
+		"SELECT * FROM path:../../../testdata/example.csv As g GROUP BY 'g.b'":                                     pkg.InvalidGroupBy,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP 'g.a'":                                    pkg.InvalidGroupBy,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY g.a":                                   pkg.InvalidGroupBy,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'f.a'":                                 pkg.InvalidGroupBy,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g HAVING COUNT(*) > '1'":                          pkg.InvalidGroupBy,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' HAVING 'g.b' = '1'":              pkg.InvalidHaving,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' HAVING LIMIT 5":                  pkg.InvalidHaving,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' HAVING COUNT(*) > 'a'":           pkg.InvalidDataType,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' HAVING SUM(*) > '1'":             pkg.InvalidAggregate,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' ORDER BY 'g.b'":                  pkg.InvalidOrderBy,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' ORDER BY COUNT('f.b')":           pkg.InvalidOrderBy,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g WHERE COUNT(*) > '1' GROUP BY 'g.a'":            pkg.InvalidSelectableColumns,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' HAVING COUNT(*) > '1' 'g.b' = 1": pkg.InvalidLogicalOperator,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidDistinct(t *testing.T) {
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT DISTINCT 'g.a', 'g.b' FROM path:../../../testdata/example.csv As g WHERE 'g.c' = 'd'"))
+
+	assert.Nil(t, err)
+	assert.True(t, metadata.Distinct)
+	assert.Equal(t, 0, len(metadata.DistinctOn))
+	assert.Equal(t, 2, len(metadata.SelectedColumns))
+
+	metadata, err = ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT distinct ON ('g.a', 'g.b') * FROM path:../../../testdata/example.csv As g ORDER BY 'g.a'"))
+
+	assert.Nil(t, err)
+	assert.True(t, metadata.Distinct)
+	assert.Equal(t, "a", metadata.DistinctOn[0].Column)
+	assert.Equal(t, "b", metadata.DistinctOn[1].Column)
+	assert.Equal(t, "*", metadata.SelectedColumns[0].Column)
+
+	metadata, err = ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT * FROM path:../../../testdata/example.csv As g"))
+
+	assert.Nil(t, err)
+	assert.False(t, metadata.Distinct)
+}
+
+func TestInvalidDistinct(t *testing.T) {
+	statements := map[string]error{
+		"SELECT DISTINCT ON 'g.a' 'g.a' FROM path:../../../testdata/example.csv As g":                            pkg.InvalidDistinct,
+		"SELECT DISTINCT ON ('g.a' 'g.a' FROM path:../../../testdata/example.csv As g":                           pkg.InvalidDistinct,
+		"SELECT DISTINCT ON (g.a) 'g.a' FROM path:../../../testdata/example.csv As g":                            pkg.InvalidDistinct,
+		"SELECT DISTINCT ON ('f.a') 'g.a' FROM path:../../../testdata/example.csv As g":                          pkg.InvalidDistinct,
+		"SELECT DISTINCT ON ('g.b') 'g.a', COUNT(*) FROM path:../../../testdata/example.csv As g GROUP BY 'g.a'": pkg.InvalidDistinct,
+		"SELECT DISTINCT FROM path:../../../testdata/example.csv As g":                                           pkg.InvalidSelectableColumns,
+		"SELECT DISTINCT ON ('g.a') FROM path:../../../testdata/example.csv As g":                                pkg.InvalidSelectableColumns,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidSelectableExpressions(t *testing.T) {
+	sql := "SELECT 'g.a' AS first, 'g.b'::float * 1000 AS b_k, ('g.c'::int + 'g.d'::int) / 2 FROM path:../../../testdata/example.csv As g"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(metadata.SelectedColumns))
+
+	plain := metadata.SelectedColumns[0]
+	assert.Equal(t, "a", plain.Column)
+	assert.Equal(t, "first", plain.As)
+	assert.Nil(t, plain.Expression)
+
+	multiplied := metadata.SelectedColumns[1]
+	assert.Equal(t, "b_k", multiplied.As)
+	assert.Equal(t, operators.MultiplyOperator, multiplied.Expression.Operator)
+	assert.Equal(t, "b", multiplied.Expression.Left.Column)
+	assert.Equal(t, "float", multiplied.Expression.Left.DataType)
+	assert.Equal(t, "1000", multiplied.Expression.Right.Literal)
+
+	// parentheses override precedence
+	divided := metadata.SelectedColumns[2]
+	assert.Equal(t, "", divided.As)
+	assert.Equal(t, "('g.c'::int + 'g.d'::int) / 2", divided.Original)
+	assert.Equal(t, operators.DivideOperator, divided.Expression.Operator)
+	assert.Equal(t, operators.AddOperator, divided.Expression.Left.Operator)
+
+	// numbers can be quoted like values of conditions
+	metadata, err = ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT 'g.b'::float * '1.5', CONCAT('g.a', '1.5') FROM path:../../../testdata/example.csv As g WHERE 'g.b'::float - '0.5' > '10' AND LENGTH('g.a') + '1' > '2'"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1.5", metadata.SelectedColumns[0].Expression.Right.Literal)
+	assert.Equal(t, "", metadata.SelectedColumns[0].Expression.Right.DataType)
+	assert.Equal(t, "string", metadata.SelectedColumns[1].Expression.Arguments[1].DataType)
+	assert.Equal(t, "0.5", metadata.Condition.Left.Condition.Expression.Right.Literal)
+	assert.Equal(t, "", metadata.Condition.Right.Condition.Expression.Right.DataType)
+
+	metadata, err = ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT 'g.a', COUNT(*) AS total FROM path:../../../testdata/example.csv As g GROUP BY 'g.a'"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "total", metadata.SelectedColumns[1].As)
+	assert.Equal(t, "count", metadata.SelectedColumns[1].Function)
+}
+
+func TestInvalidSelectableExpressions(t *testing.T) {
+	statements := map[string]error{
+		"SELECT 'g.a' * 2 AS 'x' FROM path:../../../testdata/example.csv As g":                   pkg.InvalidSelectableColumns,
+		"SELECT 'g.a' * 2 AS 2a FROM path:../../../testdata/example.csv As g":                    pkg.InvalidSelectableColumns,
+		"SELECT 'g.a' * FROM path:../../../testdata/example.csv As g":                            pkg.InvalidSelectableColumns,
+		"SELECT ('g.a' * 2 FROM path:../../../testdata/example.csv As g":                         pkg.InvalidParenthesis,
+		"SELECT 'g.a'::string * 2 FROM path:../../../testdata/example.csv As g":                  pkg.InvalidDataType,
+		"SELECT 'g.a'::number * 2 FROM path:../../../testdata/example.csv As g":                  pkg.InvalidDataType,
+		"SELECT 'g.a' * 2 AS a, 'g.b' AS a FROM path:../../../testdata/example.csv As g":         pkg.InvalidDuplicatedColumn,
+		"SELECT 'g.a' * 'f.b' FROM path:../../../testdata/example.csv As g":                      pkg.InvalidColumnAlias,
+		"SELECT 'g.a' + 1, COUNT(*) FROM path:../../../testdata/example.csv As g":                pkg.InvalidAggregate,
+		"SELECT 'g.a' + 1, COUNT(*) FROM path:../../../testdata/example.csv As g GROUP BY 'g.b'": pkg.InvalidGroupBy,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidDates(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::date('02/01/2006') > '25/12/2020' AND 'g.b'::timestamp < '2021-01-01T10:00:00Z' AND 'g.c'::time BETWEEN '08:00' AND '16:30:00' ORDER BY 'g.a'::date('02/01/2006') DESC"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "date('02/01/2006')", metadata.Condition.Left.Left.Condition.DataType)
+	assert.Equal(t, "a", metadata.Condition.Left.Left.Condition.Column)
+	assert.Equal(t, "timestamp", metadata.Condition.Left.Right.Condition.DataType)
+	assert.Equal(t, "time", metadata.Condition.Right.Condition.DataType)
+	assert.Equal(t, "date('02/01/2006')", metadata.OrderBy.Columns[0].DataType)
+}
+
+func TestInvalidDates(t *testing.T) {
+	statements := map[string]error{
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::date = '2021-13-01'":  pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::time = '25:00'":       pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::date(2006) = '2021'":  pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::int('2006') = '2021'": pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::date LIKE '2021%'":    pkg.InvalidDataType,
+		"SELECT 'g.a'::date + 1 FROM path:../../../testdata/example.csv As g":                     pkg.InvalidDataType,
+		"SELECT SUM('g.a'::date) FROM path:../../../testdata/example.csv As g":                    pkg.InvalidAggregate,
+		"SELECT * FROM path:../../../testdata/example.csv As g ORDER BY 'g.a'::datetime":          pkg.InvalidDataType,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidBoolsAndDecimals(t *testing.T) {
+	sql := "SELECT 'g.a'::decimal(10,2) * 1.5 AS total FROM path:../../../testdata/example.csv As g WHERE 'g.b'::bool = 'Yes' AND 'g.a'::decimal(10, 2) > '-12345678.90' ORDER BY 'g.a'::decimal(10,2)"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "decimal(10,2)", metadata.SelectedColumns[0].Expression.Left.DataType)
+	assert.Equal(t, "bool", metadata.Condition.Left.Condition.DataType)
+	assert.Equal(t, "decimal(10, 2)", metadata.Condition.Right.Condition.DataType)
+	assert.Equal(t, "decimal(10,2)", metadata.OrderBy.Columns[0].DataType)
+}
+
+func TestInvalidBoolsAndDecimals(t *testing.T) {
+	statements := map[string]error{
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::bool = 'maybe'":              pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::decimal = '1e5'":             pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::decimal(4,2) = '123.4'":      pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::decimal(4,2) = '1.234'":      pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::decimal(2,4) = '1'":          pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::decimal('02/01/2006') = '1'": pkg.InvalidDataType,
+		"SELECT 'g.a'::bool + 1 FROM path:../../../testdata/example.csv As g":                            pkg.InvalidDataType,
+		"SELECT SUM('g.a'::bool) FROM path:../../../testdata/example.csv As g":                           pkg.InvalidAggregate,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidDateFunctions(t *testing.T) {
+	sql := "SELECT EXTRACT(MONTH FROM 'g.a'::date) AS month, DATE_TRUNC('week', 'g.b'::timestamp) FROM path:../../../testdata/example.csv As g WHERE 'g.a'::date + INTERVAL '7 days' > NOW() - INTERVAL '1 month' ORDER BY CURRENT_DATE"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+
+	extract := metadata.SelectedColumns[0].Expression
+	assert.Equal(t, "extract", extract.Function)
+	assert.Equal(t, "month", extract.Arguments[0].Literal)
+	assert.Equal(t, "a", extract.Arguments[1].Column)
+	assert.Equal(t, "DATE_TRUNC('week', 'g.b'::timestamp)", metadata.SelectedColumns[1].Original)
+
+	condition := metadata.Condition.Condition
+	assert.Equal(t, "date", condition.DataType)
+	assert.Equal(t, operators.AddOperator, condition.Expression.Operator)
+	assert.Equal(t, "7 days", condition.Expression.Right.Literal)
+	assert.Equal(t, "interval", condition.Expression.Right.DataType)
+	assert.Equal(t, operators.SubtractOperator, condition.ValueExpression.Operator)
+	assert.Equal(t, "now", condition.ValueExpression.Left.Function)
+
+	assert.Equal(t, "current_date", metadata.OrderBy.Columns[0].Expression.Function)
+}
+
+func TestInvalidDateFunctions(t *testing.T) {
+	statements := map[string]error{
+		"SELECT EXTRACT(decade FROM 'g.a'::date) FROM path:../../../testdata/example.csv As g":      pkg.InvalidFunction,
+		"SELECT EXTRACT(year 'g.a'::date) FROM path:../../../testdata/example.csv As g":             pkg.InvalidFunction,
+		"SELECT EXTRACT(year FROM 'g.a'::int) FROM path:../../../testdata/example.csv As g":         pkg.InvalidFunction,
+		"SELECT DATE_TRUNC('decade', 'g.a'::date) FROM path:../../../testdata/example.csv As g":     pkg.InvalidFunction,
+		"SELECT NOW('g.a') FROM path:../../../testdata/example.csv As g":                            pkg.InvalidFunction,
+		"SELECT 'g.a'::date + INTERVAL '7 fortnights' FROM path:../../../testdata/example.csv As g": pkg.InvalidDataType,
+		"SELECT 'g.a'::date + INTERVAL 7 FROM path:../../../testdata/example.csv As g":              pkg.InvalidDataType,
+		"SELECT 'g.a'::date * INTERVAL '1 day' FROM path:../../../testdata/example.csv As g":        pkg.InvalidDataType,
+		"SELECT INTERVAL '1 day' - 'g.a'::date FROM path:../../../testdata/example.csv As g":        pkg.InvalidDataType,
+		"SELECT 'g.a'::date + 1 FROM path:../../../testdata/example.csv As g":                       pkg.InvalidDataType,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidFunctions(t *testing.T) {
+	sql := "SELECT UPPER('g.a') AS name, CONCAT('g.a', ' - ', 'g.b') FROM path:../../../testdata/example.csv As g WHERE LOWER('g.c') = 'level 1' ORDER BY LENGTH('g.a')"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(metadata.SelectedColumns))
+
+	upper := metadata.SelectedColumns[0]
+	assert.Equal(t, "name", upper.As)
+	assert.Equal(t, "upper", upper.Expression.Function)
+	assert.Equal(t, "a", upper.Expression.Arguments[0].Column)
+
+	// quoted values that are not columns of the alias are string literals
+	concat := metadata.SelectedColumns[1]
+	assert.Equal(t, "CONCAT('g.a', ' - ', 'g.b')", concat.Original)
+	assert.Equal(t, 3, len(concat.Expression.Arguments))
+	assert.Equal(t, " - ", concat.Expression.Arguments[1].Literal)
+	assert.Equal(t, "b", concat.Expression.Arguments[2].Column)
+
+	assert.Equal(t, "lower", metadata.Condition.Condition.Expression.Function)
+	assert.Equal(t, "level 1", metadata.Condition.Condition.Value)
+
+	assert.Equal(t, "int", metadata.OrderBy.Columns[0].DataType)
+	assert.Equal(t, "length", metadata.OrderBy.Columns[0].Expression.Function)
+}
+
+func TestInvalidFunctions(t *testing.T) {
+	statements := map[string]error{
+		"SELECT UPPER() FROM path:../../../testdata/example.csv As g":                                              pkg.InvalidFunction,
+		"SELECT UPPER('g.a', 'g.b') FROM path:../../../testdata/example.csv As g":                                  pkg.InvalidFunction,
+		"SELECT REPLACE('g.a', 'x') FROM path:../../../testdata/example.csv As g":                                  pkg.InvalidFunction,
+		"SELECT UPPER('g.a') * 2 FROM path:../../../testdata/example.csv As g":                                     pkg.InvalidDataType,
+		"SELECT UPPER('g.a'), COUNT(*) FROM path:../../../testdata/example.csv As g GROUP BY 'g.b'":                pkg.InvalidGroupBy,
+		"SELECT 'g.a', COUNT(*) FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' ORDER BY UPPER('g.b')": pkg.InvalidOrderBy,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidCollations(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a' COLLATE NoCase = 'abc' AND LOWER('g.b') COLLATE unicode IN ('x') ORDER BY 'g.c' COLLATE natural DESC, MIN('g.d') COLLATE binary"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "nocase", metadata.Condition.Left.Condition.Collation)
+	assert.Equal(t, "a", metadata.Condition.Left.Condition.Column)
+	assert.Equal(t, "unicode", metadata.Condition.Right.Condition.Collation)
+
+	assert.Equal(t, "natural", metadata.OrderBy.Columns[0].Collation)
+	assert.Equal(t, operators.Desc, metadata.OrderBy.Columns[0].Direction)
+	assert.Equal(t, "binary", metadata.OrderBy.Columns[1].Collation)
+}
+
+func TestInvalidCollations(t *testing.T) {
+	statements := map[string]error{
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a' COLLATE french = 'abc'":                  pkg.InvalidCollation,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::int COLLATE nocase = '1'":               pkg.InvalidCollation,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a' COLLATE nocase LIKE 'a%'":                pkg.InvalidCollation,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a' COLLATE nocase ~ 'a'":                    pkg.InvalidCollation,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a' = 'abc' COLLATE nocase":                  pkg.InvalidLogicalOperator,
+		"SELECT * FROM path:../../../testdata/example.csv As g ORDER BY 'g.a' COLLATE":                              pkg.InvalidCollation,
+		"SELECT * FROM path:../../../testdata/example.csv As g ORDER BY LENGTH('g.a') COLLATE nocase":               pkg.InvalidCollation,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' ORDER BY COUNT(*) COLLATE nocase": pkg.InvalidCollation,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidColumnComparisons(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.actual'::float > 'g.budget'::float * 1.1 AND 'g.a' = 'g.b'::int AND 'g.c'::int <= 'g.d'::decimal(10,2) AND 'g.e' = 'f.g' AND 'g.h' != LOWER('g.i')"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+
+	// (((a AND b) AND c) AND d) AND e
+	variance := metadata.Condition.Left.Left.Left.Left.Condition
+	assert.Equal(t, "float", variance.DataType)
+	assert.Equal(t, "*", variance.ValueExpression.Operator)
+	assert.Equal(t, "budget", variance.ValueExpression.Left.Column)
+
+	// a column without a data type is compared as the data type of the other one
+	untyped := metadata.Condition.Left.Left.Left.Right.Condition
+	assert.Equal(t, "int", untyped.DataType)
+	assert.Equal(t, "b", untyped.ValueExpression.Column)
+
+	assert.Equal(t, "decimal(10,2)", metadata.Condition.Left.Left.Right.Condition.DataType)
+
+	// the alias is not g so this is a string
+	literal := metadata.Condition.Left.Right.Condition
+	assert.Nil(t, literal.ValueExpression)
+	assert.Equal(t, "f.g", literal.Value)
+
+	assert.Equal(t, "lower", metadata.Condition.Right.Condition.ValueExpression.Function)
+}
+
+func TestInvalidColumnComparisons(t *testing.T) {
+	statements := map[string]error{
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::int = 'g.b'::date":                         pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::bool = 'g.b'::int":                         pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::int = LENGTH('g.b') + 'g.c'::bool":         pkg.InvalidDataType,
+		"SELECT * FROM path:../../../testdata/example.csv As g WHERE 'g.a'::int = 'g.b'::int + 'f.c'::int":             pkg.InvalidConditionAlias,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g GROUP BY 'g.a' HAVING MAX('g.b'::int) > 'g.c'::int": pkg.InvalidHaving,
+		"SELECT 'g.a' FROM path:../../../testdata/example.csv As g WHERE 'g.a'::int = 'g.b'::int LIKE 'a'":             pkg.InvalidLogicalOperator,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidJoins(t *testing.T) {
+	sql := "SELECT 'e.Year', 'l.Description', COUNT('l.Level') FROM path:../../../testdata/example.csv AS e LEFT OUTER JOIN path:../../../testdata/levels.csv AS l ON 'e.Industry_aggregation_NZSIOC' = 'l.Level' AND 'l.Level' != 'Level 5' JOIN path:../../../testdata/levels.csv AS d ON 'd.Level' = 'l.Level' WHERE 'e.Year'::int > '2015' GROUP BY 'e.Year', 'l.Description' ORDER BY 'l.Description'"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(metadata.Joins))
+
+	left := metadata.Joins[0]
+	assert.Equal(t, "left", left.Type)
+	assert.Equal(t, "l", left.Alias)
+	assert.Equal(t, "../../../testdata/levels.csv", left.FilePath)
+	assert.Equal(t, "and", left.On.LogicalOperator)
+	assert.Equal(t, "e.Industry_aggregation_NZSIOC", left.On.Left.Condition.Column)
+	assert.Equal(t, "l.Level", left.On.Left.Condition.ValueExpression.Column)
+
+	inner := metadata.Joins[1]
+	assert.Equal(t, "inner", inner.Type)
+	assert.Equal(t, "d.Level", inner.On.Condition.Column)
+
+	// columns are named by their alias
+	assert.Equal(t, "e.Year", metadata.SelectedColumns[0].Column)
+	assert.Equal(t, "l.Description", metadata.SelectedColumns[1].Column)
+	assert.Equal(t, "l.Level", metadata.Aggregates[0].Column)
+	assert.Equal(t, "e.Year", metadata.Condition.Condition.Column)
+	assert.Equal(t, "l.Description", metadata.GroupBy[1].Column)
+	assert.Equal(t, "l.Description", metadata.OrderBy.Columns[0].Column)
+
+	metadata, err = ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT 'a.Level', 'b.Level' FROM path:../../../testdata/levels.csv AS a RIGHT JOIN path:../../../testdata/levels.csv AS b ON 'a.Level' = 'b.Level'"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "right", metadata.Joins[0].Type)
+	assert.Equal(t, 2, len(metadata.SelectedColumns))
+}
+
+func TestInvalidJoins(t *testing.T) {
+	statements := map[string]error{
+		"SELECT * FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS e ON 'e.Year' = 'e.Year'":                                      pkg.InvalidJoin,
+		"SELECT * FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS l":                                                             pkg.InvalidJoin,
+		"SELECT * FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS l ON":                                                          pkg.InvalidJoin,
+		"SELECT * FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS l WHERE 'e.Year' = '2021'":                                     pkg.InvalidJoin,
+		"SELECT * FROM path:../../../testdata/example.csv AS e LEFT path:../../../testdata/levels.csv AS l ON 'e.Year' = 'l.Level'":                                     pkg.InvalidJoin,
+		"SELECT * FROM path:../../../testdata/example.csv AS e INNER OUTER JOIN path:../../../testdata/levels.csv AS l ON 'e.Year' = 'l.Level'":                         pkg.InvalidJoin,
+		"SELECT * FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv l ON 'e.Year' = 'l.Level'":                                        pkg.InvalidAsToken,
+		"SELECT * FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS l ON 'e.Year'::int = 'l.Level'::int + 'd.Level'::int":          pkg.InvalidConditionAlias,
+		"SELECT * FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS l ON 'd.Year' = 'l.Level'":                                     pkg.InvalidConditionAlias,
+		"SELECT 'd.Level' FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS l ON 'e.Year' = 'l.Level'":                             pkg.InvalidColumnAlias,
+		"SELECT 'e.Year', 'l.Level' FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS l ON 'e.Year' = 'l.Level' GROUP BY 'e.Year'": pkg.InvalidGroupBy,
+	}
+
+	for sql, sqlErr := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, sqlErr), sql)
+	}
+}
+
+func TestValidAsofJoins(t *testing.T) {
+	sql := "SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON 't.sym' = 'q.sym' AND 't.ts'::timestamp >= 'q.ts'::timestamp ASOF LEFT JOIN path:../../../testdata/quotes.csv AS n ON 'n.ts'::timestamp > 't.ts'::timestamp"
+
+	metadata, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(metadata.Joins))
+	assert.Equal(t, "asof", metadata.Joins[0].Type)
+	assert.Equal(t, ">=", metadata.Joins[0].On.Right.Condition.ComparisonOperator)
+	assert.Equal(t, "asof left", metadata.Joins[1].Type)
+	assert.Equal(t, "n.ts", metadata.Joins[1].On.Condition.Column)
+}
+
+func TestInvalidAsofJoins(t *testing.T) {
+	statements := []string{
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON 't.sym' = 'q.sym'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON 't.ts' >= 'q.ts' AND 't.ts' <= 'q.ts'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON 't.sym' = 'q.sym' OR 't.ts' >= 'q.ts'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON NOT 't.ts' >= 'q.ts'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON 't.sym' != 'q.sym' AND 't.ts' >= 'q.ts'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON 't.sym' = 'AAPL' AND 't.ts' >= 'q.ts'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON 'q.bid' = 'q.sym' AND 't.ts' >= 'q.ts'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON LOWER('t.sym') = 'q.sym' AND 't.ts' >= 'q.ts'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF JOIN path:../../../testdata/quotes.csv AS q ON 't.ts'::timestamp >= 'q.ts'::timestamp + INTERVAL '1 day'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF OUTER JOIN path:../../../testdata/quotes.csv AS q ON 't.ts' >= 'q.ts'",
+		"SELECT * FROM path:../../../testdata/trades.csv AS t ASOF RIGHT JOIN path:../../../testdata/quotes.csv AS q ON 't.ts' >= 'q.ts'",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, pkg.InvalidJoin), sql)
+	}
+}
+
+func TestValidSetOperations(t *testing.T) {
+	statements := []string{
+		"SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a UNION SELECT 'b.Level' FROM path:../../../testdata/levels.csv AS b",
+		"SELECT 'a.Level', 'a.Description' FROM path:../../../testdata/levels.csv AS a WHERE 'a.Level' = 'Level 1' UNION ALL SELECT 'b.Level', 'b.Description' FROM path:../../../testdata/levels.csv AS b ORDER BY 'b.Level' LIMIT 1",
+		"SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a intersect SELECT 'b.Level' FROM path:../../../testdata/levels.csv AS b EXCEPT SELECT 'c.Level' FROM path:../../../testdata/levels.csv AS c",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a UNION SELECT 'b.Level' FROM path:../../../testdata/levels.csv AS b",
+		"SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a WHERE 'a.Description' = 'union' UNION SELECT 'b.Level' FROM path:../../../testdata/levels.csv AS b",
+	}
+
+	for _, sql := range statements {
+		queries, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.Nil(t, err, sql)
+		assert.True(t, len(queries) > 1, sql)
+		assert.Equal(t, "", queries[0].Operator, sql)
+	}
+}
+
+func TestInvalidSetOperations(t *testing.T) {
+	statements := []string{
+		"SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a UNION",
+		"UNION SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a",
+		"SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a UNION UNION SELECT 'b.Level' FROM path:../../../testdata/levels.csv AS b",
+		"SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a UNION ALL",
+		"SELECT 'a.Level', 'a.Description' FROM path:../../../testdata/levels.csv AS a EXCEPT SELECT 'b.Level' FROM path:../../../testdata/levels.csv AS b",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, pkg.InvalidSetOperation), sql)
+	}
+}
+
+func TestValidSubqueries(t *testing.T) {
+	statements := []string{
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE 'a.Level' IN (SELECT 'b.Level' FROM path:../../../testdata/blocklist.csv AS b)",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE 'a.Level' not in (select 'b.Level' FROM path:../../../testdata/blocklist.csv AS b WHERE 'b.Reason' = 'Duplicated' LIMIT 10) AND 'a.Level' != 'Level 1'",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE EXISTS (SELECT * FROM path:../../../testdata/blocklist.csv AS b WHERE 'b.Level' = 'a.Level')",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE NOT EXISTS (SELECT * FROM path:../../../testdata/blocklist.csv AS b WHERE ('b.Reason' = 'Duplicated' OR 'b.Reason' = 'Unknown') AND 'a.Level' = 'b.Level')",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE 'a.Level' IN (SELECT MAX('b.Level') FROM path:../../../testdata/blocklist.csv AS b GROUP BY 'b.Reason')",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a JOIN path:../../../testdata/levels.csv AS c ON 'a.Level' = 'c.Level' WHERE 'c.Level' IN (SELECT 'b.Level' FROM path:../../../testdata/blocklist.csv AS b WHERE 'b.Reason' = 'a.Description')",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.Nil(t, err, sql)
+	}
+
+	m, err := ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT * FROM path:../../../testdata/levels.csv AS a WHERE 'a.Level'::int IN (SELECT 'b.Level' FROM path:../../../testdata/blocklist.csv AS b WHERE 'b.Reason' = 'a.Description' AND 'b.Level' != '1')"))
+	assert.Nil(t, err)
+
+	s := m.Condition.Condition.Subquery
+	assert.Equal(t, "int", m.Condition.Condition.DataType)
+	assert.Equal(t, 2, len(s.Metadata.SelectedColumns))
+	assert.Equal(t, "Reason", s.Metadata.SelectedColumns[1].Column)
+	assert.Equal(t, "Description", s.Correlated[0].Column)
+	assert.Equal(t, "!=", s.Metadata.Condition.Condition.ComparisonOperator)
+}
+
+func TestInvalidSubqueries(t *testing.T) {
+	statements := []string{
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE 'a.Level' IN (SELECT 'b.Level', 'b.Reason' FROM path:../../../testdata/blocklist.csv AS b)",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE 'a.Level' IN (SELECT * FROM path:../../../testdata/blocklist.csv AS b)",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE EXISTS 'a.Level'",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE EXISTS (SELECT * FROM path:../../../testdata/blocklist.csv AS b WHERE 'b.Level' = 'a.Level' OR 'b.Reason' = 'Unknown')",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE EXISTS (SELECT * FROM path:../../../testdata/blocklist.csv AS b WHERE 'b.Level' != 'a.Level')",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE EXISTS (SELECT * FROM path:../../../testdata/blocklist.csv AS b WHERE LOWER('b.Level') = 'a.Level')",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE EXISTS (SELECT * FROM path:../../../testdata/blocklist.csv AS b WHERE 'b.Level' = 'a.Level' LIMIT 1)",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a WHERE EXISTS (SELECT * FROM path:../../../testdata/blocklist.csv AS a)",
+		"SELECT 'a.Level', COUNT(*) FROM path:../../../testdata/levels.csv AS a GROUP BY 'a.Level' HAVING COUNT(*) IN (SELECT 'b.Level' FROM path:../../../testdata/blocklist.csv AS b)",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a JOIN path:../../../testdata/blocklist.csv AS b ON EXISTS (SELECT * FROM path:../../../testdata/blocklist.csv AS c)",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateMetadata(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, pkg.InvalidSubquery), sql)
+	}
+
+	// columns of the outer query can only be compared in WHERE
+	_, err := ValidateAndCreateMetadata(tokenizer.Tokenize("SELECT * FROM path:../../../testdata/levels.csv AS a WHERE EXISTS (SELECT 'a.Level' FROM path:../../../testdata/blocklist.csv AS b)"))
+	assert.NotNil(t, err)
+}
+
+func TestValidDerivedTables(t *testing.T) {
+	statements := []string{
+		"SELECT 't.Level' FROM (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a WHERE 'a.Level' != 'Level 1') AS t ORDER BY 't.Level'",
+		"SELECT * FROM (SELECT * FROM (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a) AS b) AS c",
+		"SELECT * FROM (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a UNION SELECT 'b.Level' FROM path:../../../testdata/blocklist.csv AS b) AS u",
+		"SELECT * FROM path:../../../testdata/levels.csv AS a LEFT JOIN (SELECT 'b.Level', 'b.Reason' FROM path:../../../testdata/blocklist.csv AS b) AS r ON 'a.Level' = 'r.Level'",
+		"WITH t AS (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a) SELECT * FROM t",
+		"with t as (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a), u AS (SELECT 'x.Level' FROM t AS x) SELECT * FROM u AS y JOIN t ON 'y.Level' = 't.Level'",
+		"WITH t AS (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a) SELECT 'x.Level' FROM t AS x UNION SELECT 'y.Level' FROM t AS y",
+		"WITH t AS (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a) SELECT * FROM path:../../../testdata/levels.csv AS b WHERE 'b.Level' IN (SELECT 't.Level' FROM t)",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.Nil(t, err, sql)
+	}
+}
+
+func TestInvalidDerivedTables(t *testing.T) {
+	statements := []string{
+		"WITH t AS (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a)",
+		"WITH t AS (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a), t AS (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a) SELECT * FROM t",
+		"WITH t (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a) SELECT * FROM t",
+		"WITH 't' AS (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a) SELECT * FROM t",
+		"WITH t AS ('a.Level') SELECT * FROM t",
+		"SELECT * FROM ('a.Level') AS t",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, pkg.InvalidDerivedTable), sql)
+	}
+
+	statements = []string{
+		"SELECT * FROM (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a)",
+		"SELECT * FROM (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a AS t",
+		"SELECT * FROM t AS x",
+		"WITH u AS (SELECT * FROM t AS x), t AS (SELECT 'a.Level' FROM path:../../../testdata/levels.csv AS a) SELECT * FROM u",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+	}
+}
+
+func TestValidConditionalExpressions(t *testing.T) {
+	statements := []string{
+		"SELECT CASE WHEN 'e.Value'::float > '100' THEN 'big' ELSE 'small' END AS bucket FROM path:../../../testdata/example.csv AS e",
+		"SELECT 'e.Year', CASE WHEN 'e.Year'::int = '2021' AND 'e.Units' IS NOT NULL THEN 'e.Units' WHEN 'e.Year'::int = '2020' THEN 'old' END FROM path:../../../testdata/example.csv AS e",
+		"SELECT CASE WHEN 'e.Value'::float > '100' THEN 'e.Value'::float * 2 ELSE 0 END AS doubled FROM path:../../../testdata/example.csv AS e",
+		"SELECT UPPER(CASE WHEN 'e.Year' = '2021' THEN 'new' ELSE CASE WHEN 'e.Year' = '2020' THEN 'old' END END) AS age FROM path:../../../testdata/example.csv AS e",
+		"SELECT COALESCE('e.Units', 'e.Variable_name', '0') AS units, NULLIF('e.Value', 'C') AS value FROM path:../../../testdata/example.csv AS e",
+		"SELECT 'e.Year' FROM path:../../../testdata/example.csv AS e WHERE CASE WHEN 'e.Year'::int > '2020' THEN 'new' ELSE 'old' END = 'new'",
+		"SELECT 'e.Year' FROM path:../../../testdata/example.csv AS e WHERE COALESCE('e.Units', 'e.Variable_name') = 'Dollars' AND NULLIF('e.Value', 'C') IS NULL",
+		"SELECT 'e.Year' FROM path:../../../testdata/example.csv AS e ORDER BY CASE WHEN 'e.Year' = '2021' THEN 0 ELSE 1 END, 'e.Year' DESC",
+		"SELECT 'e.Year', COUNT(*) FROM path:../../../testdata/example.csv AS e GROUP BY 'e.Year' ORDER BY CASE WHEN 'e.Year' = '2021' THEN 0 ELSE 1 END",
+		"SELECT CASE WHEN 'e.Year' = 'l.Level' THEN 'l.Description' END AS d FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS l ON 'e.Industry_aggregation_NZSIOC' = 'l.Level'",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.Nil(t, err, sql)
+	}
+}
+
+func TestInvalidConditionalExpressions(t *testing.T) {
+	statements := []string{
+		"SELECT CASE 'e.Year' WHEN '2021' THEN 'new' END FROM path:../../../testdata/example.csv AS e",
+		"SELECT CASE WHEN 'e.Year' = '2021' 'new' END FROM path:../../../testdata/example.csv AS e",
+		"SELECT CASE WHEN 'e.Year' = '2021' THEN 'new' FROM path:../../../testdata/example.csv AS e",
+		"SELECT CASE WHEN THEN 'new' END FROM path:../../../testdata/example.csv AS e",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, pkg.InvalidCase), sql)
+	}
+
+	statements = []string{
+		"SELECT CASE WHEN 'e.Year' = '2021' THEN 'new' ELSE 1 END FROM path:../../../testdata/example.csv AS e",
+		"SELECT COALESCE('e.Year'::int, 'none') FROM path:../../../testdata/example.csv AS e",
+		"SELECT NULLIF('e.Year'::date, 1) FROM path:../../../testdata/example.csv AS e",
+		"SELECT CASE WHEN 'e.Year' = '2021' THEN 'new' END * 2 FROM path:../../../testdata/example.csv AS e",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, pkg.InvalidDataType), sql)
+	}
+
+	statements = []string{
+		"SELECT CASE WHEN 'x.Year' = '2021' THEN 'new' END FROM path:../../../testdata/example.csv AS e",
+		"SELECT 'e.Year' FROM path:../../../testdata/example.csv AS e WHERE CASE WHEN 'e.Year' IN (SELECT 'l.Level' FROM path:../../../testdata/levels.csv AS l) THEN 1 END = '1'",
+		"SELECT NULLIF('e.Year') FROM path:../../../testdata/example.csv AS e",
+		"SELECT CASE WHEN 'e.Units' = 'x' THEN 'a' END AS u, COUNT(*) FROM path:../../../testdata/example.csv AS e GROUP BY 'e.Year'",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+	}
+}
+
+func TestValidWindowFunctions(t *testing.T) {
+	statements := []string{
+		"SELECT 'e.Year', ROW_NUMBER() OVER (PARTITION BY 'e.Industry_code_NZSIOC' ORDER BY 'e.Year'::int DESC) AS rn FROM path:../../../testdata/example.csv AS e",
+		"SELECT RANK() OVER (ORDER BY 'e.Year'::int DESC NULLS LAST, 'e.Units'), DENSE_RANK() OVER (PARTITION BY 'e.Year', 'e.Units') FROM path:../../../testdata/example.csv AS e",
+		"SELECT 'e.Year', LAG('e.Value') OVER (ORDER BY 'e.Year'), LEAD('e.Value'::float, 2, 0) OVER (PARTITION BY 'e.Units' ORDER BY 'e.Year') AS next FROM path:../../../testdata/example.csv AS e",
+		"SELECT SUM('e.Value'::float) OVER (PARTITION BY 'e.Year' ORDER BY 'e.Units') AS running, SUM('e.Value'::int) OVER () FROM path:../../../testdata/example.csv AS e WHERE 'e.Year' = '2021'",
+		"SELECT ROW_NUMBER() OVER (ORDER BY LOWER('e.Units')) FROM path:../../../testdata/example.csv AS e ORDER BY 'e.Year'",
+		"SELECT 'l.Level', ROW_NUMBER() OVER (PARTITION BY 'e.Year' ORDER BY 'l.Level') AS rn FROM path:../../../testdata/example.csv AS e JOIN path:../../../testdata/levels.csv AS l ON 'e.Industry_aggregation_NZSIOC' = 'l.Level'",
+		"SELECT 't.Year' FROM (SELECT 'e.Year', ROW_NUMBER() OVER (PARTITION BY 'e.Units' ORDER BY 'e.Year') AS rn FROM path:../../../testdata/example.csv AS e) AS t WHERE 't.rn'::int <= '3'",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.Nil(t, err, sql)
+	}
+}
+
+func TestInvalidWindowFunctions(t *testing.T) {
+	statements := []string{
+		"SELECT ROW_NUMBER('e.Year') OVER () FROM path:../../../testdata/example.csv AS e",
+		"SELECT LAG() OVER () FROM path:../../../testdata/example.csv AS e",
+		"SELECT LAG('e.Value', 'e.Year') OVER () FROM path:../../../testdata/example.csv AS e",
+		"SELECT LEAD('e.Value', -1) OVER () FROM path:../../../testdata/example.csv AS e",
+		"SELECT SUM('e.Value'::string) OVER () FROM path:../../../testdata/example.csv AS e",
+		"SELECT RANK() OVER (PARTITION 'e.Year') FROM path:../../../testdata/example.csv AS e",
+		"SELECT RANK() OVER (ORDER BY 'e.Year' LIMIT 1) FROM path:../../../testdata/example.csv AS e",
+		"SELECT RANK() OVER (ORDER BY COUNT(*)) FROM path:../../../testdata/example.csv AS e",
+		"SELECT RANK() OVER 'e.Year' FROM path:../../../testdata/example.csv AS e",
+		"SELECT 'e.Year'::int - LAG('e.Year'::int) OVER () FROM path:../../../testdata/example.csv AS e",
+		"SELECT 'e.Year', ROW_NUMBER() OVER () FROM path:../../../testdata/example.csv AS e GROUP BY 'e.Year'",
+		"SELECT COUNT(*), ROW_NUMBER() OVER () FROM path:../../../testdata/example.csv AS e",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.True(t, errors.Is(err, pkg.InvalidWindow), sql)
+	}
+
+	statements = []string{
+		"SELECT ROW_NUMBER() OVER (ORDER BY 'x.Year') FROM path:../../../testdata/example.csv AS e",
+		"SELECT LAG('x.Year'::int) OVER () FROM path:../../../testdata/example.csv AS e",
+		"SELECT LAG('e.Year'::int, 1, 'none') OVER () FROM path:../../../testdata/example.csv AS e",
+	}
+
+	for _, sql := range statements {
+		_, err := ValidateAndCreateQueries(tokenizer.Tokenize(sql))
+
+		assert.NotNil(t, err, sql)
+		assert.False(t, errors.Is(err, pkg.InvalidWindow), sql)
+	}
+}
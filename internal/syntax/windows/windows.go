@@ -0,0 +1,48 @@
+package windows
+
+import (
+	"github.com/MarioLegenda/cig/internal/syntax/aggregates"
+	"github.com/MarioLegenda/cig/internal/syntax/dataTypes"
+)
+
+const RowNumber = "row_number"
+const Rank = "rank"
+const DenseRank = "dense_rank"
+const Lag = "lag"
+const Lead = "lead"
+const Sum = aggregates.Sum
+
+// Window describes the number of arguments a window function accepts
+type Window struct {
+	MinArguments int
+	MaxArguments int
+}
+
+var Functions = map[string]Window{
+	RowNumber: {MinArguments: 0, MaxArguments: 0},
+	Rank:      {MinArguments: 0, MaxArguments: 0},
+	DenseRank: {MinArguments: 0, MaxArguments: 0},
+	// LAG(value, offset, default) and LEAD(value, offset, default), the offset is 1 by default
+	Lag:  {MinArguments: 1, MaxArguments: 3},
+	Lead: {MinArguments: 1, MaxArguments: 3},
+	Sum:  {MinArguments: 1, MaxArguments: 1},
+}
+
+func IsWindow(name string) bool {
+	_, ok := Functions[name]
+
+	return ok
+}
+
+// DataType is the data type of the result of a window function whose first
+// argument has the given data type
+func DataType(function, dataType string) string {
+	switch function {
+	case RowNumber, Rank, DenseRank:
+		return dataTypes.Int
+	case Sum:
+		return aggregates.DataType(aggregates.Sum, dataType)
+	}
+
+	return dataType
+}
@@ -2,7 +2,7 @@ package pkg
 
 import "errors"
 
-var InvalidToken = errors.New("Expected WHERE or LIMIT, OFFSET, ORDER BY, got something else.")
+var InvalidToken = errors.New("Expected WHERE or GROUP BY, HAVING, LIMIT, OFFSET, ORDER BY, got something else.")
 var InvalidSelectToken = errors.New("Expected 'select', got something else.")
 var InvalidSelectableColumns = errors.New("Expected selectable column")
 var InvalidDuplicatedColumn = errors.New("Duplicated selectable column")
@@ -19,3 +19,17 @@ var InvalidValueToken = errors.New("Invalid value token.")
 var InvalidDataType = errors.New("Invalid data type.")
 var InvalidConditionAlias = errors.New("Invalid condition alias.")
 var InvalidOrderBy = errors.New("Invalid ORDER BY")
+var InvalidParenthesis = errors.New("Unbalanced parenthesis.")
+var InvalidRegex = errors.New("Invalid regular expression.")
+var InvalidAggregate = errors.New("Invalid aggregate function.")
+var InvalidGroupBy = errors.New("Invalid GROUP BY")
+var InvalidHaving = errors.New("Invalid HAVING")
+var InvalidDistinct = errors.New("Invalid DISTINCT")
+var InvalidFunction = errors.New("Invalid function.")
+var InvalidCollation = errors.New("Invalid collation.")
+var InvalidJoin = errors.New("Invalid JOIN")
+var InvalidSetOperation = errors.New("Invalid set operation")
+var InvalidSubquery = errors.New("Invalid subquery")
+var InvalidDerivedTable = errors.New("Invalid derived table")
+var InvalidCase = errors.New("Invalid CASE")
+var InvalidWindow = errors.New("Invalid window function")
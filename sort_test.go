@@ -34,3 +34,22 @@ func TestSingleColumnStringSort(t *testing.T) {
 		assert.Equal(t, res["Year"], "2021")
 	}
 }
+
+func TestMixedColumnSort(t *testing.T) {
+	c := New()
+
+	// a column that is not only numbers is sorted as strings, comparing "2" and "10" as
+	// numbers would not give an order
+	res := c.Run("SELECT 'n.code' FROM path:testdata/numbers.csv AS n ORDER BY 'n.code'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"code": "10"}, {"code": "1a"}, {"code": "2"}, {"code": "b"}}, res.Data)
+
+	res = c.Run("SELECT 'n.code' FROM path:testdata/numbers.csv AS n ORDER BY 'n.code' DESC")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"code": "b"}, {"code": "2"}, {"code": "1a"}, {"code": "10"}}, res.Data)
+
+	// numbers are sorted as numbers
+	res = c.Run("SELECT 'n.code' FROM path:testdata/numbers.csv AS n WHERE 'n.code' IN ('2', '10') ORDER BY 'n.code'")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, []map[string]string{{"code": "2"}, {"code": "10"}}, res.Data)
+}